@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/NKV510/wallet-service/internal/auth"
+	"github.com/NKV510/wallet-service/internal/repository"
+)
+
+func runAPIKey(ctx context.Context, store repository.APIKeyStore, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: apikey create|list|revoke")
+	}
+
+	switch args[0] {
+	case "create":
+		flags := flag.NewFlagSet("apikey create", flag.ContinueOnError)
+		name := flags.String("name", "", "key name")
+		scopes := flags.String("scopes", "", "comma separated scopes: "+strings.Join(auth.Scopes, ", "))
+		wallets := flags.String("wallets", "", "comma separated wallet ids the key is limited to")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		key, err := auth.CreateKey(ctx, store, *name, splitList(*scopes), splitList(*wallets))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("ID:  %s\nKey: %s\n", key.ID, key.Key)
+		fmt.Println("Store the key now, it cannot be shown again.")
+		return nil
+
+	case "list":
+		keys, err := store.ListAPIKeys(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tPREFIX\tSCOPES\tWALLETS\tCREATED AT\tREVOKED AT")
+		for _, key := range keys {
+			wallets := "all"
+			if len(key.WalletIDs) > 0 {
+				wallets = strings.Join(key.WalletIDs, ",")
+			}
+			revokedAt := "-"
+			if key.RevokedAt != nil {
+				revokedAt = key.RevokedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", key.ID, key.Name, key.Prefix, strings.Join(key.Scopes, ","), wallets, key.CreatedAt.Format(time.RFC3339), revokedAt)
+		}
+		return w.Flush()
+
+	case "revoke":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: apikey revoke <id>")
+		}
+		if _, err := store.RevokeAPIKey(ctx, args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("Revoked %s\n", args[1])
+		return nil
+
+	default:
+		return fmt.Errorf("unknown apikey command %q, expected create, list or revoke", args[0])
+	}
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -10,16 +12,46 @@ import (
 	"time"
 
 	"github.com/NKV510/wallet-service/internal"
+	"github.com/NKV510/wallet-service/internal/auth"
 	"github.com/NKV510/wallet-service/internal/database"
+	"github.com/NKV510/wallet-service/internal/events"
+	"github.com/NKV510/wallet-service/internal/exchange"
+	"github.com/NKV510/wallet-service/internal/grpcapi"
 	"github.com/NKV510/wallet-service/internal/handlers"
+	"github.com/NKV510/wallet-service/internal/logging"
+	"github.com/NKV510/wallet-service/internal/metrics"
+	"github.com/NKV510/wallet-service/internal/ratelimit"
+	"github.com/NKV510/wallet-service/internal/reconcile"
 	"github.com/NKV510/wallet-service/internal/repository"
+	"github.com/NKV510/wallet-service/internal/tracing"
+	"github.com/NKV510/wallet-service/internal/webhooks"
+	"github.com/NKV510/wallet-service/migrations"
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 )
 
 func main() {
 	cfg, err := internal.LoadConfig()
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		fatal("failed to load config", err)
+	}
+
+	logger, err := logging.New(os.Stdout, cfg.LogLevel, cfg.LogFormat)
+	if err != nil {
+		fatal("failed to configure logging", err)
+	}
+	slog.SetDefault(logger)
+	ctx := logging.WithLogger(context.Background(), logger)
+
+	tracer, closeTracing, err := setupTracing(cfg)
+	if err != nil {
+		fatal("failed to configure tracing", err)
+	}
+	defer closeTracing()
+
+	var queryTracer pgx.QueryTracer
+	if tracer != nil {
+		queryTracer = tracing.NewQueryTracer(tracer)
 	}
 
 	dbPool, err := database.NewDBPool(
@@ -29,25 +61,170 @@ func main() {
 		cfg.DBPassword,
 		cfg.DBName,
 		cfg.MaxDBConns,
+		queryTracer,
 	)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		fatal("failed to connect to database", err)
 	}
 	defer dbPool.Close()
 
-	log.Println("Database connected successfully")
+	logger.Info("database connected")
+
+	migrator, err := database.NewMigrator(dbPool, migrations.FS, database.WithSetting("app.default_currency", cfg.DefaultCurrency))
+	if err != nil {
+		fatal("failed to load migrations", err)
+	}
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			err = runMigrate(ctx, migrator, os.Args[2:])
+		case "apikey":
+			err = runAPIKey(ctx, repository.NewWalletRepository(dbPool), os.Args[2:])
+		case "reconcile":
+			err = runReconcile(ctx, repository.NewWalletRepository(dbPool, repository.WithDefaultCurrency(cfg.DefaultCurrency)), os.Args[2:])
+		default:
+			err = fmt.Errorf("unknown command %q", os.Args[1])
+		}
+		if err != nil {
+			fatal("command failed", err)
+		}
+		return
+	}
+
+	if cfg.MigrateOnStart {
+		if _, err := migrator.Up(ctx); err != nil {
+			fatal("failed to apply migrations", err)
+		}
+	}
+
+	if err := database.CheckSchema(ctx, dbPool, migrator); err != nil {
+		fatal("database schema check failed", err)
+	}
+
+	serviceMetrics := metrics.New()
+	serviceMetrics.RegisterPool(dbPool)
+
+	walletRepo := repository.NewWalletRepository(dbPool,
+		repository.WithIdempotencyTTL(cfg.IdempotencyKeyTTL),
+		repository.WithHoldTTL(cfg.HoldTTL),
+		repository.WithFrozenDeposits(cfg.FrozenWalletAllowDeposits),
+		repository.WithWithdrawalLimits(cfg.WithdrawalLimits),
+		repository.WithDefaultCurrency(cfg.DefaultCurrency),
+		repository.WithMetrics(serviceMetrics),
+	)
+
+	bgCtx, stopBackground := context.WithCancel(ctx)
+	defer stopBackground()
+
+	go repository.StartIdempotencyCleanup(bgCtx, walletRepo, cfg.IdempotencyCleanupInterval)
+	go repository.StartHoldExpiry(bgCtx, walletRepo, cfg.HoldExpiryInterval)
+	go repository.StartOutboxCleanup(bgCtx, walletRepo, cfg.OutboxCleanupInterval, cfg.OutboxRetention)
+	go repository.StartExchangeQuoteCleanup(bgCtx, walletRepo, cfg.ExchangeQuoteCleanupInterval)
+	go repository.StartShardCompaction(bgCtx, walletRepo, cfg.WalletShardCompactInterval)
+	if cfg.ReconciliationEnabled {
+		reconciler := reconcile.New(walletRepo, cfg.ReconciliationAutoCorrect)
+		go reconciler.Run(bgCtx, cfg.ReconciliationInterval)
+	}
+
+	publisher, closePublisher, err := setupPublisher(cfg)
+	if err != nil {
+		fatal("failed to configure event publishing", err)
+	}
+	defer closePublisher()
+	if cfg.WebhooksEnabled {
+		dispatcher := webhooks.NewDispatcher(walletRepo)
+		if publisher != nil {
+			publisher = events.NewMultiPublisher(publisher, dispatcher)
+		} else {
+			publisher = dispatcher
+		}
 
-	walletRepo := repository.NewWalletRepository(dbPool)
-	walletHandler := handlers.NewWalletHandler(walletRepo)
+		sender := webhooks.NewSender(walletRepo, &http.Client{Timeout: cfg.WebhookTimeout}, webhooks.RetryPolicy{
+			MaxAttempts: cfg.WebhookMaxAttempts,
+			BaseDelay:   cfg.WebhookRetryBaseDelay,
+			MaxDelay:    cfg.WebhookRetryMaxDelay,
+		}, cfg.WebhookBatchSize)
+		go sender.Run(bgCtx, cfg.WebhookDeliveryInterval)
+	}
+	if publisher != nil {
+		relay := events.NewRelay(walletRepo, publisher, cfg.OutboxBatchSize)
+		go relay.Run(bgCtx, cfg.OutboxRelayInterval)
+	} else {
+		logger.Warn("event publishing is disabled, outbox events are kept until a publisher is configured")
+	}
+
+	walletHandler := handlers.NewWalletHandler(repository.NewTracedStore(walletRepo, tracer))
+	apiKeyHandler := handlers.NewAPIKeyHandler(walletRepo)
+	webhookHandler := handlers.NewWebhookHandler(walletRepo)
+	ledgerHandler := handlers.NewLedgerHandler(walletRepo)
+	reconciliationHandler := handlers.NewReconciliationHandler(walletRepo)
+	shardHandler := handlers.NewShardHandler(walletRepo)
+
+	rates, err := setupRates(cfg, walletRepo)
+	if err != nil {
+		fatal("failed to configure exchange rates", err)
+	}
+	exchangeHandler := handlers.NewExchangeHandler(exchange.New(walletRepo, rates, cfg.ExchangeQuoteTTL), walletRepo)
 
-	router := gin.Default()
+	router := gin.New()
+	router.Use(gin.Recovery(), tracing.Middleware(tracer), logging.Middleware(logger), serviceMetrics.Middleware())
 
+	var authenticate auth.Authenticator
 	v1 := router.Group("/api/v1")
+	if cfg.AuthEnabled {
+		authMiddleware, authenticator, err := setupAuth(cfg, walletRepo)
+		if err != nil {
+			fatal("failed to configure authentication", err)
+		}
+		v1.Use(authMiddleware)
+		authenticate = authenticator
+	} else {
+		logger.Warn("authentication is disabled")
+	}
+	if rules := rateLimitRules(cfg); len(rules) > 0 {
+		v1.Use(ratelimit.Middleware(rules...))
+	}
 	{
 		v1.POST("/wallet", walletHandler.ProcessOperation)
-		v1.GET("/wallets/:walletId", walletHandler.GetWalletBalance)
+		v1.POST("/wallet/batch", walletHandler.ProcessBatch)
+		v1.POST("/transfers", walletHandler.Transfer)
+		v1.POST("/exchange/quotes", exchangeHandler.CreateQuote)
+		v1.POST("/exchanges", exchangeHandler.Exchange)
+		v1.GET("/wallets/:walletId", auth.Require(auth.ScopeWalletRead), walletHandler.GetWalletBalance)
+		v1.GET("/wallets/:walletId/transactions", auth.Require(auth.ScopeWalletRead), walletHandler.ListTransactions)
+		v1.POST("/wallets/:walletId/holds", auth.Require(auth.ScopeWalletWithdraw), walletHandler.CreateHold)
+		v1.POST("/wallets/:walletId/holds/:holdId/capture", auth.Require(auth.ScopeWalletWithdraw), walletHandler.CaptureHold)
+		v1.POST("/wallets/:walletId/holds/:holdId/void", auth.Require(auth.ScopeWalletWithdraw), walletHandler.VoidHold)
+
+		admin := v1.Group("/admin", auth.Require(auth.ScopeAdmin))
+		admin.POST("/wallets/:walletId/freeze", walletHandler.FreezeWallet)
+		admin.POST("/wallets/:walletId/unfreeze", walletHandler.UnfreezeWallet)
+		admin.POST("/wallets/:walletId/close", walletHandler.CloseWallet)
+		admin.GET("/wallets/:walletId/limits", walletHandler.GetWalletLimits)
+		admin.PUT("/wallets/:walletId/limits", walletHandler.SetWalletLimits)
+		admin.PUT("/wallets/:walletId/shards", shardHandler.SetWalletShards)
+		admin.POST("/api-keys", apiKeyHandler.CreateAPIKey)
+		admin.GET("/api-keys", apiKeyHandler.ListAPIKeys)
+		admin.DELETE("/api-keys/:keyId", apiKeyHandler.RevokeAPIKey)
+		admin.POST("/webhooks", webhookHandler.CreateWebhook)
+		admin.GET("/webhooks", webhookHandler.ListWebhooks)
+		admin.GET("/webhooks/:webhookId", webhookHandler.GetWebhook)
+		admin.PUT("/webhooks/:webhookId", webhookHandler.UpdateWebhook)
+		admin.DELETE("/webhooks/:webhookId", webhookHandler.DeleteWebhook)
+		admin.GET("/webhooks/:webhookId/deliveries", webhookHandler.ListDeliveries)
+		admin.POST("/webhooks/:webhookId/deliveries/:deliveryId/redeliver", webhookHandler.Redeliver)
+		admin.GET("/trial-balance", ledgerHandler.TrialBalance)
+		admin.GET("/journals/:journalId", ledgerHandler.GetJournal)
+		admin.GET("/reconciliation", reconciliationHandler.LastReport)
+		admin.GET("/exchange-rates", exchangeHandler.ListRates)
+		if cfg.ExchangeRatesSource == "db" {
+			admin.PUT("/exchange-rates/:from/:to", exchangeHandler.SetRate)
+		}
 	}
 
+	router.GET("/metrics", gin.WrapH(serviceMetrics.Registry))
+
 	router.GET("/health", func(c *gin.Context) {
 		if err := dbPool.Ping(c.Request.Context()); err != nil {
 			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "database unavailable"})
@@ -62,24 +239,165 @@ func main() {
 	}
 
 	go func() {
-		log.Printf("Server starting on port %s", cfg.ServerPort)
+		logger.Info("server starting", "port", cfg.ServerPort)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to start server: %v", err)
+			fatal("failed to start server", err)
 		}
 	}()
 
+	var grpcServer *grpcapi.Server
+	if cfg.GRPCEnabled {
+		grpcServer = grpcapi.New(repository.NewTracedStore(walletRepo, tracer), grpcapi.Config{
+			Logger:        logger,
+			Authenticate:  authenticate,
+			WatchInterval: cfg.GRPCWatchInterval,
+		})
+		lis, err := net.Listen("tcp", ":"+cfg.GRPCPort)
+		if err != nil {
+			fatal("failed to listen for gRPC", err)
+		}
+		go func() {
+			logger.Info("gRPC server starting", "port", cfg.GRPCPort)
+			if err := grpcServer.Serve(lis); err != nil {
+				fatal("failed to start gRPC server", err)
+			}
+		}()
+	}
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("Shutting down server...")
+	logger.Info("shutting down server")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	stopBackground()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+	if grpcServer != nil {
+		grpcServer.Shutdown(shutdownCtx)
+	}
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		fatal("server forced to shutdown", err)
+	}
+
+	logger.Info("server exited")
+}
+
+// setupTracing returns the tracer selected by TRACING_EXPORTER, or nil when
+// tracing is off, and a function that flushes the exporter on shutdown.
+func setupTracing(cfg *internal.Config) (*tracing.Tracer, func(), error) {
+	switch cfg.TracingExporter {
+	case "", "none":
+		return nil, func() {}, nil
+	case "stdout":
+		return tracing.NewTracer(tracing.NewWriterExporter(os.Stdout)), func() {}, nil
+	case "file":
+		f, err := os.OpenFile(cfg.TracingFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open trace file: %w", err)
+		}
+		return tracing.NewTracer(tracing.NewWriterExporter(f)), func() { f.Close() }, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown tracing exporter %q", cfg.TracingExporter)
+	}
+}
+
+// setupAuth returns the HTTP middleware and the authenticator for gRPC for
+// the mode selected by AUTH_MODE.
+func setupAuth(cfg *internal.Config, store repository.APIKeyStore) (gin.HandlerFunc, auth.Authenticator, error) {
+	switch cfg.AuthMode {
+	case "", "apikey":
+		return auth.Middleware(store), auth.APIKeyAuthenticator(store), nil
+	case "jwt":
+		var keys []auth.Key
+		if cfg.JWTSecret != "" {
+			keys = append(keys, auth.HMACKey("", []byte(cfg.JWTSecret)))
+		}
+		if cfg.JWTPublicKeyFile != "" {
+			public, err := auth.LoadRSAPublicKey(cfg.JWTPublicKeyFile)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to load JWT public key: %w", err)
+			}
+			keys = append(keys, auth.RSAKey("", public))
+		}
+		if cfg.JWTJWKSFile != "" {
+			jwks, err := auth.LoadJWKS(cfg.JWTJWKSFile)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to load JWKS: %w", err)
+			}
+			keys = append(keys, jwks...)
+		}
+
+		verifier, err := auth.NewJWTVerifier(auth.JWTConfig{
+			Issuer:       cfg.JWTIssuer,
+			Audience:     cfg.JWTAudience,
+			WalletsClaim: cfg.JWTWalletsClaim,
+			ClockSkew:    cfg.JWTClockSkew,
+		}, keys...)
+		if err != nil {
+			return nil, nil, err
+		}
+		return auth.JWTMiddleware(verifier), auth.JWTAuthenticator(verifier), nil
+	default:
+		return nil, nil, fmt.Errorf("unknown auth mode %q", cfg.AuthMode)
+	}
+}
+
+// setupRates returns the rate provider selected by EXCHANGE_RATES_SOURCE.
+// Rates from a file are fixed until restart; rates in the database can be
+// changed through the admin API.
+func setupRates(cfg *internal.Config, store repository.ExchangeStore) (exchange.RateProvider, error) {
+	switch cfg.ExchangeRatesSource {
+	case "", "db":
+		return exchange.NewStoreRates(store), nil
+	case "file":
+		return exchange.LoadStaticRates(cfg.ExchangeRatesFile)
+	default:
+		return nil, fmt.Errorf("unknown exchange rates source %q", cfg.ExchangeRatesSource)
 	}
+}
+
+func setupPublisher(cfg *internal.Config) (events.Publisher, func(), error) {
+	switch cfg.EventsPublisher {
+	case "", "none":
+		return nil, func() {}, nil
+	case "stdout":
+		return events.NewWriterPublisher(os.Stdout), func() {}, nil
+	case "file":
+		f, err := os.OpenFile(cfg.EventsFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open events file: %w", err)
+		}
+		return events.NewWriterPublisher(f), func() { f.Close() }, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown events publisher %q", cfg.EventsPublisher)
+	}
+}
+
+// rateLimitRules limits each client and each target wallet separately. A
+// zero rate disables the rule.
+func rateLimitRules(cfg *internal.Config) []ratelimit.Rule {
+	var rules []ratelimit.Rule
+	if cfg.RateLimitClientRPS > 0 {
+		rules = append(rules, ratelimit.Rule{
+			Name:    "client",
+			Limiter: ratelimit.NewTokenBucket(cfg.RateLimitClientRPS, cfg.RateLimitClientBurst),
+			Keys:    ratelimit.ClientKeys,
+		})
+	}
+	if cfg.RateLimitWalletRPS > 0 {
+		rules = append(rules, ratelimit.Rule{
+			Name:    "wallet",
+			Limiter: ratelimit.NewTokenBucket(cfg.RateLimitWalletRPS, cfg.RateLimitWalletBurst),
+			Keys:    ratelimit.WalletKeys,
+		})
+	}
+	return rules
+}
 
-	log.Println("Server exited")
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
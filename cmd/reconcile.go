@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/NKV510/wallet-service/internal/reconcile"
+	"github.com/NKV510/wallet-service/internal/repository"
+)
+
+func runReconcile(ctx context.Context, store repository.ReconciliationStore, args []string) error {
+	flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	fix := flags.Bool("fix", false, "correct every drift with an adjustment entry")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	report, err := reconcile.New(store, *fix).Reconcile(ctx)
+	if err != nil {
+		return err
+	}
+
+	var uncorrected int
+	if len(report.Drifts) > 0 {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "WALLET\tEXPECTED\tACTUAL\tDIFFERENCE\tCORRECTED")
+		for _, drift := range report.Drifts {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%t\n", drift.WalletID, drift.Expected, drift.Actual, drift.Difference, drift.Corrected)
+			if !drift.Corrected {
+				uncorrected++
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Checked %d wallets, %d drifted\n", report.WalletsChecked, len(report.Drifts))
+
+	if uncorrected > 0 {
+		return fmt.Errorf("%d wallets drifted from their ledger, run with -fix to correct them", uncorrected)
+	}
+	return nil
+}
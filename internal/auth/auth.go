@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/NKV510/wallet-service/internal/models"
+	"github.com/NKV510/wallet-service/internal/repository"
+)
+
+const (
+	ScopeWalletRead     = "wallet:read"
+	ScopeWalletDeposit  = "wallet:deposit"
+	ScopeWalletWithdraw = "wallet:withdraw"
+	// ScopeAdmin grants every other scope as well as the admin endpoints.
+	ScopeAdmin = "admin"
+
+	keyPrefix = "wsk_"
+)
+
+var Scopes = []string{ScopeWalletRead, ScopeWalletDeposit, ScopeWalletWithdraw, ScopeAdmin}
+
+// HashKey returns the hex SHA-256 of a plaintext key, as stored in the
+// database. Keys are long random strings, so a fast hash is enough.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateKey generates a new key with the given scopes, optionally restricted
+// to walletIDs, stores its hash and returns the stored key together with the
+// plaintext.
+func CreateKey(ctx context.Context, store repository.APIKeyStore, name string, scopes, walletIDs []string) (*models.CreateAPIKeyResponse, error) {
+	if strings.TrimSpace(name) == "" {
+		return nil, fmt.Errorf("%w: name is required", repository.ErrInvalidOperation)
+	}
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", repository.ErrInvalidOperation)
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(Scopes, scope) {
+			return nil, fmt.Errorf("%w: unknown scope %q", repository.ErrInvalidOperation, scope)
+		}
+	}
+
+	normalized := make([]string, 0, len(walletIDs))
+	for _, id := range walletIDs {
+		normalized = append(normalized, strings.ToLower(strings.TrimSpace(id)))
+	}
+
+	var secret [32]byte
+	rand.Read(secret[:])
+	plaintext := keyPrefix + hex.EncodeToString(secret[:])
+
+	key, err := store.CreateAPIKey(ctx, models.APIKey{
+		Name:      name,
+		Prefix:    plaintext[:len(keyPrefix)+8],
+		Scopes:    slices.Compact(slices.Sorted(slices.Values(scopes))),
+		WalletIDs: normalized,
+	}, HashKey(plaintext))
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.CreateAPIKeyResponse{APIKey: *key, Key: plaintext}, nil
+}
+
+// Allows reports whether key grants scope on every wallet in walletIDs. A key
+// without wallet ids may act on any wallet.
+func Allows(key *models.APIKey, scope string, walletIDs ...string) bool {
+	return keyPrincipal(key).Allows(scope, walletIDs...)
+}
+
+func keyPrincipal(key *models.APIKey) *Principal {
+	return &Principal{Subject: key.Name, Scopes: key.Scopes, WalletIDs: key.WalletIDs}
+}
@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/NKV510/wallet-service/internal/models"
+	"github.com/NKV510/wallet-service/internal/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	walletA = "123e4567-e89b-12d3-a456-426614174000"
+	walletB = "223e4567-e89b-12d3-a456-426614174000"
+)
+
+func TestCreateKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		keyName string
+		scopes  []string
+		wantErr bool
+	}{
+		{name: "valid", keyName: "payments", scopes: []string{ScopeWalletRead, ScopeWalletDeposit}},
+		{name: "missing name", keyName: " ", scopes: []string{ScopeWalletRead}, wantErr: true},
+		{name: "no scopes", keyName: "payments", wantErr: true},
+		{name: "unknown scope", keyName: "payments", scopes: []string{"wallet:transfer"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := repository.NewMemoryWalletRepository()
+
+			created, err := CreateKey(context.Background(), store, tt.keyName, tt.scopes, []string{strings.ToUpper(walletA)})
+			if tt.wantErr {
+				assert.ErrorIs(t, err, repository.ErrInvalidOperation)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.True(t, strings.HasPrefix(created.Key, keyPrefix))
+			assert.Equal(t, created.Key[:len(keyPrefix)+8], created.Prefix)
+			assert.Equal(t, []string{walletA}, created.WalletIDs)
+
+			stored, err := store.GetAPIKeyByHash(context.Background(), HashKey(created.Key))
+			require.NoError(t, err)
+			assert.Equal(t, created.ID, stored.ID)
+		})
+	}
+}
+
+func TestAllows(t *testing.T) {
+	reader := &models.APIKey{Scopes: []string{ScopeWalletRead}}
+	scoped := &models.APIKey{Scopes: []string{ScopeWalletWithdraw}, WalletIDs: []string{walletA}}
+	admin := &models.APIKey{Scopes: []string{ScopeAdmin}}
+
+	assert.True(t, Allows(reader, ScopeWalletRead, walletB))
+	assert.False(t, Allows(reader, ScopeWalletDeposit, walletB))
+	assert.True(t, Allows(scoped, ScopeWalletWithdraw, strings.ToUpper(walletA)))
+	assert.False(t, Allows(scoped, ScopeWalletWithdraw, walletB))
+	assert.False(t, Allows(scoped, ScopeWalletWithdraw, walletA, walletB))
+	assert.True(t, Allows(admin, ScopeWalletDeposit, walletB))
+	assert.True(t, Allows(admin, ScopeAdmin))
+}
+
+func TestMiddleware(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryWalletRepository()
+
+	reader, err := CreateKey(ctx, store, "reader", []string{ScopeWalletRead}, []string{walletA})
+	require.NoError(t, err)
+	revoked, err := CreateKey(ctx, store, "old", []string{ScopeAdmin}, nil)
+	require.NoError(t, err)
+	_, err = store.RevokeAPIKey(ctx, revoked.ID)
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware(store))
+	router.GET("/wallets/:walletId", Require(ScopeWalletRead), func(c *gin.Context) {
+		c.String(http.StatusOK, KeyFromContext(c).Name)
+	})
+	router.POST("/wallets/:walletId", Require(ScopeWalletDeposit), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name           string
+		method         string
+		walletID       string
+		header         string
+		value          string
+		expectedStatus int
+	}{
+		{name: "missing key", method: "GET", walletID: walletA, expectedStatus: http.StatusUnauthorized},
+		{name: "invalid key", method: "GET", walletID: walletA, header: APIKeyHeader, value: "wsk_nope", expectedStatus: http.StatusUnauthorized},
+		{name: "revoked key", method: "GET", walletID: walletA, header: APIKeyHeader, value: revoked.Key, expectedStatus: http.StatusUnauthorized},
+		{name: "allowed", method: "GET", walletID: walletA, header: APIKeyHeader, value: reader.Key, expectedStatus: http.StatusOK},
+		{name: "bearer token", method: "GET", walletID: walletA, header: "Authorization", value: "Bearer " + reader.Key, expectedStatus: http.StatusOK},
+		{name: "other wallet", method: "GET", walletID: walletB, header: APIKeyHeader, value: reader.Key, expectedStatus: http.StatusForbidden},
+		{name: "missing scope", method: "POST", walletID: walletA, header: APIKeyHeader, value: reader.Key, expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, "/wallets/"+tt.walletID, nil)
+			require.NoError(t, err)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusOK && tt.method == "GET" {
+				assert.Equal(t, "reader", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAuthorize_WithoutMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	assert.True(t, Authorize(c, ScopeAdmin, walletA))
+	assert.False(t, c.IsAborted())
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/NKV510/wallet-service/internal/repository"
+)
+
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// Authenticator resolves the credential a caller presented to a principal,
+// for transports other than the gin middlewares. Bad credentials yield an
+// error wrapping ErrUnauthenticated; any other error means the credential
+// could not be checked.
+type Authenticator func(ctx context.Context, credential string) (*Principal, error)
+
+// APIKeyAuthenticator accepts the same API keys as Middleware.
+func APIKeyAuthenticator(store repository.APIKeyStore) Authenticator {
+	return func(ctx context.Context, credential string) (*Principal, error) {
+		if credential == "" {
+			return nil, fmt.Errorf("%w: API key is required", ErrUnauthenticated)
+		}
+
+		key, err := store.GetAPIKeyByHash(ctx, HashKey(credential))
+		if err != nil {
+			if errors.Is(err, repository.ErrAPIKeyNotFound) {
+				return nil, fmt.Errorf("%w: invalid API key", ErrUnauthenticated)
+			}
+			return nil, err
+		}
+		if key.Revoked() {
+			return nil, fmt.Errorf("%w: API key has been revoked", ErrUnauthenticated)
+		}
+		return keyPrincipal(key), nil
+	}
+}
+
+// JWTAuthenticator accepts the same tokens as JWTMiddleware.
+func JWTAuthenticator(verifier *JWTVerifier) Authenticator {
+	return func(ctx context.Context, credential string) (*Principal, error) {
+		if credential == "" {
+			return nil, fmt.Errorf("%w: bearer token is required", ErrUnauthenticated)
+		}
+
+		claims, err := verifier.Verify(credential)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrUnauthenticated, err)
+		}
+		return &Principal{
+			Subject:    claims.Subject,
+			Scopes:     claims.Scopes,
+			WalletIDs:  claims.WalletIDs,
+			Restricted: true,
+		}, nil
+	}
+}
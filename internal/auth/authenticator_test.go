@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NKV510/wallet-service/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthenticators(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	store := repository.NewMemoryWalletRepository()
+	key, err := CreateKey(ctx, store, "payments", []string{ScopeWalletRead}, []string{walletA})
+	require.NoError(t, err)
+	revoked, err := CreateKey(ctx, store, "old", []string{ScopeWalletRead}, nil)
+	require.NoError(t, err)
+	_, err = store.RevokeAPIKey(ctx, revoked.ID)
+	require.NoError(t, err)
+
+	apiKeys := APIKeyAuthenticator(store)
+	principal, err := apiKeys(ctx, key.Key)
+	require.NoError(t, err)
+	assert.Equal(t, "payments", principal.Subject)
+	assert.True(t, principal.Allows(ScopeWalletRead, walletA))
+	assert.False(t, principal.Allows(ScopeWalletRead, walletB))
+
+	for _, credential := range []string{"", "wsk_unknown", revoked.Key} {
+		_, err := apiKeys(ctx, credential)
+		assert.ErrorIs(t, err, ErrUnauthenticated, "credential %q", credential)
+	}
+
+	jwts := JWTAuthenticator(newTestVerifier(t, now, HMACKey("", testSecret)))
+	principal, err = jwts(ctx, mintToken(t, map[string]any{"alg": "HS256"}, validClaims(now), signHS256(testSecret)))
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", principal.Subject)
+	assert.True(t, principal.Restricted)
+	assert.Equal(t, []string{walletA}, principal.WalletIDs)
+
+	for _, credential := range []string{"", "not.a.token", mintToken(t, map[string]any{"alg": "HS256"}, validClaims(now), signHS256([]byte("other")))} {
+		_, err := jwts(ctx, credential)
+		assert.ErrorIs(t, err, ErrUnauthenticated)
+	}
+}
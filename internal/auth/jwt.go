@@ -0,0 +1,315 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+
+	DefaultWalletsClaim = "wallet_ids"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// Key verifies token signatures for one algorithm. When both the key and the
+// token carry a key id ("kid"), they must match.
+type Key struct {
+	id     string
+	alg    string
+	secret []byte
+	public *rsa.PublicKey
+}
+
+func HMACKey(id string, secret []byte) Key {
+	return Key{id: id, alg: AlgHS256, secret: secret}
+}
+
+func RSAKey(id string, public *rsa.PublicKey) Key {
+	return Key{id: id, alg: AlgRS256, public: public}
+}
+
+func (k Key) verify(signingInput, signature []byte) bool {
+	switch k.alg {
+	case AlgHS256:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(signingInput)
+		return hmac.Equal(mac.Sum(nil), signature)
+	case AlgRS256:
+		digest := sha256.Sum256(signingInput)
+		return rsa.VerifyPKCS1v15(k.public, crypto.SHA256, digest[:], signature) == nil
+	default:
+		return false
+	}
+}
+
+// JWTConfig controls which claims a token must carry.
+type JWTConfig struct {
+	// Issuer and Audience are checked when set.
+	Issuer   string
+	Audience string
+	// WalletsClaim names the claim listing the wallets the caller owns,
+	// either as an array or as a space or comma separated string.
+	WalletsClaim string
+	// ClockSkew is tolerated on exp, nbf and iat.
+	ClockSkew time.Duration
+}
+
+// Claims is what the service uses from a verified token.
+type Claims struct {
+	Subject   string
+	WalletIDs []string
+	// Scopes come from the "scope" claim; tokens without it get the wallet
+	// scopes.
+	Scopes    []string
+	ExpiresAt time.Time
+}
+
+// JWTVerifier checks HS256 and RS256 tokens against a fixed set of keys.
+type JWTVerifier struct {
+	cfg  JWTConfig
+	keys []Key
+	now  func() time.Time
+}
+
+func NewJWTVerifier(cfg JWTConfig, keys ...Key) (*JWTVerifier, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("at least one JWT verification key is required")
+	}
+	if cfg.WalletsClaim == "" {
+		cfg.WalletsClaim = DefaultWalletsClaim
+	}
+	return &JWTVerifier{cfg: cfg, keys: keys, now: time.Now}, nil
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Verify checks the token's signature and time claims and returns its
+// claims. Every failure wraps ErrInvalidToken.
+func (v *JWTVerifier) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidToken)
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature: %v", ErrInvalidToken, err)
+	}
+
+	signingInput := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, key := range v.keys {
+		if key.alg != header.Alg || (key.id != "" && header.Kid != "" && key.id != header.Kid) {
+			continue
+		}
+		if key.verify(signingInput, signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, fmt.Errorf("%w: signature verification failed", ErrInvalidToken)
+	}
+
+	var payload map[string]any
+	if err := decodeSegment(parts[1], &payload); err != nil {
+		return nil, fmt.Errorf("%w: payload: %v", ErrInvalidToken, err)
+	}
+	return v.claims(payload)
+}
+
+func (v *JWTVerifier) claims(payload map[string]any) (*Claims, error) {
+	now := v.now()
+	skew := v.cfg.ClockSkew
+
+	exp, ok := numericDate(payload["exp"])
+	if !ok {
+		return nil, fmt.Errorf("%w: exp is required", ErrInvalidToken)
+	}
+	if !now.Before(exp.Add(skew)) {
+		return nil, fmt.Errorf("%w: token has expired", ErrInvalidToken)
+	}
+	if nbf, ok := numericDate(payload["nbf"]); ok && now.Add(skew).Before(nbf) {
+		return nil, fmt.Errorf("%w: token is not valid yet", ErrInvalidToken)
+	}
+	if iat, ok := numericDate(payload["iat"]); ok && now.Add(skew).Before(iat) {
+		return nil, fmt.Errorf("%w: token was issued in the future", ErrInvalidToken)
+	}
+
+	if v.cfg.Issuer != "" && payload["iss"] != v.cfg.Issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer", ErrInvalidToken)
+	}
+	if v.cfg.Audience != "" && !slices.Contains(stringList(payload["aud"]), v.cfg.Audience) {
+		return nil, fmt.Errorf("%w: unexpected audience", ErrInvalidToken)
+	}
+
+	subject, _ := payload["sub"].(string)
+	if subject == "" {
+		return nil, fmt.Errorf("%w: sub is required", ErrInvalidToken)
+	}
+
+	walletIDs := stringList(payload[v.cfg.WalletsClaim])
+	for i, id := range walletIDs {
+		walletIDs[i] = strings.ToLower(id)
+	}
+
+	scopes := []string{ScopeWalletRead, ScopeWalletDeposit, ScopeWalletWithdraw}
+	if _, ok := payload["scope"]; ok {
+		scopes = stringList(payload["scope"])
+	}
+
+	return &Claims{
+		Subject:   subject,
+		WalletIDs: walletIDs,
+		Scopes:    scopes,
+		ExpiresAt: exp,
+	}, nil
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+func numericDate(value any) (time.Time, bool) {
+	number, ok := value.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	seconds, err := number.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second))), true
+}
+
+// stringList reads a claim that holds either an array of strings or a single
+// string of space or comma separated values.
+func stringList(value any) []string {
+	var list []string
+	switch value := value.(type) {
+	case string:
+		list = strings.FieldsFunc(value, func(r rune) bool { return r == ' ' || r == ',' })
+	case []any:
+		for _, item := range value {
+			if s, ok := item.(string); ok && s != "" {
+				list = append(list, s)
+			}
+		}
+	}
+	return list
+}
+
+// LoadRSAPublicKey reads a PEM encoded RSA public key (PKIX or PKCS#1).
+func LoadRSAPublicKey(path string) (*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block found", path)
+	}
+
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	key, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an RSA public key", path)
+	}
+	return key, nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	K   string `json:"k"`
+}
+
+// LoadJWKS reads RSA ("kty": "RSA") and HMAC ("kty": "oct") keys from a local
+// JWKS file. Keys meant for encryption are skipped.
+func LoadJWKS(path string) ([]Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	var keys []Key
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch k.Kty {
+		case "RSA":
+			n, err := base64.RawURLEncoding.DecodeString(k.N)
+			if err != nil {
+				return nil, fmt.Errorf("%s: key %q: n: %w", path, k.Kid, err)
+			}
+			e, err := base64.RawURLEncoding.DecodeString(k.E)
+			if err != nil {
+				return nil, fmt.Errorf("%s: key %q: e: %w", path, k.Kid, err)
+			}
+			exponent := new(big.Int).SetBytes(e)
+			if !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+				return nil, fmt.Errorf("%s: key %q: invalid exponent", path, k.Kid)
+			}
+			keys = append(keys, RSAKey(k.Kid, &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(exponent.Int64()),
+			}))
+		case "oct":
+			secret, err := base64.RawURLEncoding.DecodeString(k.K)
+			if err != nil {
+				return nil, fmt.Errorf("%s: key %q: k: %w", path, k.Kid, err)
+			}
+			keys = append(keys, HMACKey(k.Kid, secret))
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s: no signing keys found", path)
+	}
+	return keys, nil
+}
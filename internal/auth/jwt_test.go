@@ -0,0 +1,282 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testSecret = []byte("test-secret-with-enough-entropy")
+
+func mintToken(t *testing.T, header, claims map[string]any, sign func(input []byte) []byte) string {
+	t.Helper()
+
+	encode := func(v any) string {
+		data, err := json.Marshal(v)
+		require.NoError(t, err)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	input := encode(header) + "." + encode(claims)
+	return input + "." + base64.RawURLEncoding.EncodeToString(sign([]byte(input)))
+}
+
+func signHS256(secret []byte) func([]byte) []byte {
+	return func(input []byte) []byte {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(input)
+		return mac.Sum(nil)
+	}
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey) func([]byte) []byte {
+	return func(input []byte) []byte {
+		digest := sha256.Sum256(input)
+		signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+		return signature
+	}
+}
+
+func validClaims(now time.Time) map[string]any {
+	return map[string]any{
+		"sub":        "user-1",
+		"iss":        "https://id.example.com",
+		"aud":        []string{"wallet-service"},
+		"iat":        now.Unix(),
+		"exp":        now.Add(time.Hour).Unix(),
+		"wallet_ids": []string{walletA},
+	}
+}
+
+func newTestVerifier(t *testing.T, now time.Time, keys ...Key) *JWTVerifier {
+	t.Helper()
+
+	verifier, err := NewJWTVerifier(JWTConfig{
+		Issuer:    "https://id.example.com",
+		Audience:  "wallet-service",
+		ClockSkew: time.Minute,
+	}, keys...)
+	require.NoError(t, err)
+	verifier.now = func() time.Time { return now }
+	return verifier
+}
+
+func TestJWTVerifier_HS256(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	verifier := newTestVerifier(t, now, HMACKey("", testSecret))
+	header := map[string]any{"alg": AlgHS256, "typ": "JWT"}
+
+	with := func(changes map[string]any) map[string]any {
+		claims := validClaims(now)
+		for k, v := range changes {
+			if v == nil {
+				delete(claims, k)
+				continue
+			}
+			claims[k] = v
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "valid", token: mintToken(t, header, with(nil), signHS256(testSecret))},
+		{name: "expired within skew", token: mintToken(t, header, with(map[string]any{"exp": now.Add(-30 * time.Second).Unix()}), signHS256(testSecret))},
+		{name: "expired beyond skew", token: mintToken(t, header, with(map[string]any{"exp": now.Add(-2 * time.Minute).Unix()}), signHS256(testSecret)), wantErr: true},
+		{name: "missing exp", token: mintToken(t, header, with(map[string]any{"exp": nil}), signHS256(testSecret)), wantErr: true},
+		{name: "nbf within skew", token: mintToken(t, header, with(map[string]any{"nbf": now.Add(30 * time.Second).Unix()}), signHS256(testSecret))},
+		{name: "nbf beyond skew", token: mintToken(t, header, with(map[string]any{"nbf": now.Add(2 * time.Minute).Unix()}), signHS256(testSecret)), wantErr: true},
+		{name: "issued in the future", token: mintToken(t, header, with(map[string]any{"iat": now.Add(5 * time.Minute).Unix()}), signHS256(testSecret)), wantErr: true},
+		{name: "wrong issuer", token: mintToken(t, header, with(map[string]any{"iss": "https://evil.example.com"}), signHS256(testSecret)), wantErr: true},
+		{name: "wrong audience", token: mintToken(t, header, with(map[string]any{"aud": "other-service"}), signHS256(testSecret)), wantErr: true},
+		{name: "missing subject", token: mintToken(t, header, with(map[string]any{"sub": nil}), signHS256(testSecret)), wantErr: true},
+		{name: "wrong secret", token: mintToken(t, header, with(nil), signHS256([]byte("other"))), wantErr: true},
+		{name: "alg none", token: mintToken(t, map[string]any{"alg": "none"}, with(nil), func([]byte) []byte { return nil }), wantErr: true},
+		{name: "malformed", token: "not-a-token", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := verifier.Verify(tt.token)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidToken)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "user-1", claims.Subject)
+			assert.Equal(t, []string{walletA}, claims.WalletIDs)
+			assert.Equal(t, []string{ScopeWalletRead, ScopeWalletDeposit, ScopeWalletWithdraw}, claims.Scopes)
+		})
+	}
+
+	t.Run("tampered payload", func(t *testing.T) {
+		token := strings.Split(mintToken(t, header, with(nil), signHS256(testSecret)), ".")
+		forged := strings.Split(mintToken(t, header, with(map[string]any{"wallet_ids": []string{walletA, walletB}}), signHS256(testSecret)), ".")
+
+		_, err := verifier.Verify(token[0] + "." + forged[1] + "." + token[2])
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("claims as strings", func(t *testing.T) {
+		token := mintToken(t, header, with(map[string]any{
+			"wallet_ids": walletA + " " + walletB,
+			"scope":      "wallet:read",
+		}), signHS256(testSecret))
+
+		claims, err := verifier.Verify(token)
+		require.NoError(t, err)
+		assert.Equal(t, []string{walletA, walletB}, claims.WalletIDs)
+		assert.Equal(t, []string{ScopeWalletRead}, claims.Scopes)
+	})
+}
+
+func TestJWTVerifier_RS256(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+
+	pemPath := filepath.Join(dir, "public.pem")
+	der, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(pemPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600))
+
+	jwksPath := filepath.Join(dir, "jwks.json")
+	jwks, err := json.Marshal(map[string]any{"keys": []map[string]any{
+		{
+			"kty": "RSA",
+			"kid": "key-1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(privateKey.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(privateKey.E)).Bytes()),
+		},
+		{
+			"kty": "oct",
+			"kid": "key-2",
+			"k":   base64.RawURLEncoding.EncodeToString(testSecret),
+		},
+	}})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(jwksPath, jwks, 0o600))
+
+	public, err := LoadRSAPublicKey(pemPath)
+	require.NoError(t, err)
+	assert.True(t, public.Equal(&privateKey.PublicKey))
+
+	keys, err := LoadJWKS(jwksPath)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+
+	t.Run("PEM key", func(t *testing.T) {
+		verifier := newTestVerifier(t, now, RSAKey("", public))
+
+		token := mintToken(t, map[string]any{"alg": AlgRS256}, validClaims(now), signRS256(t, privateKey))
+		claims, err := verifier.Verify(token)
+		require.NoError(t, err)
+		assert.Equal(t, "user-1", claims.Subject)
+
+		token = mintToken(t, map[string]any{"alg": AlgRS256}, validClaims(now), signRS256(t, otherKey))
+		_, err = verifier.Verify(token)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("JWKS", func(t *testing.T) {
+		verifier := newTestVerifier(t, now, keys...)
+
+		token := mintToken(t, map[string]any{"alg": AlgRS256, "kid": "key-1"}, validClaims(now), signRS256(t, privateKey))
+		_, err := verifier.Verify(token)
+		require.NoError(t, err)
+
+		token = mintToken(t, map[string]any{"alg": AlgHS256, "kid": "key-2"}, validClaims(now), signHS256(testSecret))
+		_, err = verifier.Verify(token)
+		require.NoError(t, err)
+
+		token = mintToken(t, map[string]any{"alg": AlgRS256, "kid": "key-2"}, validClaims(now), signRS256(t, privateKey))
+		_, err = verifier.Verify(token)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("algorithm confusion", func(t *testing.T) {
+		verifier := newTestVerifier(t, now, RSAKey("", public))
+
+		token := mintToken(t, map[string]any{"alg": AlgHS256}, validClaims(now), signHS256(x509.MarshalPKCS1PublicKey(public)))
+		_, err := verifier.Verify(token)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+}
+
+func TestJWTMiddleware(t *testing.T) {
+	now := time.Now()
+	verifier := newTestVerifier(t, now, HMACKey("", testSecret))
+	token := mintToken(t, map[string]any{"alg": AlgHS256}, validClaims(now), signHS256(testSecret))
+
+	noWallets := validClaims(now)
+	delete(noWallets, "wallet_ids")
+	tokenWithoutWallets := mintToken(t, map[string]any{"alg": AlgHS256}, noWallets, signHS256(testSecret))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(JWTMiddleware(verifier))
+	router.GET("/wallets/:walletId", Require(ScopeWalletRead), func(c *gin.Context) {
+		c.String(http.StatusOK, PrincipalFromContext(c).Subject)
+	})
+	router.GET("/admin/wallets/:walletId", Require(ScopeAdmin), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name           string
+		path           string
+		authorization  string
+		expectedStatus int
+	}{
+		{name: "missing token", path: "/wallets/" + walletA, expectedStatus: http.StatusUnauthorized},
+		{name: "invalid token", path: "/wallets/" + walletA, authorization: "Bearer " + token + "x", expectedStatus: http.StatusUnauthorized},
+		{name: "own wallet", path: "/wallets/" + walletA, authorization: "Bearer " + token, expectedStatus: http.StatusOK},
+		{name: "other wallet", path: "/wallets/" + walletB, authorization: "Bearer " + token, expectedStatus: http.StatusForbidden},
+		{name: "no wallets", path: "/wallets/" + walletA, authorization: "Bearer " + tokenWithoutWallets, expectedStatus: http.StatusForbidden},
+		{name: "admin scope", path: "/admin/wallets/" + walletA, authorization: "Bearer " + token, expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", tt.path, nil)
+			require.NoError(t, err)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, "user-1", w.Body.String())
+			}
+		})
+	}
+}
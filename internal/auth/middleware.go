@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/NKV510/wallet-service/internal/models"
+	"github.com/NKV510/wallet-service/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	APIKeyHeader = "X-API-Key"
+
+	apiKeyContextKey    = "apiKey"
+	principalContextKey = "authPrincipal"
+)
+
+// Principal is the authenticated caller, whether it presented an API key or a
+// JWT.
+type Principal struct {
+	// Subject is the key name or the token's "sub" claim.
+	Subject string
+	Scopes  []string
+	// WalletIDs lists the wallets the caller may act on. Unless Restricted
+	// is set, an empty list means any wallet.
+	WalletIDs  []string
+	Restricted bool
+}
+
+// Allows reports whether the principal grants scope on every wallet in
+// walletIDs.
+func (p *Principal) Allows(scope string, walletIDs ...string) bool {
+	if !slices.Contains(p.Scopes, scope) && !slices.Contains(p.Scopes, ScopeAdmin) {
+		return false
+	}
+	if len(p.WalletIDs) == 0 && !p.Restricted {
+		return true
+	}
+	for _, id := range walletIDs {
+		if !slices.Contains(p.WalletIDs, strings.ToLower(id)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Middleware authenticates requests by API key, read from X-API-Key or an
+// "Authorization: Bearer" header, and stores the key in the gin context for
+// Require and Authorize.
+func Middleware(store repository.APIKeyStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		plaintext := c.GetHeader(APIKeyHeader)
+		if plaintext == "" {
+			plaintext, _ = strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		}
+		if plaintext == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "API key is required"})
+			return
+		}
+
+		key, err := store.GetAPIKeyByHash(c.Request.Context(), HashKey(plaintext))
+		if err != nil {
+			if errors.Is(err, repository.ErrAPIKeyNotFound) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid API key"})
+				return
+			}
+			_ = c.Error(err)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "authentication unavailable"})
+			return
+		}
+		if key.Revoked() {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "API key has been revoked"})
+			return
+		}
+
+		c.Set(apiKeyContextKey, key)
+		c.Set(principalContextKey, keyPrincipal(key))
+		c.Next()
+	}
+}
+
+// JWTMiddleware authenticates requests by a JWT in an "Authorization: Bearer"
+// header. The caller may only act on the wallets listed in the token.
+func JWTMiddleware(verifier *JWTVerifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "bearer token is required"})
+			return
+		}
+
+		claims, err := verifier.Verify(token)
+		if err != nil {
+			_ = c.Error(err)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			return
+		}
+
+		c.Set(principalContextKey, &Principal{
+			Subject:    claims.Subject,
+			Scopes:     claims.Scopes,
+			WalletIDs:  claims.WalletIDs,
+			Restricted: true,
+		})
+		c.Next()
+	}
+}
+
+// Require rejects requests whose key lacks scope or may not act on the
+// wallet in the :walletId path parameter.
+func Require(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var walletIDs []string
+		if walletID := c.Param("walletId"); walletID != "" {
+			walletIDs = append(walletIDs, walletID)
+		}
+		if !Authorize(c, scope, walletIDs...) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// Authorize checks scope and wallet access for handlers whose wallet ids
+// come from the request body. On failure it writes 403 and returns false.
+// Requests that did not pass through an authentication middleware, which
+// happens when authentication is disabled, are always allowed.
+func Authorize(c *gin.Context, scope string, walletIDs ...string) bool {
+	principal := PrincipalFromContext(c)
+	if principal == nil || principal.Allows(scope, walletIDs...) {
+		return true
+	}
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API key is not allowed to perform this operation"})
+	return false
+}
+
+// PrincipalFromContext returns the authenticated caller, or nil.
+func PrincipalFromContext(c *gin.Context) *Principal {
+	principal, _ := c.Get(principalContextKey)
+	p, _ := principal.(*Principal)
+	return p
+}
+
+// KeyFromContext returns the authenticated API key, or nil.
+func KeyFromContext(c *gin.Context) *models.APIKey {
+	key, _ := c.Get(apiKeyContextKey)
+	apiKey, _ := key.(*models.APIKey)
+	return apiKey
+}
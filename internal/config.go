@@ -4,7 +4,11 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/NKV510/wallet-service/internal/currency"
+	"github.com/NKV510/wallet-service/internal/models"
 	"github.com/joho/godotenv"
 )
 
@@ -16,6 +20,71 @@ type Config struct {
 	DBName     string
 	ServerPort string
 	MaxDBConns int32
+
+	GRPCEnabled       bool
+	GRPCPort          string
+	GRPCWatchInterval time.Duration
+
+	LogLevel  string
+	LogFormat string
+
+	TracingExporter string
+	TracingFile     string
+
+	MigrateOnStart bool
+	AuthEnabled    bool
+	AuthMode       string
+
+	JWTSecret        string
+	JWTPublicKeyFile string
+	JWTJWKSFile      string
+	JWTIssuer        string
+	JWTAudience      string
+	JWTWalletsClaim  string
+	JWTClockSkew     time.Duration
+
+	IdempotencyKeyTTL          time.Duration
+	IdempotencyCleanupInterval time.Duration
+
+	HoldTTL            time.Duration
+	HoldExpiryInterval time.Duration
+
+	FrozenWalletAllowDeposits bool
+
+	EventsPublisher       string
+	EventsFile            string
+	OutboxRelayInterval   time.Duration
+	OutboxBatchSize       int
+	OutboxRetention       time.Duration
+	OutboxCleanupInterval time.Duration
+
+	WebhooksEnabled         bool
+	WebhookDeliveryInterval time.Duration
+	WebhookBatchSize        int
+	WebhookTimeout          time.Duration
+	WebhookMaxAttempts      int
+	WebhookRetryBaseDelay   time.Duration
+	WebhookRetryMaxDelay    time.Duration
+
+	RateLimitClientRPS   float64
+	RateLimitClientBurst int
+	RateLimitWalletRPS   float64
+	RateLimitWalletBurst int
+
+	WithdrawalLimits models.WithdrawalLimits
+
+	DefaultCurrency string
+
+	ExchangeRatesSource          string
+	ExchangeRatesFile            string
+	ExchangeQuoteTTL             time.Duration
+	ExchangeQuoteCleanupInterval time.Duration
+
+	WalletShardCompactInterval time.Duration
+
+	ReconciliationEnabled     bool
+	ReconciliationInterval    time.Duration
+	ReconciliationAutoCorrect bool
 }
 
 func LoadConfig() (*Config, error) {
@@ -28,6 +97,11 @@ func LoadConfig() (*Config, error) {
 		maxConns = 10
 	}
 
+	defaultCurrency := strings.ToUpper(getEnv("DEFAULT_CURRENCY", "USD"))
+	if _, ok := currency.Lookup(defaultCurrency); !ok {
+		return nil, fmt.Errorf("unsupported DEFAULT_CURRENCY %q", defaultCurrency)
+	}
+
 	return &Config{
 		DBHost:     getEnv("DB_HOST", "localhost"),
 		DBPort:     getEnv("DB_PORT", "5432"),
@@ -36,6 +110,75 @@ func LoadConfig() (*Config, error) {
 		DBName:     getEnv("DB_NAME", "wallet_db"),
 		ServerPort: getEnv("SERVER_PORT", "8080"),
 		MaxDBConns: int32(maxConns),
+
+		GRPCEnabled:       getBoolEnv("GRPC_ENABLED", true),
+		GRPCPort:          getEnv("GRPC_PORT", "9090"),
+		GRPCWatchInterval: getDurationEnv("GRPC_WATCH_INTERVAL", time.Second),
+
+		LogLevel:  getEnv("LOG_LEVEL", "info"),
+		LogFormat: getEnv("LOG_FORMAT", "json"),
+
+		TracingExporter: getEnv("TRACING_EXPORTER", "none"),
+		TracingFile:     getEnv("TRACING_FILE", "traces.jsonl"),
+
+		MigrateOnStart: getBoolEnv("MIGRATE_ON_START", false),
+		AuthEnabled:    getBoolEnv("AUTH_ENABLED", true),
+		AuthMode:       getEnv("AUTH_MODE", "apikey"),
+
+		JWTSecret:        getEnv("JWT_SECRET", ""),
+		JWTPublicKeyFile: getEnv("JWT_PUBLIC_KEY_FILE", ""),
+		JWTJWKSFile:      getEnv("JWT_JWKS_FILE", ""),
+		JWTIssuer:        getEnv("JWT_ISSUER", ""),
+		JWTAudience:      getEnv("JWT_AUDIENCE", ""),
+		JWTWalletsClaim:  getEnv("JWT_WALLETS_CLAIM", "wallet_ids"),
+		JWTClockSkew:     getDurationEnv("JWT_CLOCK_SKEW", time.Minute),
+
+		IdempotencyKeyTTL:          getDurationEnv("IDEMPOTENCY_KEY_TTL", 24*time.Hour),
+		IdempotencyCleanupInterval: getDurationEnv("IDEMPOTENCY_CLEANUP_INTERVAL", time.Hour),
+
+		HoldTTL:            getDurationEnv("HOLD_TTL", 15*time.Minute),
+		HoldExpiryInterval: getDurationEnv("HOLD_EXPIRY_INTERVAL", time.Minute),
+
+		FrozenWalletAllowDeposits: getBoolEnv("FROZEN_WALLET_ALLOW_DEPOSITS", true),
+
+		EventsPublisher:       getEnv("EVENTS_PUBLISHER", "none"),
+		EventsFile:            getEnv("EVENTS_FILE", "events.jsonl"),
+		OutboxRelayInterval:   getDurationEnv("OUTBOX_RELAY_INTERVAL", time.Second),
+		OutboxBatchSize:       int(getInt64Env("OUTBOX_BATCH_SIZE", 100)),
+		OutboxRetention:       getDurationEnv("OUTBOX_RETENTION", 7*24*time.Hour),
+		OutboxCleanupInterval: getDurationEnv("OUTBOX_CLEANUP_INTERVAL", time.Hour),
+
+		WebhooksEnabled:         getBoolEnv("WEBHOOKS_ENABLED", true),
+		WebhookDeliveryInterval: getDurationEnv("WEBHOOK_DELIVERY_INTERVAL", time.Second),
+		WebhookBatchSize:        int(getInt64Env("WEBHOOK_BATCH_SIZE", 20)),
+		WebhookTimeout:          getDurationEnv("WEBHOOK_TIMEOUT", 10*time.Second),
+		WebhookMaxAttempts:      int(getInt64Env("WEBHOOK_MAX_ATTEMPTS", 8)),
+		WebhookRetryBaseDelay:   getDurationEnv("WEBHOOK_RETRY_BASE_DELAY", 30*time.Second),
+		WebhookRetryMaxDelay:    getDurationEnv("WEBHOOK_RETRY_MAX_DELAY", time.Hour),
+
+		RateLimitClientRPS:   getFloat64Env("RATE_LIMIT_CLIENT_RPS", 100),
+		RateLimitClientBurst: int(getInt64Env("RATE_LIMIT_CLIENT_BURST", 200)),
+		RateLimitWalletRPS:   getFloat64Env("RATE_LIMIT_WALLET_RPS", 0),
+		RateLimitWalletBurst: int(getInt64Env("RATE_LIMIT_WALLET_BURST", 40)),
+
+		WithdrawalLimits: models.WithdrawalLimits{
+			PerOperation: getInt64Env("WITHDRAWAL_LIMIT_PER_OPERATION", 0),
+			Daily:        getInt64Env("WITHDRAWAL_LIMIT_DAILY", 0),
+			Monthly:      getInt64Env("WITHDRAWAL_LIMIT_MONTHLY", 0),
+		},
+
+		DefaultCurrency: defaultCurrency,
+
+		ExchangeRatesSource:          getEnv("EXCHANGE_RATES_SOURCE", "db"),
+		ExchangeRatesFile:            getEnv("EXCHANGE_RATES_FILE", "rates.json"),
+		ExchangeQuoteTTL:             getDurationEnv("EXCHANGE_QUOTE_TTL", 30*time.Second),
+		ExchangeQuoteCleanupInterval: getDurationEnv("EXCHANGE_QUOTE_CLEANUP_INTERVAL", time.Hour),
+
+		WalletShardCompactInterval: getDurationEnv("WALLET_SHARD_COMPACT_INTERVAL", time.Minute),
+
+		ReconciliationEnabled:     getBoolEnv("RECONCILIATION_ENABLED", true),
+		ReconciliationInterval:    getDurationEnv("RECONCILIATION_INTERVAL", 24*time.Hour),
+		ReconciliationAutoCorrect: getBoolEnv("RECONCILIATION_AUTO_CORRECT", false),
 	}, nil
 }
 
@@ -45,3 +188,35 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(getEnv(key, ""))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
+func getInt64Env(key string, defaultValue int64) int64 {
+	value, err := strconv.ParseInt(getEnv(key, ""), 10, 64)
+	if err != nil || value < 0 {
+		return defaultValue
+	}
+	return value
+}
+
+func getFloat64Env(key string, defaultValue float64) float64 {
+	value, err := strconv.ParseFloat(getEnv(key, ""), 64)
+	if err != nil || value < 0 {
+		return defaultValue
+	}
+	return value
+}
+
+func getBoolEnv(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(getEnv(key, ""))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,61 @@
+package currency
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		code     string
+		ok       bool
+		exponent int
+	}{
+		{code: "EUR", ok: true, exponent: 2},
+		{code: "JPY", ok: true, exponent: 0},
+		{code: "KWD", ok: true, exponent: 3},
+		{code: "CLF", ok: true, exponent: 4},
+		{code: "eur"},
+		{code: "XAU"},
+		{code: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			c, ok := Lookup(tt.code)
+			require.Equal(t, tt.ok, ok)
+			if ok {
+				assert.Equal(t, tt.code, c.Code)
+				assert.Equal(t, tt.exponent, c.Exponent)
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		code   string
+		amount int64
+		want   string
+	}{
+		{code: "EUR", amount: 123456, want: "1234.56"},
+		{code: "EUR", amount: 5, want: "0.05"},
+		{code: "EUR", amount: 0, want: "0.00"},
+		{code: "EUR", amount: -250, want: "-2.50"},
+		{code: "JPY", amount: 1500, want: "1500"},
+		{code: "KWD", amount: 1, want: "0.001"},
+		{code: "CLF", amount: 123456, want: "12.3456"},
+		{code: "USD", amount: math.MinInt64, want: "-92233720368547758.08"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			c, ok := Lookup(tt.code)
+			require.True(t, ok)
+			assert.Equal(t, tt.want, c.Format(tt.amount))
+		})
+	}
+}
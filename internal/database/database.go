@@ -3,13 +3,17 @@ package database
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
+	"github.com/NKV510/wallet-service/internal/logging"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func NewDBPool(host, port, user, password, dbname string, maxConns int32) (*pgxpool.Pool, error) {
+// NewDBPool connects to Postgres, retrying while the database starts up.
+// tracer, when not nil, is called for every statement run through the pool.
+func NewDBPool(host, port, user, password, dbname string, maxConns int32, tracer pgx.QueryTracer) (*pgxpool.Pool, error) {
 	dbURL := fmt.Sprintf("postgres://%s:%s@%s:%s/%s", user, password, host, port, dbname)
 
 	dbConfig, err := pgxpool.ParseConfig(dbURL)
@@ -18,6 +22,7 @@ func NewDBPool(host, port, user, password, dbname string, maxConns int32) (*pgxp
 	}
 
 	dbConfig.MaxConns = maxConns
+	dbConfig.ConnConfig.Tracer = tracer
 	dbConfig.HealthCheckPeriod = 1 * time.Minute
 	dbConfig.MaxConnLifetime = 1 * time.Hour
 	dbConfig.MaxConnIdleTime = 30 * time.Minute
@@ -29,14 +34,14 @@ func NewDBPool(host, port, user, password, dbname string, maxConns int32) (*pgxp
 	for i := 0; i < maxRetries; i++ {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 
-		log.Printf("Attempt %d/%d: Connecting to database at %s:%s", i+1, maxRetries, host, port)
+		slog.Info("connecting to database", "attempt", i+1, "max_attempts", maxRetries, "host", host, "port", port)
 
 		dbPool, err = pgxpool.NewWithConfig(ctx, dbConfig)
 		if err != nil {
 			cancel()
-			log.Printf("Attempt %d/%d: Failed to connect to database: %v", i+1, maxRetries, err)
+			slog.Warn("failed to connect to database", "attempt", i+1, "max_attempts", maxRetries, "error", err)
 			if i < maxRetries-1 {
-				log.Printf("Retrying in %v...", retryDelay)
+				slog.Info("retrying database connection", "delay", retryDelay)
 				time.Sleep(retryDelay)
 				continue
 			}
@@ -45,10 +50,10 @@ func NewDBPool(host, port, user, password, dbname string, maxConns int32) (*pgxp
 
 		if err := dbPool.Ping(ctx); err != nil {
 			cancel()
-			log.Printf("Attempt %d/%d: Database ping failed: %v", i+1, maxRetries, err)
+			slog.Warn("database ping failed", "attempt", i+1, "max_attempts", maxRetries, "error", err)
 			dbPool.Close()
 			if i < maxRetries-1 {
-				log.Printf("Retrying in %v...", retryDelay)
+				slog.Info("retrying database connection", "delay", retryDelay)
 				time.Sleep(retryDelay)
 				continue
 			}
@@ -56,36 +61,40 @@ func NewDBPool(host, port, user, password, dbname string, maxConns int32) (*pgxp
 		}
 
 		cancel()
-		log.Printf("Successfully connected to database on attempt %d", i+1)
+		slog.Info("connected to database", "attempt", i+1)
 		break
 	}
 
-	if err := checkWalletsTable(context.Background(), dbPool); err != nil {
-		dbPool.Close()
-		return nil, fmt.Errorf("wallets table check failed: %w", err)
-	}
-
 	return dbPool, nil
 }
 
-func checkWalletsTable(ctx context.Context, dbPool *pgxpool.Pool) error {
-	var tableExists bool
+// CheckSchema fails when the schema is behind the embedded migrations. A
+// database without schema_migrations has every migration pending.
+func CheckSchema(ctx context.Context, dbPool *pgxpool.Pool, migrator *Migrator) error {
+	var tracked bool
 	err := dbPool.QueryRow(ctx, `
 		SELECT EXISTS (
-			SELECT FROM information_schema.tables 
-			WHERE table_schema = 'public' 
-			AND table_name = 'wallets'
+			SELECT FROM information_schema.tables
+			WHERE table_schema = 'public'
+			AND table_name = 'schema_migrations'
 		)
-	`).Scan(&tableExists)
-
+	`).Scan(&tracked)
 	if err != nil {
-		return fmt.Errorf("failed to check wallets table: %w", err)
+		return fmt.Errorf("failed to check schema_migrations table: %w", err)
 	}
 
-	if !tableExists {
-		return fmt.Errorf("wallets table does not exist. Please run migrations first")
+	if !tracked {
+		return fmt.Errorf("schema_migrations table does not exist, %d migrations are pending. Run \"migrate up\" or set MIGRATE_ON_START=true", len(migrator.migrations))
+	}
+
+	pending, err := migrator.Pending(ctx)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%d pending migrations, first is %d_%s. Run \"migrate up\" or set MIGRATE_ON_START=true", len(pending), pending[0].Version, pending[0].Name)
 	}
 
-	log.Println("Wallets table exists and is ready")
+	logging.FromContext(ctx).Info("database schema is up to date")
 	return nil
 }
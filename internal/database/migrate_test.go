@@ -0,0 +1,74 @@
+package database
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/NKV510/wallet-service/migrations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	tests := []struct {
+		name          string
+		files         fstest.MapFS
+		wantVersions  []int64
+		errorContains string
+	}{
+		{
+			name: "sorted by version",
+			files: fstest.MapFS{
+				"000002_add_index.up.sql":      {Data: []byte("CREATE INDEX")},
+				"000002_add_index.down.sql":    {Data: []byte("DROP INDEX")},
+				"000001_create_table.up.sql":   {Data: []byte("CREATE TABLE")},
+				"000001_create_table.down.sql": {Data: []byte("DROP TABLE")},
+				"migrations.go":                {Data: []byte("package migrations")},
+			},
+			wantVersions: []int64{1, 2},
+		},
+		{
+			name: "missing down file",
+			files: fstest.MapFS{
+				"000001_create_table.up.sql": {Data: []byte("CREATE TABLE")},
+			},
+			errorContains: "must have both up and down files",
+		},
+		{
+			name: "conflicting names",
+			files: fstest.MapFS{
+				"000001_create_table.up.sql":  {Data: []byte("CREATE TABLE")},
+				"000001_other_table.down.sql": {Data: []byte("DROP TABLE")},
+			},
+			errorContains: "conflicting names",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loaded, err := LoadMigrations(tt.files)
+
+			if tt.errorContains != "" {
+				assert.ErrorContains(t, err, tt.errorContains)
+				return
+			}
+			require.NoError(t, err)
+
+			versions := make([]int64, 0, len(loaded))
+			for _, m := range loaded {
+				versions = append(versions, m.Version)
+			}
+			assert.Equal(t, tt.wantVersions, versions)
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	loaded, err := LoadMigrations(migrations.FS)
+	require.NoError(t, err)
+	require.NotEmpty(t, loaded)
+
+	for i, m := range loaded {
+		assert.Equal(t, int64(i+1), m.Version, "migration versions must be contiguous")
+	}
+}
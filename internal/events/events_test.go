@@ -0,0 +1,118 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NKV510/wallet-service/internal/models"
+	"github.com/NKV510/wallet-service/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const walletID = "123e4567-e89b-12d3-a456-426614174000"
+
+func newTestStore(t *testing.T, deposits int) *repository.MemoryWalletRepository {
+	t.Helper()
+
+	store := repository.NewMemoryWalletRepository()
+	require.NoError(t, store.CreateWallet(context.Background(), walletID))
+	for i := 1; i <= deposits; i++ {
+		require.NoError(t, store.UpdateWalletBalance(context.Background(), walletID, models.DEPOSIT, int64(i)))
+	}
+	return store
+}
+
+type flakyPublisher struct {
+	Publisher
+	failures int
+}
+
+func (p *flakyPublisher) Publish(ctx context.Context, event models.Event) error {
+	if p.failures > 0 {
+		p.failures--
+		return errors.New("broker unavailable")
+	}
+	return p.Publisher.Publish(ctx, event)
+}
+
+func TestRelay_Drain(t *testing.T) {
+	store := newTestStore(t, 5)
+	publisher := NewMemoryPublisher()
+	relay := NewRelay(store, publisher, 2)
+
+	n, err := relay.Drain(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), n)
+
+	published := publisher.Events()
+	require.Len(t, published, 5)
+	for i, event := range published {
+		var entry models.Transaction
+		require.NoError(t, json.Unmarshal(event.Payload, &entry))
+		assert.Equal(t, int64(i+1), entry.Amount, "events are published in order")
+	}
+
+	n, err = relay.Drain(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestRelay_AtLeastOnce(t *testing.T) {
+	store := newTestStore(t, 3)
+	memory := NewMemoryPublisher()
+	publisher := &flakyPublisher{Publisher: memory, failures: 1}
+	relay := NewRelay(store, publisher, 10)
+
+	n, err := relay.Drain(context.Background())
+	assert.Error(t, err)
+	assert.Zero(t, n)
+	assert.Empty(t, memory.Events())
+
+	n, err = relay.Drain(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	assert.Len(t, memory.Events(), 3)
+}
+
+func TestRelay_Run(t *testing.T) {
+	store := newTestStore(t, 0)
+	publisher := NewMemoryPublisher()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		NewRelay(store, publisher, 10).Run(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+
+	require.NoError(t, store.UpdateWalletBalance(context.Background(), walletID, models.DEPOSIT, 100))
+	assert.Eventually(t, func() bool { return len(publisher.Events()) == 1 }, time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+}
+
+func TestWriterPublisher(t *testing.T) {
+	var buf bytes.Buffer
+	publisher := NewWriterPublisher(&buf)
+
+	store := newTestStore(t, 2)
+	n, err := NewRelay(store, publisher, 10).Drain(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	for _, line := range lines {
+		var event models.Event
+		require.NoError(t, json.Unmarshal([]byte(line), &event))
+		assert.Equal(t, models.EventBalanceChanged, event.Type)
+		assert.Equal(t, walletID, event.WalletID)
+	}
+}
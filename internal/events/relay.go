@@ -0,0 +1,61 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/NKV510/wallet-service/internal/logging"
+	"github.com/NKV510/wallet-service/internal/repository"
+)
+
+// Relay moves events from the outbox to a Publisher.
+type Relay struct {
+	store     repository.OutboxStore
+	publisher Publisher
+	batchSize int
+}
+
+func NewRelay(store repository.OutboxStore, publisher Publisher, batchSize int) *Relay {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	return &Relay{store: store, publisher: publisher, batchSize: batchSize}
+}
+
+// Drain publishes batches until the outbox is empty or publishing fails, and
+// returns the number of events published.
+func (r *Relay) Drain(ctx context.Context) (int64, error) {
+	var total int64
+	for {
+		n, err := r.store.RelayOutbox(ctx, r.batchSize, r.publisher.Publish)
+		total += n
+		if err != nil || n < int64(r.batchSize) || ctx.Err() != nil {
+			return total, err
+		}
+	}
+}
+
+// Run drains the outbox every interval until ctx is cancelled. A failed
+// batch is logged and retried on the next tick.
+func (r *Relay) Run(ctx context.Context, interval time.Duration) {
+	logger := logging.FromContext(ctx).With("job", "outbox relay")
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := r.Drain(ctx)
+			if err != nil {
+				logger.Error("background job failed", "error", err, "published", n)
+				continue
+			}
+			if n > 0 {
+				logger.Debug("background job finished", "result", "events published", "count", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package exchange
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/NKV510/wallet-service/internal/currency"
+	"github.com/NKV510/wallet-service/internal/repository"
+)
+
+// ParseRate parses a positive plain decimal such as "1.0845". Fractions,
+// exponents and signs are rejected so that stored rates stay readable.
+func ParseRate(s string) (*big.Rat, error) {
+	if s == "" {
+		return nil, fmt.Errorf("%w: rate is required", repository.ErrInvalidOperation)
+	}
+	point := false
+	for i, ch := range s {
+		switch {
+		case ch >= '0' && ch <= '9':
+		case ch == '.' && !point && i > 0 && i < len(s)-1:
+			point = true
+		default:
+			return nil, fmt.Errorf("%w: invalid rate %q", repository.ErrInvalidOperation, s)
+		}
+	}
+
+	rate, ok := new(big.Rat).SetString(s)
+	if !ok || rate.Sign() <= 0 {
+		return nil, fmt.Errorf("%w: rate must be positive", repository.ErrInvalidOperation)
+	}
+	return rate, nil
+}
+
+// Convert turns amount minor units of from into minor units of to at rate,
+// rounding half to even so that repeated conversions carry no bias.
+func Convert(amount int64, from, to currency.Currency, rate string) (int64, error) {
+	if amount <= 0 {
+		return 0, fmt.Errorf("%w: amount must be positive", repository.ErrInvalidOperation)
+	}
+	r, err := ParseRate(rate)
+	if err != nil {
+		return 0, err
+	}
+
+	// amount / 10^from.Exponent major units, times the rate, times
+	// 10^to.Exponent minor units of the target currency.
+	num := new(big.Int).Mul(big.NewInt(amount), r.Num())
+	num.Mul(num, pow10(to.Exponent))
+	den := new(big.Int).Mul(r.Denom(), pow10(from.Exponent))
+
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	switch rem.Lsh(rem, 1).Cmp(den) {
+	case 1:
+		quo.Add(quo, big.NewInt(1))
+	case 0:
+		if quo.Bit(0) == 1 {
+			quo.Add(quo, big.NewInt(1))
+		}
+	}
+
+	if !quo.IsInt64() {
+		return 0, fmt.Errorf("%w: converted amount is too large", repository.ErrInvalidOperation)
+	}
+	return quo.Int64(), nil
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
@@ -0,0 +1,142 @@
+// Package exchange converts money between wallets of different currencies
+// at rates from a RateProvider, optionally locked in advance with a quote.
+package exchange
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NKV510/wallet-service/internal/currency"
+	"github.com/NKV510/wallet-service/internal/models"
+	"github.com/NKV510/wallet-service/internal/repository"
+)
+
+// Store is what the Exchanger needs from the repository.
+type Store interface {
+	repository.ExchangeStore
+	GetWallet(ctx context.Context, walletID string) (*models.Wallet, error)
+}
+
+type Exchanger struct {
+	store    Store
+	rates    RateProvider
+	quoteTTL time.Duration
+}
+
+func New(store Store, rates RateProvider, quoteTTL time.Duration) *Exchanger {
+	return &Exchanger{store: store, rates: rates, quoteTTL: quoteTTL}
+}
+
+func (e *Exchanger) Rates(ctx context.Context) ([]models.ExchangeRate, error) {
+	return e.rates.Rates(ctx)
+}
+
+// Quote locks the current rate for the pair for the quote TTL. With an
+// amount, the quote also shows what it converts to at that rate.
+func (e *Exchanger) Quote(ctx context.Context, request models.ExchangeQuoteRequest) (*models.ExchangeQuote, error) {
+	from := strings.ToUpper(request.FromCurrency)
+	to := strings.ToUpper(request.ToCurrency)
+	if err := ValidatePair(from, to); err != nil {
+		return nil, err
+	}
+	if request.Amount < 0 {
+		return nil, fmt.Errorf("%w: amount must be positive", repository.ErrInvalidOperation)
+	}
+
+	rate, err := e.rates.Rate(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var converted int64
+	if request.Amount > 0 {
+		converted, err = convert(request.Amount, from, to, rate)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	quote, err := e.store.CreateExchangeQuote(ctx, models.ExchangeQuote{
+		FromCurrency: from,
+		ToCurrency:   to,
+		Rate:         rate,
+	}, e.quoteTTL)
+	if err != nil {
+		return nil, err
+	}
+	quote.Amount = request.Amount
+	quote.ConvertedAmount = converted
+	return quote, nil
+}
+
+// Exchange debits request.Amount from the source wallet and credits the
+// converted amount to the destination wallet, at the quoted rate when a
+// quote is given and at the current rate otherwise.
+func (e *Exchanger) Exchange(ctx context.Context, request models.ExchangeRequest) (*models.ExchangeResponse, error) {
+	if request.Amount <= 0 {
+		return nil, fmt.Errorf("%w: amount must be positive", repository.ErrInvalidOperation)
+	}
+
+	from, err := e.store.GetWallet(ctx, request.FromWalletID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := e.store.GetWallet(ctx, request.ToWalletID)
+	if err != nil {
+		return nil, err
+	}
+	if from.Currency == to.Currency {
+		return nil, fmt.Errorf("%w: both wallets hold %s, use a transfer", repository.ErrInvalidOperation, from.Currency)
+	}
+
+	var rate string
+	if request.QuoteID != "" {
+		quote, err := e.store.GetExchangeQuote(ctx, request.QuoteID)
+		if err != nil {
+			return nil, err
+		}
+		if quote.FromCurrency != from.Currency || quote.ToCurrency != to.Currency {
+			return nil, fmt.Errorf("%w: quote is for %s to %s, wallets hold %s and %s",
+				repository.ErrCurrencyMismatch, quote.FromCurrency, quote.ToCurrency, from.Currency, to.Currency)
+		}
+		rate = quote.Rate
+	} else {
+		rate, err = e.rates.Rate(ctx, from.Currency, to.Currency)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	credit, err := convert(request.Amount, from.Currency, to.Currency, rate)
+	if err != nil {
+		return nil, err
+	}
+	if credit == 0 {
+		return nil, fmt.Errorf("%w: amount converts to zero %s", repository.ErrInvalidOperation, to.Currency)
+	}
+
+	return e.store.Exchange(ctx, models.Exchange{
+		FromWalletID: request.FromWalletID,
+		ToWalletID:   request.ToWalletID,
+		FromCurrency: from.Currency,
+		ToCurrency:   to.Currency,
+		Debit:        request.Amount,
+		Credit:       credit,
+		Rate:         rate,
+		QuoteID:      request.QuoteID,
+	})
+}
+
+func convert(amount int64, from, to, rate string) (int64, error) {
+	fromCurrency, ok := currency.Lookup(from)
+	if !ok {
+		return 0, fmt.Errorf("%w: unsupported currency %q", repository.ErrInvalidOperation, from)
+	}
+	toCurrency, ok := currency.Lookup(to)
+	if !ok {
+		return 0, fmt.Errorf("%w: unsupported currency %q", repository.ErrInvalidOperation, to)
+	}
+	return Convert(amount, fromCurrency, toCurrency, rate)
+}
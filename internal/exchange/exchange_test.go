@@ -0,0 +1,211 @@
+package exchange
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NKV510/wallet-service/internal/currency"
+	"github.com/NKV510/wallet-service/internal/models"
+	"github.com/NKV510/wallet-service/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		rate    string
+		wantErr bool
+	}{
+		{"1", false},
+		{"1.0845", false},
+		{"0.0067", false},
+		{"151.2", false},
+		{"", true},
+		{"0", true},
+		{"0.000", true},
+		{"-1.2", true},
+		{"+1.2", true},
+		{"1e3", true},
+		{"1/3", true},
+		{".5", true},
+		{"5.", true},
+		{"1.2.3", true},
+		{"abc", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rate, func(t *testing.T) {
+			_, err := ParseRate(tt.rate)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, repository.ErrInvalidOperation)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestConvert(t *testing.T) {
+	usd, _ := currency.Lookup("USD")
+	eur, _ := currency.Lookup("EUR")
+	jpy, _ := currency.Lookup("JPY")
+	kwd, _ := currency.Lookup("KWD")
+
+	tests := []struct {
+		name   string
+		amount int64
+		from   currency.Currency
+		to     currency.Currency
+		rate   string
+		want   int64
+	}{
+		{"exact", 1000, eur, usd, "1.25", 1250},
+		{"rounds down below half", 1001, eur, usd, "1.0844", 1085},
+		{"rounds up above half", 1003, eur, usd, "1.0845", 1088},
+		{"half rounds to even down", 25, eur, usd, "0.5", 12},
+		{"half rounds to even up", 35, eur, usd, "0.5", 18},
+		{"more minor units", 10000, usd, jpy, "151.2", 15120},
+		{"half yen rounds to even", 1, usd, jpy, "250", 2},
+		{"fewer minor units", 100, jpy, usd, "0.0067", 67},
+		{"three decimals", 100, usd, kwd, "0.3075", 308},
+		{"too small", 1, usd, jpy, "0.004", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Convert(tt.amount, tt.from, tt.to, tt.rate)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := Convert(0, usd, eur, "1")
+	assert.ErrorIs(t, err, repository.ErrInvalidOperation)
+	_, err = Convert(1<<62, eur, jpy, "1000")
+	assert.ErrorIs(t, err, repository.ErrInvalidOperation, "overflow")
+}
+
+func TestStaticRates(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "rates.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[
+		{"from": "usd", "to": "JPY", "rate": "151.2"},
+		{"from": "EUR", "to": "USD", "rate": "1.0845"}
+	]`), 0o600))
+
+	rates, err := LoadStaticRates(path)
+	require.NoError(t, err)
+
+	rate, err := rates.Rate(ctx, "USD", "JPY")
+	require.NoError(t, err)
+	assert.Equal(t, "151.2", rate)
+	_, err = rates.Rate(ctx, "JPY", "USD")
+	assert.ErrorIs(t, err, repository.ErrRateNotFound)
+
+	all, err := rates.Rates(ctx)
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.Equal(t, "EUR", all[0].From)
+
+	invalid := [][]models.ExchangeRate{
+		{{From: "EUR", To: "XXX", Rate: "1"}},
+		{{From: "EUR", To: "EUR", Rate: "1"}},
+		{{From: "EUR", To: "USD", Rate: "-1"}},
+		{{From: "EUR", To: "USD", Rate: "1.1"}, {From: "eur", To: "usd", Rate: "1.2"}},
+	}
+	for _, rates := range invalid {
+		_, err := NewStaticRates(rates)
+		assert.Error(t, err)
+	}
+}
+
+func TestExchanger(t *testing.T) {
+	ctx := context.Background()
+	const (
+		eurWallet = "123e4567-e89b-12d3-a456-426614174000"
+		usdWallet = "223e4567-e89b-12d3-a456-426614174000"
+		eurOther  = "323e4567-e89b-12d3-a456-426614174000"
+	)
+
+	setup := func(t *testing.T) (*Exchanger, *repository.MemoryWalletRepository) {
+		store := repository.NewMemoryWalletRepository()
+		for _, op := range []models.WalletOperation{
+			{WalletID: eurWallet, OperationType: models.DEPOSIT, Amount: 10000, Currency: "EUR"},
+			{WalletID: usdWallet, OperationType: models.DEPOSIT, Amount: 100, Currency: "USD"},
+			{WalletID: eurOther, OperationType: models.DEPOSIT, Amount: 100, Currency: "EUR"},
+		} {
+			_, _, err := store.ProcessOperation(ctx, op)
+			require.NoError(t, err)
+		}
+		_, err := store.SetExchangeRate(ctx, models.ExchangeRate{From: "EUR", To: "USD", Rate: "1.0845"})
+		require.NoError(t, err)
+		return New(store, NewStoreRates(store), time.Minute), store
+	}
+
+	t.Run("at the current rate", func(t *testing.T) {
+		exchanger, _ := setup(t)
+		result, err := exchanger.Exchange(ctx, models.ExchangeRequest{FromWalletID: eurWallet, ToWalletID: usdWallet, Amount: 1003})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1088), result.CreditedAmount)
+		assert.Equal(t, "1.0845", result.Rate)
+		assert.Equal(t, int64(8997), result.FromBalance)
+		assert.Equal(t, int64(1188), result.ToBalance)
+	})
+
+	t.Run("at the quoted rate", func(t *testing.T) {
+		exchanger, store := setup(t)
+		quote, err := exchanger.Quote(ctx, models.ExchangeQuoteRequest{FromCurrency: "eur", ToCurrency: "usd", Amount: 1000})
+		require.NoError(t, err)
+		assert.Equal(t, "EUR", quote.FromCurrency)
+		assert.Equal(t, int64(1084), quote.ConvertedAmount, "1084.5 rounds to even")
+
+		_, err = store.SetExchangeRate(ctx, models.ExchangeRate{From: "EUR", To: "USD", Rate: "2"})
+		require.NoError(t, err)
+
+		result, err := exchanger.Exchange(ctx, models.ExchangeRequest{FromWalletID: eurWallet, ToWalletID: usdWallet, Amount: 1000, QuoteID: quote.ID})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1084), result.CreditedAmount, "the quote locks the rate")
+
+		_, err = exchanger.Exchange(ctx, models.ExchangeRequest{FromWalletID: eurWallet, ToWalletID: usdWallet, Amount: 1000, QuoteID: quote.ID})
+		assert.ErrorIs(t, err, repository.ErrQuoteExpired)
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		exchanger, _ := setup(t)
+		quote, err := exchanger.Quote(ctx, models.ExchangeQuoteRequest{FromCurrency: "EUR", ToCurrency: "USD"})
+		require.NoError(t, err)
+
+		tests := []struct {
+			name    string
+			request models.ExchangeRequest
+			wantErr error
+		}{
+			{"same currency", models.ExchangeRequest{FromWalletID: eurWallet, ToWalletID: eurOther, Amount: 100}, repository.ErrInvalidOperation},
+			{"no rate", models.ExchangeRequest{FromWalletID: usdWallet, ToWalletID: eurWallet, Amount: 100}, repository.ErrRateNotFound},
+			{"quote for another pair", models.ExchangeRequest{FromWalletID: usdWallet, ToWalletID: eurWallet, Amount: 100, QuoteID: quote.ID}, repository.ErrCurrencyMismatch},
+			{"unknown quote", models.ExchangeRequest{FromWalletID: eurWallet, ToWalletID: usdWallet, Amount: 100, QuoteID: "00000000-0000-0000-0000-000000000000"}, repository.ErrQuoteNotFound},
+			{"unknown wallet", models.ExchangeRequest{FromWalletID: eurWallet, ToWalletID: "423e4567-e89b-12d3-a456-426614174000", Amount: 100}, repository.ErrWalletNotFound},
+			{"converts to zero", models.ExchangeRequest{FromWalletID: eurWallet, ToWalletID: usdWallet, Amount: 0}, repository.ErrInvalidOperation},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := exchanger.Exchange(ctx, tt.request)
+				assert.ErrorIs(t, err, tt.wantErr)
+			})
+		}
+	})
+
+	t.Run("quote validation", func(t *testing.T) {
+		exchanger, _ := setup(t)
+		_, err := exchanger.Quote(ctx, models.ExchangeQuoteRequest{FromCurrency: "EUR", ToCurrency: "EUR"})
+		assert.ErrorIs(t, err, repository.ErrInvalidOperation)
+		_, err = exchanger.Quote(ctx, models.ExchangeQuoteRequest{FromCurrency: "EUR", ToCurrency: "XXX"})
+		assert.ErrorIs(t, err, repository.ErrInvalidOperation)
+		_, err = exchanger.Quote(ctx, models.ExchangeQuoteRequest{FromCurrency: "USD", ToCurrency: "EUR"})
+		assert.ErrorIs(t, err, repository.ErrRateNotFound)
+	})
+}
@@ -0,0 +1,123 @@
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/NKV510/wallet-service/internal/currency"
+	"github.com/NKV510/wallet-service/internal/models"
+	"github.com/NKV510/wallet-service/internal/repository"
+)
+
+// RateProvider supplies the rate for converting one currency into another.
+// Missing pairs are reported as repository.ErrRateNotFound.
+type RateProvider interface {
+	Rate(ctx context.Context, from, to string) (string, error)
+	Rates(ctx context.Context) ([]models.ExchangeRate, error)
+}
+
+// StoreRates reads rates from the exchange_rates table, so they can be
+// changed at runtime through the admin API.
+type StoreRates struct {
+	store repository.ExchangeStore
+}
+
+func NewStoreRates(store repository.ExchangeStore) *StoreRates {
+	return &StoreRates{store: store}
+}
+
+func (p *StoreRates) Rate(ctx context.Context, from, to string) (string, error) {
+	rate, err := p.store.GetExchangeRate(ctx, from, to)
+	if err != nil {
+		return "", err
+	}
+	return rate.Rate, nil
+}
+
+func (p *StoreRates) Rates(ctx context.Context) ([]models.ExchangeRate, error) {
+	return p.store.ListExchangeRates(ctx)
+}
+
+// StaticRates is a fixed rate table, usually loaded from a file at startup.
+// Only the listed pairs are available; inverse rates are not derived.
+type StaticRates struct {
+	rates map[string]models.ExchangeRate
+}
+
+// NewStaticRates validates rates and indexes them by pair. A pair listed
+// twice is an error.
+func NewStaticRates(rates []models.ExchangeRate) (*StaticRates, error) {
+	p := &StaticRates{rates: make(map[string]models.ExchangeRate, len(rates))}
+	for _, rate := range rates {
+		rate.From = strings.ToUpper(rate.From)
+		rate.To = strings.ToUpper(rate.To)
+		if err := ValidatePair(rate.From, rate.To); err != nil {
+			return nil, err
+		}
+		if _, err := ParseRate(rate.Rate); err != nil {
+			return nil, fmt.Errorf("%s to %s: %w", rate.From, rate.To, err)
+		}
+
+		key := rate.From + "/" + rate.To
+		if _, ok := p.rates[key]; ok {
+			return nil, fmt.Errorf("duplicate rate %s to %s", rate.From, rate.To)
+		}
+		p.rates[key] = rate
+	}
+	return p, nil
+}
+
+// LoadStaticRates reads a JSON array of rates such as
+// [{"from": "EUR", "to": "USD", "rate": "1.0845"}].
+func LoadStaticRates(path string) (*StaticRates, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rates []models.ExchangeRate
+	if err := json.Unmarshal(data, &rates); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return NewStaticRates(rates)
+}
+
+func (p *StaticRates) Rate(ctx context.Context, from, to string) (string, error) {
+	rate, ok := p.rates[from+"/"+to]
+	if !ok {
+		return "", fmt.Errorf("%w: %s to %s", repository.ErrRateNotFound, from, to)
+	}
+	return rate.Rate, nil
+}
+
+func (p *StaticRates) Rates(ctx context.Context) ([]models.ExchangeRate, error) {
+	rates := make([]models.ExchangeRate, 0, len(p.rates))
+	for _, rate := range p.rates {
+		rates = append(rates, rate)
+	}
+	slices.SortFunc(rates, func(a, b models.ExchangeRate) int {
+		if c := strings.Compare(a.From, b.From); c != 0 {
+			return c
+		}
+		return strings.Compare(a.To, b.To)
+	})
+	return rates, nil
+}
+
+// ValidatePair checks that both codes are known currencies and differ.
+func ValidatePair(from, to string) error {
+	if _, ok := currency.Lookup(from); !ok {
+		return fmt.Errorf("%w: unsupported currency %q", repository.ErrInvalidOperation, from)
+	}
+	if _, ok := currency.Lookup(to); !ok {
+		return fmt.Errorf("%w: unsupported currency %q", repository.ErrInvalidOperation, to)
+	}
+	if from == to {
+		return fmt.Errorf("%w: cannot exchange %s to itself", repository.ErrInvalidOperation, from)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package grpcapi
+
+import (
+	"errors"
+
+	"github.com/NKV510/wallet-service/internal/auth"
+	"github.com/NKV510/wallet-service/internal/repository"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// rpcError carries the status sent to the client together with the error
+// that caused it, so the logging interceptor can record the cause of
+// internal errors whose message is hidden from the client.
+type rpcError struct {
+	status *status.Status
+	cause  error
+}
+
+func (e *rpcError) Error() string              { return e.cause.Error() }
+func (e *rpcError) Unwrap() error              { return e.cause }
+func (e *rpcError) GRPCStatus() *status.Status { return e.status }
+
+func errorCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, repository.ErrWalletNotFound):
+		return codes.NotFound
+	case errors.Is(err, repository.ErrInsufficientFunds),
+		errors.Is(err, repository.ErrWalletNotActive),
+		errors.Is(err, repository.ErrCurrencyMismatch):
+		return codes.FailedPrecondition
+	case errors.Is(err, repository.ErrLimitExceeded):
+		return codes.ResourceExhausted
+	case errors.Is(err, repository.ErrInvalidOperation),
+		errors.Is(err, repository.ErrIdempotencyKeyReused):
+		return codes.InvalidArgument
+	case errors.Is(err, repository.ErrConflict):
+		return codes.Aborted
+	case errors.Is(err, repository.ErrUnavailable):
+		return codes.Unavailable
+	case errors.Is(err, auth.ErrUnauthenticated):
+		return codes.Unauthenticated
+	default:
+		return codes.Internal
+	}
+}
+
+// statusError converts a repository error to a gRPC status error, mirroring
+// the HTTP status mapping of the handlers package.
+func statusError(err error) error {
+	code := errorCode(err)
+	message := err.Error()
+
+	switch code {
+	case codes.Internal:
+		message = "internal server error"
+	case codes.Unavailable:
+		message = "database unavailable"
+	}
+
+	return &rpcError{status: status.New(code, message), cause: err}
+}
@@ -0,0 +1,133 @@
+package grpcapi
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/NKV510/wallet-service/internal/auth"
+	"github.com/NKV510/wallet-service/internal/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const apiKeyMetadata = "x-api-key"
+
+type principalKey struct{}
+
+// unaryLogging logs every call like the HTTP logging middleware and passes
+// a logger carrying the request id to the handler.
+func unaryLogging(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, done := logCall(ctx, logger, info.FullMethod)
+		resp, err := handler(ctx, req)
+		done(err)
+		return resp, err
+	}
+}
+
+func streamLogging(logger *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, done := logCall(ss.Context(), logger, info.FullMethod)
+		err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		done(err)
+		return err
+	}
+}
+
+func logCall(ctx context.Context, logger *slog.Logger, method string) (context.Context, func(error)) {
+	start := time.Now()
+
+	requestID := logging.RequestID(firstMetadata(ctx, strings.ToLower(logging.RequestIDHeader)))
+	_ = grpc.SetHeader(ctx, metadata.Pairs(logging.RequestIDHeader, requestID))
+
+	reqLogger := logger.With("request_id", requestID)
+	ctx = logging.WithLogger(ctx, reqLogger)
+
+	return ctx, func(err error) {
+		code := status.Code(err)
+		attrs := []any{
+			"method", method,
+			"code", code.String(),
+			"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+		}
+		if err != nil {
+			attrs = append(attrs, "error", err.Error())
+		}
+
+		level := slog.LevelInfo
+		switch code {
+		case codes.OK, codes.Canceled:
+		case codes.Internal, codes.Unavailable, codes.Unknown, codes.DataLoss:
+			level = slog.LevelError
+		default:
+			level = slog.LevelWarn
+		}
+		reqLogger.Log(ctx, level, "request completed", attrs...)
+	}
+}
+
+// unaryAuth authenticates calls by the "authorization: Bearer" or
+// "x-api-key" metadata and stores the principal for authorize.
+func unaryAuth(authenticate auth.Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authenticateCall(ctx, authenticate)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func streamAuth(authenticate auth.Authenticator) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticateCall(ss.Context(), authenticate)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func authenticateCall(ctx context.Context, authenticate auth.Authenticator) (context.Context, error) {
+	credential := firstMetadata(ctx, apiKeyMetadata)
+	if credential == "" {
+		credential, _ = strings.CutPrefix(firstMetadata(ctx, "authorization"), "Bearer ")
+	}
+
+	principal, err := authenticate(ctx, credential)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return context.WithValue(ctx, principalKey{}, principal), nil
+}
+
+// authorize checks scope and wallet access like auth.Authorize. Calls that
+// were not authenticated, because authentication is disabled, are allowed.
+func authorize(ctx context.Context, scope, walletID string) error {
+	principal, _ := ctx.Value(principalKey{}).(*auth.Principal)
+	if principal == nil || principal.Allows(scope, walletID) {
+		return nil
+	}
+	return status.Error(codes.PermissionDenied, "caller is not allowed to perform this operation")
+}
+
+func firstMetadata(ctx context.Context, key string) string {
+	if values := metadata.ValueFromIncomingContext(ctx, key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// serverStream overrides the context of a stream for the handler.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,255 @@
+// Package grpcapi serves the wallet.v1.WalletService gRPC API on top of the
+// same store as the HTTP handlers.
+package grpcapi
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	walletv1 "github.com/NKV510/wallet-service/api/wallet/v1"
+	"github.com/NKV510/wallet-service/internal/auth"
+	"github.com/NKV510/wallet-service/internal/currency"
+	"github.com/NKV510/wallet-service/internal/models"
+	"github.com/NKV510/wallet-service/internal/repository"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const maxIdempotencyKeyLength = 255
+
+type Config struct {
+	Logger *slog.Logger
+	// Authenticate checks the caller's credentials; nil disables
+	// authentication.
+	Authenticate auth.Authenticator
+	// WatchInterval is how often WatchBalance polls the store for changes.
+	WatchInterval time.Duration
+}
+
+// Server is a gRPC server with the wallet service registered.
+type Server struct {
+	grpc     *grpc.Server
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func New(store repository.WalletStore, cfg Config) *Server {
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	if cfg.WatchInterval <= 0 {
+		cfg.WatchInterval = time.Second
+	}
+
+	unary := []grpc.UnaryServerInterceptor{unaryLogging(cfg.Logger)}
+	stream := []grpc.StreamServerInterceptor{streamLogging(cfg.Logger)}
+	if cfg.Authenticate != nil {
+		unary = append(unary, unaryAuth(cfg.Authenticate))
+		stream = append(stream, streamAuth(cfg.Authenticate))
+	}
+
+	s := &Server{
+		grpc: grpc.NewServer(grpc.ChainUnaryInterceptor(unary...), grpc.ChainStreamInterceptor(stream...)),
+		stop: make(chan struct{}),
+	}
+	walletv1.RegisterWalletServiceServer(s.grpc, &walletService{
+		store:         store,
+		watchInterval: cfg.WatchInterval,
+		stop:          s.stop,
+	})
+	return s
+}
+
+func (s *Server) Serve(lis net.Listener) error {
+	return s.grpc.Serve(lis)
+}
+
+// Shutdown ends the WatchBalance streams and waits for other calls to
+// finish, cancelling them if ctx expires first.
+func (s *Server) Shutdown(ctx context.Context) {
+	s.stopOnce.Do(func() { close(s.stop) })
+
+	stopped := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.grpc.Stop()
+	}
+}
+
+type walletService struct {
+	walletv1.UnimplementedWalletServiceServer
+
+	store         repository.WalletStore
+	watchInterval time.Duration
+	stop          <-chan struct{}
+}
+
+func (s *walletService) Deposit(ctx context.Context, req *walletv1.DepositRequest) (*walletv1.OperationResponse, error) {
+	return s.operate(ctx, models.WalletOperation{
+		WalletID:       req.GetWalletId(),
+		OperationType:  models.DEPOSIT,
+		Amount:         req.GetAmount(),
+		Currency:       req.GetCurrency(),
+		IdempotencyKey: req.GetIdempotencyKey(),
+	})
+}
+
+func (s *walletService) Withdraw(ctx context.Context, req *walletv1.WithdrawRequest) (*walletv1.OperationResponse, error) {
+	return s.operate(ctx, models.WalletOperation{
+		WalletID:       req.GetWalletId(),
+		OperationType:  models.WITHDRAW,
+		Amount:         req.GetAmount(),
+		Currency:       req.GetCurrency(),
+		IdempotencyKey: req.GetIdempotencyKey(),
+	})
+}
+
+// operate follows WalletHandler.ProcessOperation: the wallet is created by
+// its first deposit, the currency is required and the idempotency key is
+// optional.
+func (s *walletService) operate(ctx context.Context, operation models.WalletOperation) (*walletv1.OperationResponse, error) {
+	walletID, err := parseWalletID(operation.WalletID)
+	if err != nil {
+		return nil, err
+	}
+	operation.WalletID = walletID
+	if operation.Amount <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "amount must be positive")
+	}
+	operation.Currency = strings.ToUpper(operation.Currency)
+	if operation.Currency == "" {
+		return nil, status.Error(codes.InvalidArgument, "currency is required")
+	}
+	if _, ok := currency.Lookup(operation.Currency); !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported currency %q", operation.Currency)
+	}
+	if len(operation.IdempotencyKey) > maxIdempotencyKeyLength {
+		return nil, status.Errorf(codes.InvalidArgument, "idempotency key must be at most %d characters", maxIdempotencyKeyLength)
+	}
+
+	scope := auth.ScopeWalletDeposit
+	if operation.OperationType == models.WITHDRAW {
+		scope = auth.ScopeWalletWithdraw
+	}
+	if err := authorize(ctx, scope, operation.WalletID); err != nil {
+		return nil, err
+	}
+
+	newBalance, replayed, err := s.store.ProcessOperation(ctx, operation)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	walletCurrency, _ := currency.Lookup(operation.Currency)
+	return &walletv1.OperationResponse{
+		IdempotentReplayed: replayed,
+		Balance: &walletv1.Balance{
+			WalletId:         operation.WalletID,
+			Balance:          newBalance,
+			Currency:         operation.Currency,
+			FormattedBalance: walletCurrency.Format(newBalance),
+		},
+	}, nil
+}
+
+func (s *walletService) GetBalance(ctx context.Context, req *walletv1.GetBalanceRequest) (*walletv1.Balance, error) {
+	walletID, err := parseWalletID(req.GetWalletId())
+	if err != nil {
+		return nil, err
+	}
+	if err := authorize(ctx, auth.ScopeWalletRead, walletID); err != nil {
+		return nil, err
+	}
+
+	wallet, err := s.store.GetWallet(ctx, walletID)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return balance(wallet), nil
+}
+
+// WatchBalance polls the store rather than listening to the outbox, so that
+// every instance can serve watchers regardless of which one relays events.
+func (s *walletService) WatchBalance(req *walletv1.WatchBalanceRequest, stream walletv1.WalletService_WatchBalanceServer) error {
+	ctx := stream.Context()
+	walletID, err := parseWalletID(req.GetWalletId())
+	if err != nil {
+		return err
+	}
+	if err := authorize(ctx, auth.ScopeWalletRead, walletID); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(s.watchInterval)
+	defer ticker.Stop()
+
+	var last *models.Wallet
+	for {
+		wallet, err := s.store.GetWallet(ctx, walletID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return status.FromContextError(ctx.Err()).Err()
+			}
+			return statusError(err)
+		}
+		if last == nil || changed(last, wallet) {
+			if err := stream.Send(balance(wallet)); err != nil {
+				return fmt.Errorf("failed to send balance: %w", err)
+			}
+			last = wallet
+		}
+
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-s.stop:
+			return status.Error(codes.Unavailable, "server is shutting down")
+		case <-ticker.C:
+		}
+	}
+}
+
+// parseWalletID checks a wallet ID like the HTTP handlers do and returns it
+// in the lower-case form the store expects.
+func parseWalletID(id string) (string, error) {
+	if id == "" {
+		return "", status.Error(codes.InvalidArgument, "wallet ID is required")
+	}
+	normalized, ok := models.NormalizeID(id)
+	if !ok {
+		return "", status.Error(codes.InvalidArgument, "wallet ID must be a UUID")
+	}
+	return normalized, nil
+}
+
+func changed(a, b *models.Wallet) bool {
+	return a.Balance != b.Balance || a.Held != b.Held || a.Status != b.Status || a.StatusReason != b.StatusReason
+}
+
+func balance(wallet *models.Wallet) *walletv1.Balance {
+	walletCurrency, _ := currency.Lookup(wallet.Currency)
+	return &walletv1.Balance{
+		WalletId:           wallet.ID,
+		Balance:            wallet.Balance,
+		Available:          wallet.Available(),
+		Status:             string(wallet.Status),
+		StatusReason:       wallet.StatusReason,
+		UpdatedAt:          timestamppb.New(wallet.UpdatedAt),
+		Currency:           wallet.Currency,
+		FormattedBalance:   walletCurrency.Format(wallet.Balance),
+		FormattedAvailable: walletCurrency.Format(wallet.Available()),
+	}
+}
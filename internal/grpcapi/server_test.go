@@ -0,0 +1,280 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	walletv1 "github.com/NKV510/wallet-service/api/wallet/v1"
+	"github.com/NKV510/wallet-service/internal/auth"
+	"github.com/NKV510/wallet-service/internal/models"
+	"github.com/NKV510/wallet-service/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const (
+	walletID = "123e4567-e89b-12d3-a456-426614174000"
+	otherID  = "223e4567-e89b-12d3-a456-426614174000"
+)
+
+func newTestClient(t *testing.T, store repository.WalletStore, cfg Config) walletv1.WalletServiceClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	server := New(store, cfg)
+	go server.Serve(lis)
+	t.Cleanup(func() { server.Shutdown(context.Background()) })
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return walletv1.NewWalletServiceClient(conn)
+}
+
+func TestWalletService_Operations(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t, repository.NewMemoryWalletRepository(), Config{})
+
+	resp, err := client.Deposit(ctx, &walletv1.DepositRequest{WalletId: walletID, Amount: 1000, Currency: "USD"})
+	require.NoError(t, err)
+	assert.False(t, resp.GetIdempotentReplayed())
+	assert.Equal(t, int64(1000), resp.GetBalance().GetBalance())
+	assert.Equal(t, walletID, resp.GetBalance().GetWalletId())
+	assert.Equal(t, "USD", resp.GetBalance().GetCurrency())
+	assert.Equal(t, "10.00", resp.GetBalance().GetFormattedBalance())
+
+	resp, err = client.Withdraw(ctx, &walletv1.WithdrawRequest{WalletId: walletID, Amount: 300, IdempotencyKey: "w-1", Currency: "USD"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(700), resp.GetBalance().GetBalance())
+
+	_, err = client.Deposit(ctx, &walletv1.DepositRequest{WalletId: walletID, Amount: 50, Currency: "USD"})
+	require.NoError(t, err)
+
+	resp, err = client.Withdraw(ctx, &walletv1.WithdrawRequest{WalletId: walletID, Amount: 300, IdempotencyKey: "w-1", Currency: "USD"})
+	require.NoError(t, err)
+	assert.True(t, resp.GetIdempotentReplayed())
+	assert.Equal(t, int64(700), resp.GetBalance().GetBalance(), "a replay returns the balance of the original operation")
+
+	balance, err := client.GetBalance(ctx, &walletv1.GetBalanceRequest{WalletId: walletID})
+	require.NoError(t, err)
+	assert.Equal(t, walletID, balance.GetWalletId())
+	assert.Equal(t, int64(750), balance.GetBalance())
+	assert.Equal(t, int64(750), balance.GetAvailable())
+	assert.Equal(t, "USD", balance.GetCurrency())
+	assert.Equal(t, "7.50", balance.GetFormattedBalance())
+	assert.False(t, balance.GetUpdatedAt().AsTime().IsZero())
+}
+
+func TestWalletService_Errors(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryWalletRepository()
+	client := newTestClient(t, store, Config{})
+
+	_, err := client.Deposit(ctx, &walletv1.DepositRequest{WalletId: walletID, Amount: 100, Currency: "USD"})
+	require.NoError(t, err)
+	require.NoError(t, store.CreateWallet(ctx, otherID))
+	_, err = store.SetWalletStatus(ctx, otherID, models.WalletFrozen, "review")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		call func() error
+		want codes.Code
+	}{
+		{
+			name: "missing wallet id",
+			call: func() error {
+				_, err := client.Deposit(ctx, &walletv1.DepositRequest{Amount: 100, Currency: "USD"})
+				return err
+			},
+			want: codes.InvalidArgument,
+		},
+		{
+			name: "wallet id is not a UUID",
+			call: func() error {
+				_, err := client.Deposit(ctx, &walletv1.DepositRequest{WalletId: "wallet-1", Amount: 100, Currency: "USD"})
+				return err
+			},
+			want: codes.InvalidArgument,
+		},
+		{
+			name: "non-positive amount",
+			call: func() error {
+				_, err := client.Withdraw(ctx, &walletv1.WithdrawRequest{WalletId: walletID, Currency: "USD"})
+				return err
+			},
+			want: codes.InvalidArgument,
+		},
+		{
+			name: "missing currency",
+			call: func() error {
+				_, err := client.Deposit(ctx, &walletv1.DepositRequest{WalletId: walletID, Amount: 100})
+				return err
+			},
+			want: codes.InvalidArgument,
+		},
+		{
+			name: "currency mismatch",
+			call: func() error {
+				_, err := client.Deposit(ctx, &walletv1.DepositRequest{WalletId: walletID, Amount: 100, Currency: "EUR"})
+				return err
+			},
+			want: codes.FailedPrecondition,
+		},
+		{
+			name: "insufficient funds",
+			call: func() error {
+				_, err := client.Withdraw(ctx, &walletv1.WithdrawRequest{WalletId: walletID, Amount: 101, Currency: "USD"})
+				return err
+			},
+			want: codes.FailedPrecondition,
+		},
+		{
+			name: "frozen wallet",
+			call: func() error {
+				_, err := client.Withdraw(ctx, &walletv1.WithdrawRequest{WalletId: otherID, Amount: 1, Currency: "USD"})
+				return err
+			},
+			want: codes.FailedPrecondition,
+		},
+		{
+			name: "unknown wallet",
+			call: func() error {
+				_, err := client.GetBalance(ctx, &walletv1.GetBalanceRequest{WalletId: "323e4567-e89b-12d3-a456-426614174000"})
+				return err
+			},
+			want: codes.NotFound,
+		},
+		{
+			name: "idempotency key reused",
+			call: func() error {
+				if _, err := client.Deposit(ctx, &walletv1.DepositRequest{WalletId: walletID, Amount: 1, IdempotencyKey: "k", Currency: "USD"}); err != nil {
+					return err
+				}
+				_, err := client.Deposit(ctx, &walletv1.DepositRequest{WalletId: walletID, Amount: 2, IdempotencyKey: "k", Currency: "USD"})
+				return err
+			},
+			want: codes.InvalidArgument,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, status.Code(tt.call()))
+		})
+	}
+}
+
+func TestWalletService_WatchBalance(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	store := repository.NewMemoryWalletRepository()
+	client := newTestClient(t, store, Config{WatchInterval: 10 * time.Millisecond})
+
+	_, err := client.Deposit(ctx, &walletv1.DepositRequest{WalletId: walletID, Amount: 100, Currency: "USD"})
+	require.NoError(t, err)
+
+	stream, err := client.WatchBalance(ctx, &walletv1.WatchBalanceRequest{WalletId: walletID})
+	require.NoError(t, err)
+
+	update, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), update.GetBalance(), "the current balance is sent first")
+
+	_, err = client.Withdraw(ctx, &walletv1.WithdrawRequest{WalletId: walletID, Amount: 40, Currency: "USD"})
+	require.NoError(t, err)
+	update, err = stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, int64(60), update.GetBalance())
+
+	_, err = store.SetWalletStatus(ctx, walletID, models.WalletFrozen, "review")
+	require.NoError(t, err)
+	update, err = stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, string(models.WalletFrozen), update.GetStatus())
+	assert.Equal(t, "review", update.GetStatusReason())
+
+	missing, err := client.WatchBalance(ctx, &walletv1.WatchBalanceRequest{WalletId: otherID})
+	require.NoError(t, err)
+	_, err = missing.Recv()
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestWalletService_WatchBalance_Shutdown(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	store := repository.NewMemoryWalletRepository()
+	require.NoError(t, store.CreateWallet(ctx, walletID))
+
+	lis := bufconn.Listen(1 << 20)
+	server := New(store, Config{WatchInterval: 10 * time.Millisecond})
+	go server.Serve(lis)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	stream, err := walletv1.NewWalletServiceClient(conn).WatchBalance(ctx, &walletv1.WatchBalanceRequest{WalletId: walletID})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.NoError(t, err)
+
+	server.Shutdown(ctx)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+func TestWalletService_Auth(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryWalletRepository()
+	client := newTestClient(t, store, Config{Authenticate: auth.APIKeyAuthenticator(store)})
+
+	restricted, err := auth.CreateKey(ctx, store, "payments", []string{auth.ScopeWalletRead, auth.ScopeWalletDeposit}, []string{walletID})
+	require.NoError(t, err)
+	admin, err := auth.CreateKey(ctx, store, "admin", []string{auth.ScopeAdmin}, nil)
+	require.NoError(t, err)
+
+	withKey := func(key string) context.Context {
+		return metadata.AppendToOutgoingContext(ctx, "x-api-key", key)
+	}
+
+	_, err = client.Deposit(ctx, &walletv1.DepositRequest{WalletId: walletID, Amount: 100, Currency: "USD"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.Deposit(withKey("wsk_invalid"), &walletv1.DepositRequest{WalletId: walletID, Amount: 100, Currency: "USD"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.Deposit(withKey(restricted.Key), &walletv1.DepositRequest{WalletId: walletID, Amount: 100, Currency: "USD"})
+	require.NoError(t, err)
+
+	_, err = client.Withdraw(withKey(restricted.Key), &walletv1.WithdrawRequest{WalletId: walletID, Amount: 10, Currency: "USD"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err), "key lacks the withdraw scope")
+
+	_, err = client.GetBalance(withKey(restricted.Key), &walletv1.GetBalanceRequest{WalletId: otherID})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err), "key is restricted to another wallet")
+
+	bearer := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+admin.Key)
+	_, err = client.Withdraw(bearer, &walletv1.WithdrawRequest{WalletId: walletID, Amount: 10, Currency: "USD"})
+	require.NoError(t, err)
+
+	stream, err := client.WatchBalance(ctx, &walletv1.WatchBalanceRequest{WalletId: walletID})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "streams are authenticated too")
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/NKV510/wallet-service/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, repository.ErrWalletNotFound),
+		errors.Is(err, repository.ErrHoldNotFound),
+		errors.Is(err, repository.ErrAPIKeyNotFound),
+		errors.Is(err, repository.ErrWebhookNotFound),
+		errors.Is(err, repository.ErrDeliveryNotFound),
+		errors.Is(err, repository.ErrQuoteNotFound),
+		errors.Is(err, repository.ErrJournalNotFound),
+		errors.Is(err, repository.ErrNoReconciliation):
+		return http.StatusNotFound
+	case errors.Is(err, repository.ErrConflict),
+		errors.Is(err, repository.ErrWalletNotActive):
+		return http.StatusConflict
+	case errors.Is(err, repository.ErrInsufficientFunds),
+		errors.Is(err, repository.ErrInvalidOperation),
+		errors.Is(err, repository.ErrIdempotencyKeyReused),
+		errors.Is(err, repository.ErrLimitExceeded),
+		errors.Is(err, repository.ErrCurrencyMismatch),
+		errors.Is(err, repository.ErrRateNotFound),
+		errors.Is(err, repository.ErrQuoteExpired):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, repository.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// errorMessage returns the text shown to clients for a repository error:
+// the error itself for client errors and a generic message for server errors.
+func errorMessage(err error) string {
+	switch errorStatus(err) {
+	case http.StatusInternalServerError:
+		return "internal server error"
+	case http.StatusServiceUnavailable:
+		return "database unavailable"
+	default:
+		return err.Error()
+	}
+}
+
+// respondError writes the status for a repository error. Client errors carry
+// the error text, plus the remaining allowance for limit errors; server errors
+// get a generic message and the details are attached to the gin context for
+// logging.
+func respondError(c *gin.Context, err error) {
+	status := errorStatus(err)
+	message := errorMessage(err)
+
+	_ = c.Error(err)
+
+	var limitErr *repository.LimitError
+	if errors.As(err, &limitErr) {
+		c.JSON(status, gin.H{"error": message, "limit": limitErr.Limit, "remaining": limitErr.Remaining})
+		return
+	}
+	c.JSON(status, gin.H{"error": message})
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/NKV510/wallet-service/internal/auth"
+	"github.com/NKV510/wallet-service/internal/exchange"
+	"github.com/NKV510/wallet-service/internal/logging"
+	"github.com/NKV510/wallet-service/internal/models"
+	"github.com/NKV510/wallet-service/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+type ExchangeHandler struct {
+	exchanger *exchange.Exchanger
+	store     repository.ExchangeStore
+}
+
+func NewExchangeHandler(exchanger *exchange.Exchanger, store repository.ExchangeStore) *ExchangeHandler {
+	return &ExchangeHandler{exchanger: exchanger, store: store}
+}
+
+func (h *ExchangeHandler) CreateQuote(c *gin.Context) {
+	var request models.ExchangeQuoteRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if request.FromCurrency == "" || request.ToCurrency == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "source and destination currencies are required"})
+		return
+	}
+	if request.Amount < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount must be positive"})
+		return
+	}
+
+	quote, err := h.exchanger.Quote(c.Request.Context(), request)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, quote)
+}
+
+func (h *ExchangeHandler) Exchange(c *gin.Context) {
+	var request models.ExchangeRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if request.FromWalletID == "" || request.ToWalletID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "source and destination wallet IDs are required"})
+		return
+	}
+	if !normalizeIDs(&request.FromWalletID, &request.ToWalletID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "wallet IDs must be UUIDs"})
+		return
+	}
+	if request.QuoteID != "" && !normalizeIDs(&request.QuoteID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "quote ID must be a UUID"})
+		return
+	}
+	c.Set(logging.WalletIDKey, request.FromWalletID)
+
+	if request.Amount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount must be positive"})
+		return
+	}
+
+	if !auth.Authorize(c, auth.ScopeWalletWithdraw, request.FromWalletID) {
+		return
+	}
+
+	result, err := h.exchanger.Exchange(c.Request.Context(), request)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func (h *ExchangeHandler) ListRates(c *gin.Context) {
+	rates, err := h.exchanger.Rates(c.Request.Context())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rates": rates})
+}
+
+func (h *ExchangeHandler) SetRate(c *gin.Context) {
+	var request models.ExchangeRateRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	rate := models.ExchangeRate{
+		From: strings.ToUpper(c.Param("from")),
+		To:   strings.ToUpper(c.Param("to")),
+		Rate: request.Rate,
+	}
+	if err := exchange.ValidatePair(rate.From, rate.To); err != nil {
+		respondError(c, err)
+		return
+	}
+	if _, err := exchange.ParseRate(rate.Rate); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	stored, err := h.store.SetExchangeRate(c.Request.Context(), rate)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, stored)
+}
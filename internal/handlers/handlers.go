@@ -3,17 +3,36 @@ package handlers
 import (
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/NKV510/wallet-service/internal/auth"
+	"github.com/NKV510/wallet-service/internal/currency"
+	"github.com/NKV510/wallet-service/internal/logging"
 	"github.com/NKV510/wallet-service/internal/models"
 	"github.com/NKV510/wallet-service/internal/repository"
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	defaultTransactionsLimit = 50
+	maxTransactionsLimit     = 100
+
+	idempotencyKeyHeader    = "Idempotency-Key"
+	idempotentReplayHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength = 255
+
+	maxHoldTTL = 30 * 24 * time.Hour
+
+	maxBatchOperations = 1000
+)
+
 type WalletHandler struct {
-	repo *repository.WalletRepository
+	repo repository.WalletStore
 }
 
-func NewWalletHandler(repo *repository.WalletRepository) *WalletHandler {
+func NewWalletHandler(repo repository.WalletStore) *WalletHandler {
 	return &WalletHandler{repo: repo}
 }
 
@@ -28,6 +47,11 @@ func (h *WalletHandler) ProcessOperation(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "wallet ID is required"})
 		return
 	}
+	if !normalizeIDs(&operation.WalletID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "wallet ID must be a UUID"})
+		return
+	}
+	c.Set(logging.WalletIDKey, operation.WalletID)
 
 	if operation.Amount <= 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "amount must be positive"})
@@ -39,48 +63,474 @@ func (h *WalletHandler) ProcessOperation(c *gin.Context) {
 		return
 	}
 
-	existingWallet, err := h.repo.GetWallet(c.Request.Context(), operation.WalletID)
-	if err != nil && err.Error() != "wallet not found" {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to get wallet: %v", err)})
+	operation.Currency = strings.ToUpper(operation.Currency)
+	if message := validateCurrency(operation.Currency); message != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
 		return
 	}
 
-	if existingWallet == nil {
-		if err := h.repo.CreateWallet(c.Request.Context(), operation.WalletID); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to create wallet: %v", err)})
+	scope := auth.ScopeWalletDeposit
+	if operation.OperationType == models.WITHDRAW {
+		scope = auth.ScopeWalletWithdraw
+	}
+	if !auth.Authorize(c, scope, operation.WalletID) {
+		return
+	}
+
+	if key := c.GetHeader(idempotencyKeyHeader); key != "" {
+		operation.IdempotencyKey = key
+	}
+	if len(operation.IdempotencyKey) > maxIdempotencyKeyLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("idempotency key must be at most %d characters", maxIdempotencyKeyLength)})
+		return
+	}
+
+	balance, replayed, err := h.repo.ProcessOperation(c.Request.Context(), operation)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if replayed {
+		c.Header(idempotentReplayHeader, "true")
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "balance": balance})
+}
+
+// ProcessBatch applies a list of deposits and withdrawals. A failed atomic
+// batch is answered with the status of the failed operation; an independent
+// batch is always answered with 200 and the outcome of each operation.
+func (h *WalletHandler) ProcessBatch(c *gin.Context) {
+	var request models.BatchRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if request.Mode == "" {
+		request.Mode = models.BatchAtomic
+	}
+	if request.Mode != models.BatchAtomic && request.Mode != models.BatchIndependent {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be atomic or independent"})
+		return
+	}
+	if len(request.Operations) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "operations are required"})
+		return
+	}
+	if len(request.Operations) > maxBatchOperations {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("a batch can have at most %d operations", maxBatchOperations)})
+		return
+	}
+
+	var depositWallets, withdrawWallets []string
+	keys := make(map[string]bool)
+	for i := range request.Operations {
+		operation := &request.Operations[i]
+		operation.Currency = strings.ToUpper(operation.Currency)
+		if message := validateBatchOperation(operation); message != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("operations[%d]: %s", i, message)})
 			return
 		}
+		if key := operation.IdempotencyKey; key != "" {
+			if keys[key] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("operations[%d]: duplicate idempotency key", i)})
+				return
+			}
+			keys[key] = true
+		}
+
+		if operation.OperationType == models.DEPOSIT {
+			depositWallets = append(depositWallets, operation.WalletID)
+		} else {
+			withdrawWallets = append(withdrawWallets, operation.WalletID)
+		}
+	}
+	if len(depositWallets) > 0 && !auth.Authorize(c, auth.ScopeWalletDeposit, depositWallets...) {
+		return
+	}
+	if len(withdrawWallets) > 0 && !auth.Authorize(c, auth.ScopeWalletWithdraw, withdrawWallets...) {
+		return
+	}
+
+	results, err := h.repo.ApplyBatch(c.Request.Context(), request.Mode, request.Operations)
+	if results == nil {
+		respondError(c, err)
+		return
+	}
+
+	response := models.BatchResponse{Mode: request.Mode, Results: results}
+	for i := range results {
+		switch results[i].Status {
+		case models.BatchItemApplied, models.BatchItemReplayed:
+			response.Applied++
+		case models.BatchItemFailed:
+			response.Failed++
+			results[i].Error = errorMessage(results[i].Err)
+			if errorStatus(results[i].Err) >= http.StatusInternalServerError {
+				_ = c.Error(results[i].Err)
+			}
+		}
 	}
 
-	if err := h.repo.UpdateWalletBalance(c.Request.Context(), operation.WalletID, operation.OperationType, operation.Amount); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err != nil {
+		_ = c.Error(err)
+		response.Error = errorMessage(err)
+		c.JSON(errorStatus(err), response)
 		return
 	}
+	c.JSON(http.StatusOK, response)
+}
 
-	c.JSON(http.StatusOK, gin.H{"status": "success"})
+// validateBatchOperation applies the checks of ProcessOperation to one
+// operation of a batch, normalizing its wallet ID, and returns what is wrong
+// with it.
+func validateBatchOperation(operation *models.WalletOperation) string {
+	switch {
+	case operation.WalletID == "":
+		return "wallet ID is required"
+	case !normalizeIDs(&operation.WalletID):
+		return "wallet ID must be a UUID"
+	case operation.Amount <= 0:
+		return "amount must be positive"
+	case operation.OperationType != models.DEPOSIT && operation.OperationType != models.WITHDRAW:
+		return "invalid operation type"
+	case len(operation.IdempotencyKey) > maxIdempotencyKeyLength:
+		return fmt.Sprintf("idempotency key must be at most %d characters", maxIdempotencyKeyLength)
+	}
+	return validateCurrency(operation.Currency)
+}
+
+// validateCurrency returns what is wrong with the ISO 4217 code of an
+// operation, which the caller has upper-cased.
+func validateCurrency(code string) string {
+	if code == "" {
+		return "currency is required"
+	}
+	if _, ok := currency.Lookup(code); !ok {
+		return fmt.Sprintf("unsupported currency %q", code)
+	}
+	return ""
+}
+
+// normalizeIDs rewrites each of ids in the lower-case form the stores
+// expect. It returns false at the first one that is not a UUID.
+func normalizeIDs(ids ...*string) bool {
+	for _, id := range ids {
+		normalized, ok := models.NormalizeID(*id)
+		if !ok {
+			return false
+		}
+		*id = normalized
+	}
+	return true
+}
+
+// pathID reads the UUID path parameter param, answering 400 with label in
+// the message when it is missing or not a UUID.
+func pathID(c *gin.Context, param, label string) (string, bool) {
+	id := c.Param(param)
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": label + " is required"})
+		return "", false
+	}
+	if !normalizeIDs(&id) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": label + " must be a UUID"})
+		return "", false
+	}
+	return id, true
+}
+
+func (h *WalletHandler) Transfer(c *gin.Context) {
+	var request models.TransferRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if request.FromWalletID == "" || request.ToWalletID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "source and destination wallet IDs are required"})
+		return
+	}
+	if !normalizeIDs(&request.FromWalletID, &request.ToWalletID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "wallet IDs must be UUIDs"})
+		return
+	}
+	c.Set(logging.WalletIDKey, request.FromWalletID)
+
+	if request.Amount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount must be positive"})
+		return
+	}
+
+	if !auth.Authorize(c, auth.ScopeWalletWithdraw, request.FromWalletID) {
+		return
+	}
+
+	result, err := h.repo.Transfer(c.Request.Context(), request.FromWalletID, request.ToWalletID, request.Amount)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
 }
 
 func (h *WalletHandler) GetWalletBalance(c *gin.Context) {
-	walletID := c.Param("walletId")
-	if walletID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Wallet ID is required"})
+	walletID, ok := pathID(c, "walletId", "Wallet ID")
+	if !ok {
 		return
 	}
 
 	wallet, err := h.repo.GetWallet(c.Request.Context(), walletID)
 	if err != nil {
-		if err.Error() == "wallet not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": "wallet not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to get wallet: %v", err)})
+		respondError(c, err)
 		return
 	}
 
+	walletCurrency, _ := currency.Lookup(wallet.Currency)
 	response := models.WalletBalanceResponse{
-		WalletID: wallet.ID,
-		Balance:  wallet.Balance,
+		WalletID:           wallet.ID,
+		Balance:            wallet.Balance,
+		Available:          wallet.Available(),
+		Currency:           wallet.Currency,
+		FormattedBalance:   walletCurrency.Format(wallet.Balance),
+		FormattedAvailable: walletCurrency.Format(wallet.Available()),
+		Status:             wallet.Status,
+		StatusReason:       wallet.StatusReason,
 	}
 
 	c.JSON(http.StatusOK, response)
 }
+
+func (h *WalletHandler) ListTransactions(c *gin.Context) {
+	walletID, ok := pathID(c, "walletId", "Wallet ID")
+	if !ok {
+		return
+	}
+
+	filter := models.TransactionFilter{Limit: defaultTransactionsLimit}
+
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 || n > maxTransactionsLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxTransactionsLimit)})
+			return
+		}
+		filter.Limit = n
+	}
+
+	if opType := models.OperationType(c.Query("type")); opType != "" {
+		if opType != models.DEPOSIT && opType != models.WITHDRAW && opType != models.TRANSFER && opType != models.EXCHANGE && opType != models.ADJUSTMENT && opType != models.OPENING {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid operation type"})
+			return
+		}
+		filter.OperationType = opType
+	}
+
+	bounds := []struct {
+		param string
+		dst   *time.Time
+	}{
+		{"from", &filter.From},
+		{"to", &filter.To},
+	}
+	for _, b := range bounds {
+		value := c.Query(b.param)
+		if value == "" {
+			continue
+		}
+		ts, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s must be an RFC 3339 timestamp", b.param)})
+			return
+		}
+		*b.dst = ts
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		after, err := repository.DecodeTransactionCursor(cursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		filter.After = after
+	}
+
+	if _, err := h.repo.GetWallet(c.Request.Context(), walletID); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	transactions, nextCursor, err := h.repo.ListTransactions(c.Request.Context(), walletID, filter)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.TransactionListResponse{
+		WalletID:     walletID,
+		Transactions: transactions,
+		NextCursor:   nextCursor,
+	})
+}
+
+func (h *WalletHandler) CreateHold(c *gin.Context) {
+	walletID, ok := pathID(c, "walletId", "Wallet ID")
+	if !ok {
+		return
+	}
+
+	var request models.CreateHoldRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if request.Amount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount must be positive"})
+		return
+	}
+
+	// Checked before converting, so that a huge value cannot overflow into an
+	// allowed duration.
+	if request.TTLSeconds < 0 || request.TTLSeconds > int64(maxHoldTTL/time.Second) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("ttlSeconds must be at most %d, or 0 for the default", int64(maxHoldTTL/time.Second))})
+		return
+	}
+	ttl := time.Duration(request.TTLSeconds) * time.Second
+
+	hold, err := h.repo.CreateHold(c.Request.Context(), walletID, request.Amount, ttl)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, hold)
+}
+
+func (h *WalletHandler) CaptureHold(c *gin.Context) {
+	walletID, ok := pathID(c, "walletId", "Wallet ID")
+	if !ok {
+		return
+	}
+	holdID, ok := pathID(c, "holdId", "Hold ID")
+	if !ok {
+		return
+	}
+
+	var request models.CaptureHoldRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+	}
+
+	if request.Amount < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount must be positive"})
+		return
+	}
+
+	hold, err := h.repo.CaptureHold(c.Request.Context(), walletID, holdID, request.Amount)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, hold)
+}
+
+func (h *WalletHandler) VoidHold(c *gin.Context) {
+	walletID, ok := pathID(c, "walletId", "Wallet ID")
+	if !ok {
+		return
+	}
+	holdID, ok := pathID(c, "holdId", "Hold ID")
+	if !ok {
+		return
+	}
+
+	hold, err := h.repo.VoidHold(c.Request.Context(), walletID, holdID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, hold)
+}
+
+func (h *WalletHandler) FreezeWallet(c *gin.Context) {
+	h.setWalletStatus(c, models.WalletFrozen)
+}
+
+func (h *WalletHandler) UnfreezeWallet(c *gin.Context) {
+	h.setWalletStatus(c, models.WalletActive)
+}
+
+func (h *WalletHandler) CloseWallet(c *gin.Context) {
+	h.setWalletStatus(c, models.WalletClosed)
+}
+
+// setWalletStatus handles the admin status endpoints. The request body with
+// the reason is optional.
+func (h *WalletHandler) setWalletStatus(c *gin.Context, status models.WalletStatus) {
+	walletID, ok := pathID(c, "walletId", "Wallet ID")
+	if !ok {
+		return
+	}
+
+	var request models.WalletStatusRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+	}
+
+	wallet, err := h.repo.SetWalletStatus(c.Request.Context(), walletID, status, request.Reason)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, wallet)
+}
+
+func (h *WalletHandler) GetWalletLimits(c *gin.Context) {
+	walletID, ok := pathID(c, "walletId", "Wallet ID")
+	if !ok {
+		return
+	}
+
+	limits, err := h.repo.GetWalletLimits(c.Request.Context(), walletID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, limits)
+}
+
+// SetWalletLimits replaces the wallet's limit override; omitted or null
+// fields fall back to the global defaults.
+func (h *WalletHandler) SetWalletLimits(c *gin.Context) {
+	walletID, ok := pathID(c, "walletId", "Wallet ID")
+	if !ok {
+		return
+	}
+
+	var request models.WithdrawalLimitsOverride
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	limits, err := h.repo.SetWalletLimits(c.Request.Context(), walletID, request)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, limits)
+}
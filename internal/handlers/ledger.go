@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/NKV510/wallet-service/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+type LedgerHandler struct {
+	store repository.LedgerStore
+}
+
+func NewLedgerHandler(store repository.LedgerStore) *LedgerHandler {
+	return &LedgerHandler{store: store}
+}
+
+func (h *LedgerHandler) TrialBalance(c *gin.Context) {
+	balance, err := h.store.TrialBalance(c.Request.Context())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, balance)
+}
+
+func (h *LedgerHandler) GetJournal(c *gin.Context) {
+	journalID, ok := pathID(c, "journalId", "Journal ID")
+	if !ok {
+		return
+	}
+
+	entries, err := h.store.GetJournal(c.Request.Context(), journalID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"journalId": journalID, "entries": entries})
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/NKV510/wallet-service/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+type ReconciliationHandler struct {
+	store repository.ReconciliationStore
+}
+
+func NewReconciliationHandler(store repository.ReconciliationStore) *ReconciliationHandler {
+	return &ReconciliationHandler{store: store}
+}
+
+func (h *ReconciliationHandler) LastReport(c *gin.Context) {
+	report, err := h.store.LastReconciliationReport(c.Request.Context())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
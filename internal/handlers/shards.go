@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/NKV510/wallet-service/internal/models"
+	"github.com/NKV510/wallet-service/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+type ShardHandler struct {
+	store repository.ShardStore
+}
+
+func NewShardHandler(store repository.ShardStore) *ShardHandler {
+	return &ShardHandler{store: store}
+}
+
+func (h *ShardHandler) SetWalletShards(c *gin.Context) {
+	walletID, ok := pathID(c, "walletId", "Wallet ID")
+	if !ok {
+		return
+	}
+
+	var request models.WalletShardsRequest
+	if err := c.BindJSON(&request); err != nil || request.Shards == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	wallet, err := h.store.SetWalletShards(c.Request.Context(), walletID, *request.Shards)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, wallet)
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/NKV510/wallet-service/internal/models"
+	"github.com/NKV510/wallet-service/internal/repository"
+	"github.com/NKV510/wallet-service/internal/webhooks"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 100
+)
+
+type WebhookHandler struct {
+	store repository.WebhookStore
+}
+
+func NewWebhookHandler(store repository.WebhookStore) *WebhookHandler {
+	return &WebhookHandler{store: store}
+}
+
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var request models.WebhookRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	webhook, err := webhooks.CreateSubscription(c.Request.Context(), h.store, request)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, webhook)
+}
+
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	subscriptions, err := h.store.ListWebhooks(c.Request.Context())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"webhooks": subscriptions})
+}
+
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	webhook, err := h.store.GetWebhook(c.Request.Context(), c.Param("webhookId"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	var request models.WebhookRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	webhook, err := webhooks.UpdateSubscription(c.Request.Context(), h.store, c.Param("webhookId"), request)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	if err := h.store.DeleteWebhook(c.Request.Context(), c.Param("webhookId")); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListDeliveries returns the delivery log of a subscription, newest first.
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	filter := models.WebhookDeliveryFilter{Limit: defaultDeliveriesLimit}
+
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 || n > maxDeliveriesLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxDeliveriesLimit)})
+			return
+		}
+		filter.Limit = n
+	}
+
+	if status := models.WebhookDeliveryStatus(c.Query("status")); status != "" {
+		if status != models.DeliveryPending && status != models.DeliverySucceeded && status != models.DeliveryDead {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid delivery status"})
+			return
+		}
+		filter.Status = status
+	}
+
+	deliveries, err := h.store.ListWebhookDeliveries(c.Request.Context(), c.Param("webhookId"), filter)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deliveries": deliveries})
+}
+
+// Redeliver queues a delivery for an immediate new attempt, whatever its
+// current status, with a fresh attempt budget.
+func (h *WebhookHandler) Redeliver(c *gin.Context) {
+	delivery, err := h.store.RedeliverWebhook(c.Request.Context(), c.Param("webhookId"), c.Param("deliveryId"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, delivery)
+}
@@ -0,0 +1,120 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		level   string
+		format  string
+		wantErr bool
+	}{
+		{name: "json", level: "info", format: "json"},
+		{name: "text", level: "debug", format: "text"},
+		{name: "upper case", level: "WARN", format: "JSON"},
+		{name: "invalid level", level: "verbose", format: "json", wantErr: true},
+		{name: "invalid format", level: "info", format: "xml", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, err := New(&bytes.Buffer{}, tt.level, tt.format)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotNil(t, logger)
+		})
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	assert.Equal(t, slog.Default(), FromContext(context.Background()))
+
+	logger := slog.New(slog.NewJSONHandler(&bytes.Buffer{}, nil))
+	assert.Equal(t, logger, FromContext(WithLogger(context.Background(), logger)))
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var out bytes.Buffer
+	logger, err := New(&out, "info", "json")
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.Use(Middleware(logger))
+	router.GET("/wallets/:walletId", func(c *gin.Context) {
+		FromContext(c.Request.Context()).Info("inside handler")
+		c.Status(http.StatusOK)
+	})
+	router.POST("/wallet", func(c *gin.Context) {
+		c.Set(WalletIDKey, "body-wallet")
+		_ = c.Error(errors.New("failed to get wallet: connection refused"))
+		c.Status(http.StatusServiceUnavailable)
+	})
+
+	readLines := func() []map[string]any {
+		var lines []map[string]any
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			var entry map[string]any
+			require.NoError(t, json.Unmarshal([]byte(line), &entry))
+			lines = append(lines, entry)
+		}
+		out.Reset()
+		return lines
+	}
+
+	t.Run("propagates request id", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/wallets/abc", nil)
+		req.Header.Set(RequestIDHeader, "req-123")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, "req-123", w.Header().Get(RequestIDHeader))
+
+		lines := readLines()
+		require.Len(t, lines, 2)
+		assert.Equal(t, "inside handler", lines[0]["msg"])
+		assert.Equal(t, "req-123", lines[0]["request_id"])
+
+		assert.Equal(t, "request completed", lines[1]["msg"])
+		assert.Equal(t, "INFO", lines[1]["level"])
+		assert.Equal(t, "req-123", lines[1]["request_id"])
+		assert.Equal(t, "GET", lines[1]["method"])
+		assert.Equal(t, "/wallets/:walletId", lines[1]["route"])
+		assert.Equal(t, float64(200), lines[1]["status"])
+		assert.Equal(t, "abc", lines[1]["wallet_id"])
+		assert.Contains(t, lines[1], "latency_ms")
+	})
+
+	t.Run("assigns request id and logs errors", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/wallet", nil))
+
+		requestID := w.Header().Get(RequestIDHeader)
+		assert.Len(t, requestID, 32)
+
+		lines := readLines()
+		require.Len(t, lines, 1)
+		assert.Equal(t, "ERROR", lines[0]["level"])
+		assert.Equal(t, requestID, lines[0]["request_id"])
+		assert.Equal(t, "body-wallet", lines[0]["wallet_id"])
+		assert.Equal(t, "failed to get wallet: connection refused", lines[0]["error"])
+	})
+}
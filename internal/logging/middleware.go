@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+
+	// WalletIDKey is the gin context key handlers set when the wallet id
+	// comes from the request body rather than the path.
+	WalletIDKey = "walletId"
+
+	maxRequestIDLength = 128
+)
+
+// Middleware assigns every request an id, taken from X-Request-ID when the
+// client sent one, stores a logger tagged with it in the request context and
+// logs the request once it completes.
+func Middleware(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		requestID := RequestID(c.GetHeader(RequestIDHeader))
+		c.Header(RequestIDHeader, requestID)
+
+		reqLogger := logger.With("request_id", requestID)
+		c.Request = c.Request.WithContext(WithLogger(c.Request.Context(), reqLogger))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		attrs := []any{
+			"method", c.Request.Method,
+			"route", c.FullPath(),
+			"path", c.Request.URL.Path,
+			"status", status,
+			"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+		}
+
+		walletID := c.Param("walletId")
+		if walletID == "" {
+			walletID = c.GetString(WalletIDKey)
+		}
+		if walletID != "" {
+			attrs = append(attrs, "wallet_id", walletID)
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, "error", strings.Join(c.Errors.Errors(), "; "))
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+		reqLogger.Log(c.Request.Context(), level, "request completed", attrs...)
+	}
+}
+
+// RequestID returns the id the client sent, or a new one when it sent none
+// or one that is too long.
+func RequestID(sent string) string {
+	if sent == "" || len(sent) > maxRequestIDLength {
+		return newRequestID()
+	}
+	return sent
+}
+
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
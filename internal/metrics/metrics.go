@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	OutcomeSuccess           = "success"
+	OutcomeInsufficientFunds = "insufficient_funds"
+	OutcomeNotFound          = "not_found"
+	OutcomeRejected          = "rejected"
+	OutcomeError             = "error"
+)
+
+var lockWaitBuckets = []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1, 5}
+
+// Metrics is the set of service metrics. A nil *Metrics is valid and records
+// nothing, so components can be used without instrumentation.
+type Metrics struct {
+	Registry *Registry
+
+	httpRequests     *CounterVec
+	httpDuration     *HistogramVec
+	operations       *CounterVec
+	operationAmounts *CounterVec
+	lockWait         *HistogramVec
+}
+
+func New() *Metrics {
+	r := NewRegistry()
+	return &Metrics{
+		Registry: r,
+		httpRequests: r.NewCounterVec("wallet_http_requests_total",
+			"HTTP requests by method, route and status.", "method", "route", "status"),
+		httpDuration: r.NewHistogramVec("wallet_http_request_duration_seconds",
+			"HTTP request latency by method, route and status.", DefBuckets, "method", "route", "status"),
+		operations: r.NewCounterVec("wallet_operations_total",
+			"Deposits and withdrawals by outcome.", "operation", "outcome"),
+		operationAmounts: r.NewCounterVec("wallet_operation_amount_total",
+			"Sum of successful deposit and withdrawal amounts.", "operation"),
+		lockWait: r.NewHistogramVec("wallet_lock_wait_seconds",
+			"Time spent waiting for the wallet row lock.", lockWaitBuckets, "operation"),
+	}
+}
+
+// Middleware records the count and latency of every request. Requests that
+// match no route are grouped under the "unmatched" route to bound cardinality.
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+
+		m.httpRequests.Inc(c.Request.Method, route, status)
+		m.httpDuration.Observe(time.Since(start).Seconds(), c.Request.Method, route, status)
+	}
+}
+
+// ObserveOperation counts a deposit or withdrawal by outcome and, when it
+// succeeded, adds its amount to the totals.
+func (m *Metrics) ObserveOperation(operation, outcome string, amount int64) {
+	if m == nil {
+		return
+	}
+	m.operations.Inc(operation, outcome)
+	if outcome == OutcomeSuccess {
+		m.operationAmounts.Add(float64(amount), operation)
+	}
+}
+
+func (m *Metrics) ObserveLockWait(operation string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.lockWait.Observe(d.Seconds(), operation)
+}
+
+// RegisterPool exposes the connection pool statistics, read on every scrape.
+func (m *Metrics) RegisterPool(pool *pgxpool.Pool) {
+	r := m.Registry
+	r.NewGaugeFunc("wallet_db_pool_acquired_connections", "Connections currently in use.", func() float64 {
+		return float64(pool.Stat().AcquiredConns())
+	})
+	r.NewGaugeFunc("wallet_db_pool_idle_connections", "Idle connections in the pool.", func() float64 {
+		return float64(pool.Stat().IdleConns())
+	})
+	r.NewGaugeFunc("wallet_db_pool_total_connections", "Open connections in the pool.", func() float64 {
+		return float64(pool.Stat().TotalConns())
+	})
+	r.NewGaugeFunc("wallet_db_pool_max_connections", "Maximum size of the pool.", func() float64 {
+		return float64(pool.Stat().MaxConns())
+	})
+	r.NewCounterFunc("wallet_db_pool_acquire_total", "Connections acquired from the pool.", func() float64 {
+		return float64(pool.Stat().AcquireCount())
+	})
+	r.NewCounterFunc("wallet_db_pool_wait_total", "Acquires that had to wait for a free connection.", func() float64 {
+		return float64(pool.Stat().EmptyAcquireCount())
+	})
+	r.NewCounterFunc("wallet_db_pool_acquire_duration_seconds_total", "Time spent acquiring connections.", func() float64 {
+		return pool.Stat().AcquireDuration().Seconds()
+	})
+}
@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics_Middleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := New()
+
+	router := gin.New()
+	router.Use(m.Middleware())
+	router.GET("/wallets/:walletId", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for _, path := range []string{"/wallets/1", "/wallets/2", "/missing"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	assert.Equal(t, float64(2), m.httpRequests.Value("GET", "/wallets/:walletId", "200"))
+	assert.Equal(t, float64(1), m.httpRequests.Value("GET", "unmatched", "404"))
+	assert.Equal(t, uint64(2), m.httpDuration.Count("GET", "/wallets/:walletId", "200"))
+}
+
+func TestMetrics_Nil(t *testing.T) {
+	var m *Metrics
+
+	assert.NotPanics(t, func() {
+		m.ObserveOperation("DEPOSIT", OutcomeSuccess, 100)
+		m.ObserveLockWait("DEPOSIT", time.Millisecond)
+	})
+}
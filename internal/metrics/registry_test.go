@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_Write(t *testing.T) {
+	r := NewRegistry()
+
+	counter := r.NewCounterVec("test_requests_total", "Requests.", "route", "status")
+	counter.Inc("/a", "200")
+	counter.Add(2, "/a", "200")
+	counter.Inc("/b", "500")
+	counter.Add(-1, "/b", "500")
+	counter.Inc(`say "hi"`, "200")
+
+	histogram := r.NewHistogramVec("test_duration_seconds", "Latency.", []float64{1, 0.1}, "route")
+	histogram.Observe(0.05, "/a")
+	histogram.Observe(0.5, "/a")
+	histogram.Observe(5, "/a")
+
+	r.NewGaugeFunc("test_gauge", "Gauge.", func() float64 { return 7 })
+
+	var out bytes.Buffer
+	require.NoError(t, r.Write(&out))
+
+	expected := `# HELP test_requests_total Requests.
+# TYPE test_requests_total counter
+test_requests_total{route="/a",status="200"} 3
+test_requests_total{route="/b",status="500"} 1
+test_requests_total{route="say \"hi\"",status="200"} 1
+# HELP test_duration_seconds Latency.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{route="/a",le="0.1"} 1
+test_duration_seconds_bucket{route="/a",le="1"} 2
+test_duration_seconds_bucket{route="/a",le="+Inf"} 3
+test_duration_seconds_sum{route="/a"} 5.55
+test_duration_seconds_count{route="/a"} 3
+# HELP test_gauge Gauge.
+# TYPE test_gauge gauge
+test_gauge 7
+`
+	assert.Equal(t, expected, out.String())
+	assert.Equal(t, float64(3), counter.Value("/a", "200"))
+	assert.Equal(t, uint64(3), histogram.Count("/a"))
+}
+
+func TestRegistry_ServeHTTP(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("test_total", "Test.").Inc()
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/plain; version=0.0.4")
+	assert.Contains(t, w.Body.String(), "test_total 1\n")
+}
+
+func TestCounterVec_WrongLabelCount(t *testing.T) {
+	counter := NewRegistry().NewCounterVec("test_total", "Test.", "route")
+	assert.Panics(t, func() { counter.Inc() })
+}
@@ -1,28 +1,513 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type OperationType string
 
 const (
 	DEPOSIT  OperationType = "DEPOSIT"
 	WITHDRAW OperationType = "WITHDRAW"
+	TRANSFER OperationType = "TRANSFER"
+	EXCHANGE OperationType = "EXCHANGE"
+	// ADJUSTMENT entries are written by reconciliation to make the history
+	// add up to a balance that drifted from it; the balance is unchanged.
+	ADJUSTMENT OperationType = "ADJUSTMENT"
+	// OPENING entries are written by a migration for balances the wallet had
+	// before its history was recorded.
+	OPENING OperationType = "OPENING"
+)
+
+type WalletStatus string
+
+const (
+	WalletActive WalletStatus = "ACTIVE"
+	WalletFrozen WalletStatus = "FROZEN"
+	WalletClosed WalletStatus = "CLOSED"
+)
+
+type HoldStatus string
+
+const (
+	HoldActive   HoldStatus = "ACTIVE"
+	HoldCaptured HoldStatus = "CAPTURED"
+	HoldVoided   HoldStatus = "VOIDED"
+	HoldExpired  HoldStatus = "EXPIRED"
 )
 
 type Wallet struct {
-	ID        string    `json:"id"`
-	Balance   int64     `json:"balance"`
+	ID           string       `json:"id"`
+	Balance      int64        `json:"balance"`
+	Held         int64        `json:"held"`
+	Currency     string       `json:"currency"`
+	Status       WalletStatus `json:"status"`
+	StatusReason string       `json:"status_reason,omitempty"`
+	// Shards is the number of shard rows the balance is spread over; zero
+	// keeps it in the wallet row.
+	Shards    int       `json:"shards,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// Available is the part of the balance not reserved by active holds.
+func (w Wallet) Available() int64 {
+	return w.Balance - w.Held
+}
+
 type WalletOperation struct {
+	WalletID       string        `json:"walletId"`
+	OperationType  OperationType `json:"operationType"`
+	Amount         int64         `json:"amount"`
+	Currency       string        `json:"currency"`
+	IdempotencyKey string        `json:"idempotencyKey,omitempty"`
+}
+
+type BatchMode string
+
+const (
+	// BatchAtomic applies every operation in one transaction or none.
+	BatchAtomic BatchMode = "atomic"
+	// BatchIndependent applies each operation on its own.
+	BatchIndependent BatchMode = "independent"
+)
+
+type BatchRequest struct {
+	Mode       BatchMode         `json:"mode"`
+	Operations []WalletOperation `json:"operations"`
+}
+
+type BatchItemStatus string
+
+const (
+	BatchItemApplied  BatchItemStatus = "applied"
+	BatchItemReplayed BatchItemStatus = "replayed"
+	BatchItemFailed   BatchItemStatus = "failed"
+	// BatchItemRolledBack marks operations of an atomic batch that were
+	// undone because another operation failed.
+	BatchItemRolledBack BatchItemStatus = "rolled_back"
+	// BatchItemSkipped marks operations of an atomic batch after the one
+	// that failed.
+	BatchItemSkipped BatchItemStatus = "skipped"
+)
+
+type BatchItemResult struct {
+	Index    int             `json:"index"`
+	WalletID string          `json:"walletId"`
+	Status   BatchItemStatus `json:"status"`
+	// Balance is the wallet balance right after the operation; for replayed
+	// operations it is the current balance.
+	Balance *int64 `json:"balance,omitempty"`
+	Error   string `json:"error,omitempty"`
+	// Err is the failure behind Error, for the handler to map.
+	Err error `json:"-"`
+}
+
+type BatchResponse struct {
+	Mode BatchMode `json:"mode"`
+	// Applied counts applied and replayed operations, Failed the failed
+	// ones.
+	Applied int               `json:"applied"`
+	Failed  int               `json:"failed"`
+	Results []BatchItemResult `json:"results"`
+	Error   string            `json:"error,omitempty"`
+}
+
+type WalletBalanceResponse struct {
+	WalletID  string `json:"walletId"`
+	Balance   int64  `json:"balance"`
+	Available int64  `json:"available"`
+	Currency  string `json:"currency"`
+	// FormattedBalance and FormattedAvailable are the amounts in major units,
+	// for example "12.34" for a balance of 1234 EUR.
+	FormattedBalance   string       `json:"formattedBalance"`
+	FormattedAvailable string       `json:"formattedAvailable"`
+	Status             WalletStatus `json:"status"`
+	StatusReason       string       `json:"statusReason,omitempty"`
+}
+
+type WalletStatusRequest struct {
+	Reason string `json:"reason"`
+}
+
+type WalletShardsRequest struct {
+	Shards *int `json:"shards"`
+}
+
+type Transaction struct {
+	ID            string        `json:"id"`
 	WalletID      string        `json:"walletId"`
 	OperationType OperationType `json:"operationType"`
 	Amount        int64         `json:"amount"`
+	// BalanceBefore and BalanceAfter are nil for deposits and withdrawals
+	// made through the shards of a sharded wallet, whose whole balance at
+	// that point is not known; ShardEntry marks those entries. BalanceChange
+	// is always set.
+	BalanceBefore *int64    `json:"balanceBefore"`
+	BalanceAfter  *int64    `json:"balanceAfter"`
+	BalanceChange int64     `json:"balanceChange"`
+	ShardEntry    bool      `json:"shardEntry,omitempty"`
+	TransferID    string    `json:"transferId,omitempty"`
+	HoldID        string    `json:"holdId,omitempty"`
+	ExchangeID    string    `json:"exchangeId,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+	// Sequence orders the ledger: entries written in one database
+	// transaction share CreatedAt.
+	Sequence int64 `json:"-"`
 }
 
-type WalletBalanceResponse struct {
+// SetBalances records the balance before and after the entry.
+func (t *Transaction) SetBalances(before, after int64) {
+	t.BalanceBefore, t.BalanceAfter, t.BalanceChange = &before, &after, after-before
+}
+
+// System accounts are the counterparties of money entering and leaving the
+// wallets. Each wallet has its own account, named by WalletAccount.
+const (
+	AccountCashIn  = "cash_in"
+	AccountCashOut = "cash_out"
+	// AccountFX holds the position taken by currency exchanges, one balance
+	// per currency.
+	AccountFX = "fx"
+	// AccountAdjustments is the counterparty of reconciliation adjustments.
+	AccountAdjustments = "adjustments"
+	// AccountOpeningBalances is the counterparty of opening entries.
+	AccountOpeningBalances = "opening_balances"
+	// AccountWallets stands for all wallet accounts together in the trial
+	// balance.
+	AccountWallets = "wallets"
+
+	walletAccountPrefix = "wallet:"
+)
+
+func WalletAccount(walletID string) string {
+	return walletAccountPrefix + walletID
+}
+
+// NormalizeID returns the UUID id in the lower-case form Postgres prints it
+// in, or false when id is not a UUID written as 8-4-4-4-12 hex digits.
+func NormalizeID(id string) (string, bool) {
+	if len(id) != 36 {
+		return "", false
+	}
+
+	normalized := make([]byte, len(id))
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case i == 8 || i == 13 || i == 18 || i == 23:
+			if c != '-' {
+				return "", false
+			}
+		case '0' <= c && c <= '9', 'a' <= c && c <= 'f':
+		case 'A' <= c && c <= 'F':
+			c += 'a' - 'A'
+		default:
+			return "", false
+		}
+		normalized[i] = c
+	}
+	return string(normalized), true
+}
+
+// JournalEntry is one side of a double-entry posting. A credit increases a
+// wallet account's balance and a debit decreases it; the entries of a
+// journal have equal debits and credits in each currency.
+type JournalEntry struct {
+	ID            string    `json:"id"`
+	JournalID     string    `json:"journalId"`
+	TransactionID string    `json:"transactionId"`
+	Account       string    `json:"account"`
+	Currency      string    `json:"currency"`
+	Debit         int64     `json:"debit"`
+	Credit        int64     `json:"credit"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+type AccountBalance struct {
+	Account  string `json:"account"`
+	Currency string `json:"currency"`
+	Debit    int64  `json:"debit"`
+	Credit   int64  `json:"credit"`
+	// Balance is Credit minus Debit.
+	Balance int64 `json:"balance"`
+}
+
+type CurrencyTotal struct {
+	Currency string `json:"currency"`
+	Debit    int64  `json:"debit"`
+	Credit   int64  `json:"credit"`
+}
+
+// TrialBalance sums the journal per account and currency. The books are
+// balanced when, in every currency, debits equal credits, so the account
+// balances add up to zero.
+type TrialBalance struct {
+	Accounts []AccountBalance `json:"accounts"`
+	Totals   []CurrencyTotal  `json:"totals"`
+	Balanced bool             `json:"balanced"`
+}
+
+// BalanceDrift is a wallet whose balance differs from the sum of its
+// ledger entries.
+type BalanceDrift struct {
 	WalletID string `json:"walletId"`
-	Balance  int64  `json:"balance"`
+	// Expected is the balance the ledger adds up to.
+	Expected int64 `json:"expected"`
+	Actual   int64 `json:"actual"`
+	// Difference is Actual minus Expected.
+	Difference int64 `json:"difference"`
+	Corrected  bool  `json:"corrected"`
+}
+
+type ReconciliationReport struct {
+	ID             string         `json:"id"`
+	StartedAt      time.Time      `json:"startedAt"`
+	FinishedAt     time.Time      `json:"finishedAt"`
+	AutoCorrect    bool           `json:"autoCorrect"`
+	WalletsChecked int64          `json:"walletsChecked"`
+	Drifts         []BalanceDrift `json:"drifts"`
+	Error          string         `json:"error,omitempty"`
+}
+
+type TransactionCursor struct {
+	Sequence int64
+}
+
+type TransactionFilter struct {
+	OperationType OperationType
+	From          time.Time
+	To            time.Time
+	After         *TransactionCursor
+	Limit         int
+}
+
+type TransactionListResponse struct {
+	WalletID     string        `json:"walletId"`
+	Transactions []Transaction `json:"transactions"`
+	NextCursor   string        `json:"nextCursor,omitempty"`
+}
+
+type TransferRequest struct {
+	FromWalletID string `json:"fromWalletId"`
+	ToWalletID   string `json:"toWalletId"`
+	Amount       int64  `json:"amount"`
+}
+
+type TransferResponse struct {
+	TransferID   string `json:"transferId"`
+	FromWalletID string `json:"fromWalletId"`
+	ToWalletID   string `json:"toWalletId"`
+	Amount       int64  `json:"amount"`
+	FromBalance  int64  `json:"fromBalance"`
+	ToBalance    int64  `json:"toBalance"`
+}
+
+// ExchangeRate is how many units of To one unit of From buys, as an exact
+// decimal string such as "0.9134".
+type ExchangeRate struct {
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Rate      string    `json:"rate"`
+	UpdatedAt time.Time `json:"updatedAt,omitempty"`
+}
+
+type ExchangeRateRequest struct {
+	Rate string `json:"rate"`
+}
+
+type ExchangeQuoteRequest struct {
+	FromCurrency string `json:"fromCurrency"`
+	ToCurrency   string `json:"toCurrency"`
+	// Amount is optional; with it the quote also shows what it converts to.
+	Amount int64 `json:"amount,omitempty"`
+}
+
+// ExchangeQuote locks a rate until ExpiresAt for one exchange.
+type ExchangeQuote struct {
+	ID              string    `json:"quoteId"`
+	FromCurrency    string    `json:"fromCurrency"`
+	ToCurrency      string    `json:"toCurrency"`
+	Rate            string    `json:"rate"`
+	Amount          int64     `json:"amount,omitempty"`
+	ConvertedAmount int64     `json:"convertedAmount,omitempty"`
+	ExpiresAt       time.Time `json:"expiresAt"`
+}
+
+type ExchangeRequest struct {
+	FromWalletID string `json:"fromWalletId"`
+	ToWalletID   string `json:"toWalletId"`
+	// Amount is debited from the source wallet, in its minor units.
+	Amount  int64  `json:"amount"`
+	QuoteID string `json:"quoteId,omitempty"`
+}
+
+// Exchange is an exchange with the credited amount already worked out, as
+// the store applies it.
+type Exchange struct {
+	FromWalletID string
+	ToWalletID   string
+	FromCurrency string
+	ToCurrency   string
+	Debit        int64
+	Credit       int64
+	Rate         string
+	QuoteID      string
+}
+
+type ExchangeResponse struct {
+	ExchangeID     string `json:"exchangeId"`
+	FromWalletID   string `json:"fromWalletId"`
+	ToWalletID     string `json:"toWalletId"`
+	FromCurrency   string `json:"fromCurrency"`
+	ToCurrency     string `json:"toCurrency"`
+	Rate           string `json:"rate"`
+	QuoteID        string `json:"quoteId,omitempty"`
+	DebitedAmount  int64  `json:"debitedAmount"`
+	CreditedAmount int64  `json:"creditedAmount"`
+	FromBalance    int64  `json:"fromBalance"`
+	ToBalance      int64  `json:"toBalance"`
+}
+
+type Hold struct {
+	ID             string     `json:"id"`
+	WalletID       string     `json:"walletId"`
+	Amount         int64      `json:"amount"`
+	CapturedAmount int64      `json:"capturedAmount"`
+	Status         HoldStatus `json:"status"`
+	ExpiresAt      time.Time  `json:"expiresAt"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+}
+
+type CreateHoldRequest struct {
+	Amount     int64 `json:"amount"`
+	TTLSeconds int64 `json:"ttlSeconds,omitempty"`
+}
+
+type CaptureHoldRequest struct {
+	Amount int64 `json:"amount,omitempty"`
+}
+
+// WithdrawalLimits caps how much can leave a wallet. Zero means no limit.
+type WithdrawalLimits struct {
+	PerOperation int64 `json:"perOperation"`
+	Daily        int64 `json:"daily"`
+	Monthly      int64 `json:"monthly"`
+}
+
+// WithdrawalLimitsOverride holds per-wallet limits. A nil field falls back to
+// the global default.
+type WithdrawalLimitsOverride struct {
+	PerOperation *int64 `json:"perOperation"`
+	Daily        *int64 `json:"daily"`
+	Monthly      *int64 `json:"monthly"`
+}
+
+type WalletLimitsResponse struct {
+	WalletID    string                   `json:"walletId"`
+	Limits      WithdrawalLimits         `json:"limits"`
+	Override    WithdrawalLimitsOverride `json:"override"`
+	DailyUsed   int64                    `json:"dailyUsed"`
+	MonthlyUsed int64                    `json:"monthlyUsed"`
+}
+
+type APIKey struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	Scopes    []string   `json:"scopes"`
+	WalletIDs []string   `json:"walletIds"`
+	CreatedAt time.Time  `json:"createdAt"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+
+// Revoked reports whether the key has been revoked.
+func (k APIKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	WalletIDs []string `json:"walletIds"`
+}
+
+// CreateAPIKeyResponse is the only place the plaintext key is ever returned.
+type CreateAPIKeyResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+const EventBalanceChanged = "wallet.balance_changed"
+
+// Event is a message written to the outbox in the same transaction as the
+// change it describes. Sequence orders events; for wallet.balance_changed the
+// payload is the ledger entry (Transaction).
+type Event struct {
+	ID        string          `json:"id"`
+	Sequence  int64           `json:"sequence"`
+	Type      string          `json:"type"`
+	WalletID  string          `json:"walletId"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+type WebhookSubscription struct {
+	ID         string    `json:"id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"eventTypes"`
+	WalletIDs  []string  `json:"walletIds"`
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// WebhookRequest creates or updates a subscription. On update, empty fields
+// keep their current value.
+type WebhookRequest struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"eventTypes"`
+	WalletIDs  []string `json:"walletIds"`
+	Secret     string   `json:"secret"`
+	Active     *bool    `json:"active"`
+}
+
+// CreateWebhookResponse is the only place the signing secret is returned.
+type CreateWebhookResponse struct {
+	WebhookSubscription
+	Secret string `json:"secret"`
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	DeliveryPending   WebhookDeliveryStatus = "PENDING"
+	DeliverySucceeded WebhookDeliveryStatus = "SUCCEEDED"
+	// DeliveryDead marks a delivery that failed every attempt. It stays in
+	// the log until it is redelivered by hand.
+	DeliveryDead WebhookDeliveryStatus = "DEAD"
+)
+
+type WebhookDelivery struct {
+	ID             string                `json:"id"`
+	WebhookID      string                `json:"webhookId"`
+	EventID        string                `json:"eventId"`
+	EventType      string                `json:"eventType"`
+	WalletID       string                `json:"walletId"`
+	Payload        json.RawMessage       `json:"payload"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	NextAttemptAt  *time.Time            `json:"nextAttemptAt,omitempty"`
+	LastAttemptAt  *time.Time            `json:"lastAttemptAt,omitempty"`
+	LastStatusCode int                   `json:"lastStatusCode,omitempty"`
+	LastError      string                `json:"lastError,omitempty"`
+	CreatedAt      time.Time             `json:"createdAt"`
+	UpdatedAt      time.Time             `json:"updatedAt"`
+}
+
+type WebhookDeliveryFilter struct {
+	Status WebhookDeliveryStatus
+	Limit  int
 }
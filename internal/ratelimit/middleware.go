@@ -0,0 +1,149 @@
+package ratelimit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NKV510/wallet-service/internal/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// maxKeyBodyBytes caps how much of a request body WalletKeys reads. A batch
+// of the largest allowed size fits well within it.
+const maxKeyBodyBytes = 1 << 20
+
+// Rule applies a Limiter to the keys extracted from each request; the request
+// takes a token for every key. Requests for which Keys returns none are not
+// limited by the rule.
+type Rule struct {
+	Name    string
+	Limiter Limiter
+	Keys    func(c *gin.Context) []string
+}
+
+// Middleware checks every rule and rejects the request with 429 as soon as
+// one is exhausted, refunding the tokens it already took, so a rejected
+// request, such as a batch with one exhausted wallet, costs the other keys
+// nothing. The RateLimit-* headers describe the most restrictive rule.
+func Middleware(rules ...Rule) gin.HandlerFunc {
+	type token struct {
+		limiter Limiter
+		key     string
+	}
+
+	return func(c *gin.Context) {
+		var tightest *Result
+		var taken []token
+		for _, rule := range rules {
+			for _, key := range rule.Keys(c) {
+				key = rule.Name + ":" + key
+				result, err := rule.Limiter.Allow(c.Request.Context(), key)
+				if err != nil {
+					// A failing shared backend should not take the API down.
+					_ = c.Error(err)
+					continue
+				}
+
+				if !result.Allowed {
+					for _, t := range taken {
+						if err := t.limiter.Refund(c.Request.Context(), t.key); err != nil {
+							_ = c.Error(err)
+						}
+					}
+					setHeaders(c, result)
+					c.Header("Retry-After", strconv.Itoa(seconds(result.RetryAfter)))
+					c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded", "limit": rule.Name})
+					return
+				}
+				taken = append(taken, token{rule.Limiter, key})
+				if tightest == nil || result.Remaining < tightest.Remaining {
+					tightest = &result
+				}
+			}
+		}
+
+		if tightest != nil {
+			setHeaders(c, *tightest)
+		}
+		c.Next()
+	}
+}
+
+func setHeaders(c *gin.Context, result Result) {
+	c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+	c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Header("RateLimit-Reset", strconv.Itoa(seconds(result.Reset)))
+}
+
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// ClientKeys identifies the caller by API key id or token subject, falling
+// back to the client IP when authentication is disabled.
+func ClientKeys(c *gin.Context) []string {
+	if key := auth.KeyFromContext(c); key != nil {
+		return []string{"key:" + key.ID}
+	}
+	if principal := auth.PrincipalFromContext(c); principal != nil {
+		return []string{"sub:" + principal.Subject}
+	}
+	return []string{"ip:" + c.ClientIP()}
+}
+
+// WalletKeys returns the wallets a request targets: the :walletId path
+// parameter, the walletId or fromWalletId field of a JSON body, or the
+// walletId of every operation of a batch. The body is restored for the
+// handler; one over maxKeyBodyBytes is not read past the limit, and the
+// handler then fails to read it.
+func WalletKeys(c *gin.Context) []string {
+	if walletID := c.Param("walletId"); walletID != "" {
+		return []string{strings.ToLower(walletID)}
+	}
+	if c.Request.Body == nil || c.Request.Method == http.MethodGet {
+		return nil
+	}
+
+	limited := http.MaxBytesReader(c.Writer, c.Request.Body, maxKeyBodyBytes)
+	body, err := io.ReadAll(limited)
+	if err != nil {
+		c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), limited))
+		return nil
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	var target struct {
+		WalletID     string `json:"walletId"`
+		FromWalletID string `json:"fromWalletId"`
+		Operations   []struct {
+			WalletID string `json:"walletId"`
+		} `json:"operations"`
+	}
+	if json.Unmarshal(body, &target) != nil {
+		return nil
+	}
+
+	walletIDs := []string{target.WalletID}
+	if target.WalletID == "" {
+		walletIDs[0] = target.FromWalletID
+	}
+	for _, operation := range target.Operations {
+		walletIDs = append(walletIDs, operation.WalletID)
+	}
+
+	var keys []string
+	for _, walletID := range walletIDs {
+		key := strings.ToLower(walletID)
+		if key != "" && !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,129 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Result describes the state of a key's allowance after a request.
+type Result struct {
+	Allowed bool
+	// Limit is the burst size, the most requests a key can make at once.
+	Limit     int
+	Remaining int
+	// RetryAfter is how long a rejected request should wait for a token.
+	RetryAfter time.Duration
+	// Reset is how long until the allowance is full again.
+	Reset time.Duration
+}
+
+// Limiter decides whether a request for key may proceed. Implementations
+// must be safe for concurrent use; the in-process TokenBucket can be replaced
+// by one backed by a shared store when the service runs as several replicas.
+type Limiter interface {
+	Allow(ctx context.Context, key string) (Result, error)
+	// Refund gives back a token taken by Allow for a request that was
+	// rejected on another key.
+	Refund(ctx context.Context, key string) error
+}
+
+// TokenBucket is an in-process Limiter that gives each key a bucket of burst
+// tokens refilled at rate tokens per second.
+type TokenBucket struct {
+	rate  float64
+	burst int
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket returns a limiter refilling rate tokens per second, which
+// must be positive, up to burst tokens.
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &TokenBucket{
+		rate:    rate,
+		burst:   burst,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+var _ Limiter = (*TokenBucket)(nil)
+
+func (l *TokenBucket) Allow(ctx context.Context, key string) (Result, error) {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = l.refill(b, now)
+	b.last = now
+
+	result := Result{Limit: l.burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = l.duration(1 - b.tokens)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = l.duration(float64(l.burst) - b.tokens)
+
+	return result, nil
+}
+
+func (l *TokenBucket) Refund(ctx context.Context, key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// A bucket swept since is full already.
+	if b, ok := l.buckets[key]; ok {
+		b.tokens = math.Min(float64(l.burst), b.tokens+1)
+	}
+	return nil
+}
+
+func (l *TokenBucket) refill(b *bucket, now time.Time) float64 {
+	elapsed := now.Sub(b.last).Seconds()
+	return math.Min(float64(l.burst), b.tokens+elapsed*l.rate)
+}
+
+// duration returns how long it takes to refill tokens.
+func (l *TokenBucket) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// sweep drops buckets that have refilled completely, since a new bucket
+// would be identical. It runs at most once per full refill period.
+func (l *TokenBucket) sweep(now time.Time) {
+	period := l.duration(float64(l.burst))
+	if now.Sub(l.lastSweep) < period {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if l.refill(b, now) >= float64(l.burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
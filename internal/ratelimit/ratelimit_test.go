@@ -0,0 +1,248 @@
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestBucket(rate float64, burst int) (*TokenBucket, *time.Time) {
+	now := time.Unix(1_700_000_000, 0)
+	limiter := NewTokenBucket(rate, burst)
+	limiter.now = func() time.Time { return now }
+	return limiter, &now
+}
+
+func TestTokenBucket(t *testing.T) {
+	ctx := context.Background()
+	limiter, now := newTestBucket(2, 3)
+
+	for i := 2; i >= 0; i-- {
+		result, err := limiter.Allow(ctx, "a")
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 3, result.Limit)
+		assert.Equal(t, i, result.Remaining)
+	}
+
+	result, err := limiter.Allow(ctx, "a")
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 500*time.Millisecond, result.RetryAfter)
+	assert.Equal(t, 1500*time.Millisecond, result.Reset)
+
+	result, err = limiter.Allow(ctx, "b")
+	require.NoError(t, err)
+	assert.True(t, result.Allowed, "keys have separate buckets")
+
+	*now = now.Add(500 * time.Millisecond)
+	result, err = limiter.Allow(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+
+	*now = now.Add(time.Hour)
+	result, err = limiter.Allow(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 2, result.Remaining, "bucket refills up to burst only")
+}
+
+func TestTokenBucket_Sweep(t *testing.T) {
+	ctx := context.Background()
+	limiter, now := newTestBucket(1, 2)
+
+	for _, key := range []string{"a", "b", "c"} {
+		_, err := limiter.Allow(ctx, key)
+		require.NoError(t, err)
+	}
+	assert.Len(t, limiter.buckets, 3)
+
+	*now = now.Add(time.Minute)
+	_, err := limiter.Allow(ctx, "d")
+	require.NoError(t, err)
+	assert.Len(t, limiter.buckets, 1)
+}
+
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	return Result{}, errors.New("backend unavailable")
+}
+
+func (failingLimiter) Refund(ctx context.Context, key string) error {
+	return errors.New("backend unavailable")
+}
+
+func TestMiddleware(t *testing.T) {
+	clients, _ := newTestBucket(1, 3)
+	wallets, _ := newTestBucket(1, 2)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware(
+		Rule{Name: "client", Limiter: clients, Keys: func(c *gin.Context) []string { return []string{c.GetHeader("X-Client")} }},
+		Rule{Name: "wallet", Limiter: wallets, Keys: WalletKeys},
+		Rule{Name: "shared", Limiter: failingLimiter{}, Keys: ClientKeys},
+	))
+	router.POST("/wallet", func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, string(body))
+	})
+	router.GET("/wallets/:walletId", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	serve := func(method, path, client, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+		require.NoError(t, err)
+		req.Header.Set("X-Client", client)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	body := `{"walletId":"123E4567-E89B-12D3-A456-426614174000","operationType":"DEPOSIT","amount":1}`
+	w := serve("POST", "/wallet", "alice", body)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, body, w.Body.String(), "body is restored for the handler")
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"), "headers describe the tightest rule")
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Reset"))
+
+	w = serve("GET", "/wallets/123e4567-e89b-12d3-a456-426614174000", "bob", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+
+	w = serve("POST", "/wallet", "carol", body)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.JSONEq(t, `{"error":"rate limit exceeded","limit":"wallet"}`, w.Body.String())
+
+	for _, walletID := range []string{"223e4567-e89b-12d3-a456-426614174000", "323e4567-e89b-12d3-a456-426614174000"} {
+		w = serve("GET", "/wallets/"+walletID, "alice", "")
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+	w = serve("GET", "/wallets/423e4567-e89b-12d3-a456-426614174000", "alice", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.JSONEq(t, `{"error":"rate limit exceeded","limit":"client"}`, w.Body.String())
+}
+
+func TestWalletKeys(t *testing.T) {
+	const (
+		walletA = "123e4567-e89b-12d3-a456-426614174000"
+		walletB = "223e4567-e89b-12d3-a456-426614174000"
+	)
+
+	tests := []struct {
+		name string
+		path string
+		body string
+		want []string
+	}{
+		{
+			name: "operation",
+			path: "/wallet",
+			body: `{"walletId":"123E4567-E89B-12D3-A456-426614174000","operationType":"DEPOSIT","amount":1}`,
+			want: []string{walletA},
+		},
+		{
+			name: "transfer",
+			path: "/wallet",
+			body: `{"fromWalletId":"` + walletA + `","toWalletId":"` + walletB + `","amount":1}`,
+			want: []string{walletA},
+		},
+		{
+			name: "batch",
+			path: "/wallet",
+			body: `{"operations":[{"walletId":"` + walletA + `"},{"walletId":"` + walletB + `"},{"walletId":"123E4567-E89B-12D3-A456-426614174000"}]}`,
+			want: []string{walletA, walletB},
+		},
+		{
+			name: "path parameter",
+			path: "/wallets/" + walletB + "/holds",
+			body: `{"amount":1}`,
+			want: []string{walletB},
+		},
+		{
+			name: "invalid body",
+			path: "/wallet",
+			body: `{"walletId":`,
+		},
+		{
+			name: "body over the limit",
+			path: "/wallet",
+			body: `{"walletId":"` + walletA + `","padding":"` + strings.Repeat("x", maxKeyBodyBytes) + `"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			var keys []string
+			var readErr error
+			handler := func(c *gin.Context) {
+				keys = WalletKeys(c)
+				var body []byte
+				body, readErr = io.ReadAll(c.Request.Body)
+				if readErr == nil {
+					assert.Equal(t, tt.body, string(body), "body is restored for the handler")
+				}
+			}
+			router.POST("/wallet", handler)
+			router.POST("/wallets/:walletId/holds", handler)
+
+			req, err := http.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+			require.NoError(t, err)
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Equal(t, tt.want, keys)
+			if len(tt.body) > maxKeyBodyBytes {
+				assert.Error(t, readErr, "the handler cannot read a body over the limit")
+			} else {
+				assert.NoError(t, readErr)
+			}
+		})
+	}
+}
+
+func TestMiddleware_RefundsRejectedBatch(t *testing.T) {
+	wallets, _ := newTestBucket(1, 1)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware(Rule{Name: "wallet", Limiter: wallets, Keys: WalletKeys}))
+	router.POST("/wallets/batch", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.GET("/wallets/:walletId", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	serve := func(method, path, body string) int {
+		req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	const walletA, walletB = "123e4567-e89b-12d3-a456-426614174000", "223e4567-e89b-12d3-a456-426614174000"
+	require.Equal(t, http.StatusOK, serve("GET", "/wallets/"+walletB, ""))
+
+	batch := `{"operations":[{"walletId":"` + walletA + `"},{"walletId":"` + walletB + `"}]}`
+	assert.Equal(t, http.StatusTooManyRequests, serve("POST", "/wallets/batch", batch))
+	assert.Equal(t, http.StatusOK, serve("GET", "/wallets/"+walletA, ""), "the token taken for the first wallet is refunded")
+}
@@ -0,0 +1,100 @@
+// Package reconcile proves that wallet balances match their ledger history:
+// it recomputes every balance from the recorded operations, reports the
+// wallets that drifted and, if enabled, corrects them.
+package reconcile
+
+import (
+	"context"
+	"time"
+
+	"github.com/NKV510/wallet-service/internal/logging"
+	"github.com/NKV510/wallet-service/internal/models"
+	"github.com/NKV510/wallet-service/internal/repository"
+)
+
+type Reconciler struct {
+	store       repository.ReconciliationStore
+	autoCorrect bool
+}
+
+// New returns a Reconciler. With autoCorrect, every drift found is corrected
+// by an ADJUSTMENT ledger entry: the balance is taken as the truth and the
+// history is made to add up to it.
+func New(store repository.ReconciliationStore, autoCorrect bool) *Reconciler {
+	return &Reconciler{store: store, autoCorrect: autoCorrect}
+}
+
+// Reconcile checks every wallet once and saves the report. A failed check
+// is saved too, so the last report always tells how the last run ended.
+func (r *Reconciler) Reconcile(ctx context.Context) (*models.ReconciliationReport, error) {
+	report := models.ReconciliationReport{
+		StartedAt:   time.Now().UTC(),
+		AutoCorrect: r.autoCorrect,
+		Drifts:      []models.BalanceDrift{},
+	}
+
+	err := r.check(ctx, &report)
+	if err != nil {
+		report.Error = err.Error()
+	}
+	report.FinishedAt = time.Now().UTC()
+
+	saved, saveErr := r.store.SaveReconciliationReport(ctx, report)
+	if err != nil {
+		return &report, err
+	}
+	if saveErr != nil {
+		return &report, saveErr
+	}
+	return saved, nil
+}
+
+func (r *Reconciler) check(ctx context.Context, report *models.ReconciliationReport) error {
+	checked, drifts, err := r.store.FindBalanceDrift(ctx)
+	if err != nil {
+		return err
+	}
+	report.WalletsChecked = checked
+	report.Drifts = drifts
+
+	if !r.autoCorrect {
+		return nil
+	}
+	for i, drift := range report.Drifts {
+		corrected, err := r.store.CorrectBalanceDrift(ctx, drift.WalletID)
+		if err != nil {
+			return err
+		}
+		// A wallet fixed in the meantime has nothing left to correct and
+		// keeps its report entry as found.
+		if corrected != nil {
+			report.Drifts[i] = *corrected
+		}
+	}
+	return nil
+}
+
+// Run reconciles every interval until ctx is cancelled, logging each drift.
+func (r *Reconciler) Run(ctx context.Context, interval time.Duration) {
+	logger := logging.FromContext(ctx).With("job", "balance reconciliation")
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := r.Reconcile(ctx)
+			if err != nil {
+				logger.Error("background job failed", "error", err)
+				continue
+			}
+			for _, drift := range report.Drifts {
+				logger.Warn("balance drift", "wallet_id", drift.WalletID, "expected", drift.Expected, "actual", drift.Actual, "corrected", drift.Corrected)
+			}
+			logger.Info("background job finished", "result", "wallets checked", "count", report.WalletsChecked, "drifts", len(report.Drifts))
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/NKV510/wallet-service/internal/models"
+	"github.com/NKV510/wallet-service/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// driftStore reports fixed drift and records the corrections asked for.
+type driftStore struct {
+	*repository.MemoryWalletRepository
+	drifts    []models.BalanceDrift
+	findErr   error
+	corrected []string
+}
+
+func (s *driftStore) FindBalanceDrift(ctx context.Context) (int64, []models.BalanceDrift, error) {
+	if s.findErr != nil {
+		return 0, nil, s.findErr
+	}
+	return 10, append([]models.BalanceDrift(nil), s.drifts...), nil
+}
+
+func (s *driftStore) CorrectBalanceDrift(ctx context.Context, walletID string) (*models.BalanceDrift, error) {
+	s.corrected = append(s.corrected, walletID)
+	for _, drift := range s.drifts {
+		if drift.WalletID == walletID && drift.WalletID != "fixed-meanwhile" {
+			drift.Corrected = true
+			return &drift, nil
+		}
+	}
+	return nil, nil
+}
+
+func TestReconciler(t *testing.T) {
+	ctx := context.Background()
+	drifts := []models.BalanceDrift{
+		{WalletID: "a", Expected: 100, Actual: 150, Difference: 50},
+		{WalletID: "fixed-meanwhile", Expected: 10, Actual: 0, Difference: -10},
+	}
+
+	tests := []struct {
+		name          string
+		autoCorrect   bool
+		findErr       error
+		wantCorrected []string
+		wantDrifts    []models.BalanceDrift
+		wantErr       bool
+	}{
+		{
+			name:       "report only",
+			wantDrifts: drifts,
+		},
+		{
+			name:          "auto correct",
+			autoCorrect:   true,
+			wantCorrected: []string{"a", "fixed-meanwhile"},
+			wantDrifts: []models.BalanceDrift{
+				{WalletID: "a", Expected: 100, Actual: 150, Difference: 50, Corrected: true},
+				drifts[1],
+			},
+		},
+		{
+			name:       "check fails",
+			findErr:    errors.New("connection refused"),
+			wantDrifts: []models.BalanceDrift{},
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &driftStore{MemoryWalletRepository: repository.NewMemoryWalletRepository(), drifts: drifts, findErr: tt.findErr}
+			report, err := New(store, tt.autoCorrect).Reconcile(ctx)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantCorrected, store.corrected)
+			assert.Equal(t, tt.wantDrifts, report.Drifts)
+
+			last, err := store.LastReconciliationReport(ctx)
+			require.NoError(t, err, "every run is saved")
+			assert.Equal(t, tt.autoCorrect, last.AutoCorrect)
+			assert.Equal(t, tt.wantDrifts, last.Drifts)
+			if tt.wantErr {
+				assert.Equal(t, "connection refused", last.Error)
+			} else {
+				assert.Equal(t, int64(10), last.WalletsChecked)
+				assert.Empty(t, last.Error)
+			}
+		})
+	}
+}
+
+func TestReconcilerMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryWalletRepository()
+	_, _, err := store.ProcessOperation(ctx, models.WalletOperation{WalletID: "123e4567-e89b-12d3-a456-426614174000", OperationType: models.DEPOSIT, Amount: 100, Currency: "USD"})
+	require.NoError(t, err)
+
+	report, err := New(store, true).Reconcile(ctx)
+	require.NoError(t, err)
+	assert.NotEmpty(t, report.ID)
+	assert.Equal(t, int64(1), report.WalletsChecked)
+	assert.Empty(t, report.Drifts)
+	assert.False(t, report.FinishedAt.Before(report.StartedAt))
+}
@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/NKV510/wallet-service/internal/models"
+)
+
+// APIKeyStore keeps API keys. Only the SHA-256 hash of a key is stored; the
+// plaintext is shown once when the key is created.
+type APIKeyStore interface {
+	CreateAPIKey(ctx context.Context, key models.APIKey, keyHash string) (*models.APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id string) (*models.APIKey, error)
+}
+
+var (
+	_ APIKeyStore = (*WalletRepository)(nil)
+	_ APIKeyStore = (*MemoryWalletRepository)(nil)
+)
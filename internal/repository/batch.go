@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"github.com/NKV510/wallet-service/internal/models"
+)
+
+func newBatchResults(operations []models.WalletOperation) []models.BatchItemResult {
+	results := make([]models.BatchItemResult, len(operations))
+	for i, op := range operations {
+		results[i] = models.BatchItemResult{Index: i, WalletID: op.WalletID}
+	}
+	return results
+}
+
+func setBatchResult(result *models.BatchItemResult, status models.BatchItemStatus, balance int64) {
+	result.Status = status
+	result.Balance = &balance
+}
+
+// failBatch fills in the results of an atomic batch that was rolled back
+// because operation failed did not go through.
+func failBatch(results []models.BatchItemResult, failed int, err error) {
+	for i := range results {
+		switch {
+		case i < failed:
+			results[i].Status = models.BatchItemRolledBack
+			results[i].Balance = nil
+		case i == failed:
+			results[i].Status = models.BatchItemFailed
+			results[i].Err = err
+		default:
+			results[i].Status = models.BatchItemSkipped
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"encoding/base64"
+	"strconv"
+
+	"github.com/NKV510/wallet-service/internal/models"
+)
+
+// EncodeTransactionCursor turns the position of a ledger entry into an opaque
+// token that clients pass back to fetch the next page.
+func EncodeTransactionCursor(cursor models.TransactionCursor) string {
+	raw := strconv.FormatInt(cursor.Sequence, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeTransactionCursor(token string) (*models.TransactionCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	sequence, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || sequence <= 0 {
+		return nil, ErrInvalidCursor
+	}
+
+	return &models.TransactionCursor{Sequence: sequence}, nil
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrWalletNotFound       = errors.New("wallet not found")
+	ErrHoldNotFound         = errors.New("hold not found")
+	ErrAPIKeyNotFound       = errors.New("api key not found")
+	ErrWebhookNotFound      = errors.New("webhook not found")
+	ErrDeliveryNotFound     = errors.New("webhook delivery not found")
+	ErrQuoteNotFound        = errors.New("exchange quote not found")
+	ErrJournalNotFound      = errors.New("journal not found")
+	ErrNoReconciliation     = errors.New("no reconciliation has run yet")
+	ErrInsufficientFunds    = errors.New("insufficient funds")
+	ErrWalletNotActive      = errors.New("wallet is not active")
+	ErrLimitExceeded        = errors.New("limit exceeded")
+	ErrInvalidOperation     = errors.New("invalid operation")
+	ErrConflict             = errors.New("conflict")
+	ErrUnavailable          = errors.New("database unavailable")
+	ErrIdempotencyKeyReused = errors.New("idempotency key reused with different payload")
+	ErrInvalidCursor        = errors.New("invalid cursor")
+	ErrCurrencyMismatch     = errors.New("currency does not match the wallet")
+	ErrRateNotFound         = errors.New("exchange rate not found")
+	ErrQuoteExpired         = errors.New("exchange quote expired or already used")
+)
+
+// dbError wraps a database failure with the action that caused it and tags
+// outages with ErrUnavailable and write races with ErrConflict, so callers
+// can tell them apart from bugs with errors.Is.
+func dbError(action string, err error) error {
+	switch {
+	case isUnavailable(err):
+		return fmt.Errorf("failed to %s: %w: %w", action, ErrUnavailable, err)
+	case isConflict(err):
+		return fmt.Errorf("failed to %s: %w: %w", action, ErrConflict, err)
+	default:
+		return fmt.Errorf("failed to %s: %w", action, err)
+	}
+}
+
+func isUnavailable(err error) bool {
+	var connectErr *pgconn.ConnectError
+	if errors.As(err, &connectErr) || pgconn.Timeout(err) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case len(pgErr.Code) == 5 && pgErr.Code[:2] == "08": // connection_exception
+			return true
+		case pgErr.Code == "53300", // too_many_connections
+			pgErr.Code == "57P01", // admin_shutdown
+			pgErr.Code == "57P02", // crash_shutdown
+			pgErr.Code == "57P03": // cannot_connect_now
+			return true
+		}
+	}
+
+	return false
+}
+
+func isConflict(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+
+	switch pgErr.Code {
+	case "23505", // unique_violation
+		"40001", // serialization_failure
+		"40P01": // deadlock_detected
+		return true
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDBError(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		unavailable bool
+		conflict    bool
+	}{
+		{
+			name:     "unique violation",
+			err:      &pgconn.PgError{Code: "23505"},
+			conflict: true,
+		},
+		{
+			name:     "deadlock",
+			err:      &pgconn.PgError{Code: "40P01"},
+			conflict: true,
+		},
+		{
+			name:        "connection failure",
+			err:         &pgconn.PgError{Code: "08006"},
+			unavailable: true,
+		},
+		{
+			name:        "too many connections",
+			err:         &pgconn.PgError{Code: "53300"},
+			unavailable: true,
+		},
+		{
+			name:        "deadline exceeded",
+			err:         context.DeadlineExceeded,
+			unavailable: true,
+		},
+		{
+			name: "syntax error",
+			err:  &pgconn.PgError{Code: "42601"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := dbError("get wallet", tt.err)
+
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.unavailable, errors.Is(err, ErrUnavailable))
+			assert.Equal(t, tt.conflict, errors.Is(err, ErrConflict))
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/NKV510/wallet-service/internal/models"
+)
+
+// ExchangeStore keeps the exchange rate table and rate quotes and applies
+// currency exchanges between wallets. Rates are exact decimal strings; the
+// caller validates them and converts amounts before calling Exchange.
+type ExchangeStore interface {
+	GetExchangeRate(ctx context.Context, from, to string) (*models.ExchangeRate, error)
+	ListExchangeRates(ctx context.Context) ([]models.ExchangeRate, error)
+	SetExchangeRate(ctx context.Context, rate models.ExchangeRate) (*models.ExchangeRate, error)
+
+	// CreateExchangeQuote stores the quote's pair and rate for ttl and
+	// returns it with its id and expiry.
+	CreateExchangeQuote(ctx context.Context, quote models.ExchangeQuote, ttl time.Duration) (*models.ExchangeQuote, error)
+	GetExchangeQuote(ctx context.Context, id string) (*models.ExchangeQuote, error)
+	DeleteExpiredExchangeQuotes(ctx context.Context) (int64, error)
+
+	// Exchange debits one wallet and credits another in one transaction,
+	// recording both legs under a shared exchange id. A quote, if given, is
+	// used up by the exchange and must not have expired.
+	Exchange(ctx context.Context, exchange models.Exchange) (*models.ExchangeResponse, error)
+}
+
+var (
+	_ ExchangeStore = (*WalletRepository)(nil)
+	_ ExchangeStore = (*MemoryWalletRepository)(nil)
+)
+
+func checkExchange(fromWalletID, toWalletID string, exchange models.Exchange) error {
+	if fromWalletID == toWalletID {
+		return fmt.Errorf("%w: cannot exchange within the same wallet", ErrInvalidOperation)
+	}
+	if exchange.Debit <= 0 || exchange.Credit <= 0 {
+		return fmt.Errorf("%w: amounts must be positive", ErrInvalidOperation)
+	}
+	return nil
+}
+
+func exchangeResponse(exchangeID, fromWalletID, toWalletID string, exchange models.Exchange, fromBalance, toBalance int64) *models.ExchangeResponse {
+	return &models.ExchangeResponse{
+		ExchangeID:     exchangeID,
+		FromWalletID:   fromWalletID,
+		ToWalletID:     toWalletID,
+		FromCurrency:   exchange.FromCurrency,
+		ToCurrency:     exchange.ToCurrency,
+		Rate:           exchange.Rate,
+		QuoteID:        exchange.QuoteID,
+		DebitedAmount:  exchange.Debit,
+		CreditedAmount: exchange.Credit,
+		FromBalance:    fromBalance,
+		ToBalance:      toBalance,
+	}
+}
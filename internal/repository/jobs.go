@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/NKV510/wallet-service/internal/logging"
+)
+
+// StartIdempotencyCleanup deletes expired idempotency keys every interval
+// until ctx is cancelled.
+func StartIdempotencyCleanup(ctx context.Context, store WalletStore, interval time.Duration) {
+	runPeriodically(ctx, interval, "idempotency key cleanup", "expired idempotency keys deleted", store.DeleteExpiredIdempotencyKeys)
+}
+
+// StartHoldExpiry marks holds past their TTL as expired every interval until
+// ctx is cancelled.
+func StartHoldExpiry(ctx context.Context, store WalletStore, interval time.Duration) {
+	runPeriodically(ctx, interval, "hold expiry", "holds expired", store.ExpireHolds)
+}
+
+// StartExchangeQuoteCleanup deletes expired exchange quotes every interval
+// until ctx is cancelled.
+func StartExchangeQuoteCleanup(ctx context.Context, store ExchangeStore, interval time.Duration) {
+	runPeriodically(ctx, interval, "exchange quote cleanup", "expired exchange quotes deleted", store.DeleteExpiredExchangeQuotes)
+}
+
+// StartShardCompaction spreads the balance of every sharded wallet evenly
+// over its shards every interval until ctx is cancelled.
+func StartShardCompaction(ctx context.Context, store ShardStore, interval time.Duration) {
+	runPeriodically(ctx, interval, "wallet shard compaction", "wallets compacted", store.CompactWalletShards)
+}
+
+// StartOutboxCleanup deletes outbox events published more than retention ago
+// every interval until ctx is cancelled.
+func StartOutboxCleanup(ctx context.Context, store OutboxStore, interval, retention time.Duration) {
+	runPeriodically(ctx, interval, "outbox cleanup", "published events deleted", func(ctx context.Context) (int64, error) {
+		return store.DeletePublishedEvents(ctx, retention)
+	})
+}
+
+func runPeriodically(ctx context.Context, interval time.Duration, name, result string, job func(context.Context) (int64, error)) {
+	logger := logging.FromContext(ctx).With("job", name)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := job(ctx)
+			if err != nil {
+				logger.Error("background job failed", "error", err)
+				continue
+			}
+			if n > 0 {
+				logger.Info("background job finished", "result", result, "count", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/NKV510/wallet-service/internal/models"
+)
+
+// LedgerStore reads the double-entry journal posted alongside every ledger
+// entry.
+type LedgerStore interface {
+	TrialBalance(ctx context.Context) (*models.TrialBalance, error)
+	// GetJournal returns the entries of one journal: a transfer or exchange
+	// id, or the id of the ledger entry of any other operation.
+	GetJournal(ctx context.Context, journalID string) ([]models.JournalEntry, error)
+}
+
+var (
+	_ LedgerStore = (*WalletRepository)(nil)
+	_ LedgerStore = (*MemoryWalletRepository)(nil)
+)
+
+// counterAccount is the system account on the other side of a ledger entry.
+// Transfers have none: the two wallet legs balance each other.
+func counterAccount(operationType models.OperationType) string {
+	switch operationType {
+	case models.DEPOSIT:
+		return models.AccountCashIn
+	case models.WITHDRAW:
+		return models.AccountCashOut
+	case models.EXCHANGE:
+		return models.AccountFX
+	case models.ADJUSTMENT:
+		return models.AccountAdjustments
+	case models.OPENING:
+		return models.AccountOpeningBalances
+	default:
+		return ""
+	}
+}
+
+func journalID(entry models.Transaction) string {
+	switch {
+	case entry.TransferID != "":
+		return entry.TransferID
+	case entry.ExchangeID != "":
+		return entry.ExchangeID
+	default:
+		return entry.ID
+	}
+}
+
+// journalPostings returns the journal entries for a ledger entry: the change
+// of the wallet's account and, unless it is a transfer leg, the opposite
+// posting to the counter account.
+func journalPostings(entry models.Transaction, currency string) []models.JournalEntry {
+	postings := []struct {
+		account string
+		amount  int64
+	}{
+		{models.WalletAccount(entry.WalletID), entry.BalanceChange},
+		{counterAccount(entry.OperationType), -entry.BalanceChange},
+	}
+
+	var entries []models.JournalEntry
+	for _, posting := range postings {
+		if posting.account == "" || posting.amount == 0 {
+			continue
+		}
+		journalEntry := models.JournalEntry{
+			JournalID:     journalID(entry),
+			TransactionID: entry.ID,
+			Account:       posting.account,
+			Currency:      currency,
+			CreatedAt:     entry.CreatedAt,
+		}
+		if posting.amount > 0 {
+			journalEntry.Credit = posting.amount
+		} else {
+			journalEntry.Debit = -posting.amount
+		}
+		entries = append(entries, journalEntry)
+	}
+	return entries
+}
+
+// newTrialBalance totals accounts, which are already summed per account and
+// currency, and sorts them by currency and account.
+func newTrialBalance(accounts []models.AccountBalance) *models.TrialBalance {
+	slices.SortFunc(accounts, func(a, b models.AccountBalance) int {
+		if c := strings.Compare(a.Currency, b.Currency); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Account, b.Account)
+	})
+
+	balance := &models.TrialBalance{Accounts: accounts, Totals: []models.CurrencyTotal{}, Balanced: true}
+	for i := range accounts {
+		accounts[i].Balance = accounts[i].Credit - accounts[i].Debit
+
+		last := len(balance.Totals) - 1
+		if last < 0 || balance.Totals[last].Currency != accounts[i].Currency {
+			balance.Totals = append(balance.Totals, models.CurrencyTotal{Currency: accounts[i].Currency})
+			last++
+		}
+		balance.Totals[last].Debit += accounts[i].Debit
+		balance.Totals[last].Credit += accounts[i].Credit
+	}
+	for _, total := range balance.Totals {
+		if total.Debit != total.Credit {
+			balance.Balanced = false
+		}
+	}
+	return balance
+}
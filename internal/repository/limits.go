@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/NKV510/wallet-service/internal/models"
+)
+
+const (
+	LimitPerOperation = "per_operation"
+	LimitDaily        = "daily"
+	LimitMonthly      = "monthly"
+
+	dailyLimitWindow   = 24 * time.Hour
+	monthlyLimitWindow = 30 * 24 * time.Hour
+)
+
+// LimitError reports which withdrawal limit an operation would break and how
+// much can still be withdrawn under it. It matches ErrLimitExceeded.
+type LimitError struct {
+	Limit     string
+	Max       int64
+	Remaining int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: %s limit is %d, remaining %d", ErrLimitExceeded, e.Limit, e.Max, e.Remaining)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// effectiveLimits applies the per-wallet override on top of the defaults.
+func effectiveLimits(defaults models.WithdrawalLimits, override models.WithdrawalLimitsOverride) models.WithdrawalLimits {
+	limits := defaults
+	if override.PerOperation != nil {
+		limits.PerOperation = *override.PerOperation
+	}
+	if override.Daily != nil {
+		limits.Daily = *override.Daily
+	}
+	if override.Monthly != nil {
+		limits.Monthly = *override.Monthly
+	}
+	return limits
+}
+
+// checkWithdrawalLimits reports whether withdrawing amount fits the limits,
+// given what already left the wallet in the rolling day and month.
+func checkWithdrawalLimits(limits models.WithdrawalLimits, amount, dailyUsed, monthlyUsed int64) error {
+	if limits.PerOperation > 0 && amount > limits.PerOperation {
+		return &LimitError{Limit: LimitPerOperation, Max: limits.PerOperation, Remaining: limits.PerOperation}
+	}
+	if limits.Daily > 0 && dailyUsed+amount > limits.Daily {
+		return &LimitError{Limit: LimitDaily, Max: limits.Daily, Remaining: max(limits.Daily-dailyUsed, 0)}
+	}
+	if limits.Monthly > 0 && monthlyUsed+amount > limits.Monthly {
+		return &LimitError{Limit: LimitMonthly, Max: limits.Monthly, Remaining: max(limits.Monthly-monthlyUsed, 0)}
+	}
+	return nil
+}
+
+func validateLimitsOverride(override models.WithdrawalLimitsOverride) error {
+	for _, value := range []*int64{override.PerOperation, override.Daily, override.Monthly} {
+		if value != nil && *value < 0 {
+			return fmt.Errorf("%w: limits must not be negative", ErrInvalidOperation)
+		}
+	}
+	return nil
+}
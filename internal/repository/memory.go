@@ -0,0 +1,308 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NKV510/wallet-service/internal/models"
+)
+
+type idempotencyRecord struct {
+	requestHash string
+	// balance is what the operation left, returned to replays.
+	balance   int64
+	expiresAt time.Time
+}
+
+// MemoryWalletRepository keeps wallets in process memory. A single mutex
+// serializes every operation, which is at least as strict as the row locks
+// taken by WalletRepository.
+type MemoryWalletRepository struct {
+	options
+	mu sync.Mutex
+
+	wallets         map[string]*models.Wallet
+	transactions    map[string][]models.Transaction
+	journal         []models.JournalEntry
+	idempotencyKeys map[string]idempotencyRecord
+	holds           map[string]*models.Hold
+	limits          map[string]models.WithdrawalLimitsOverride
+	apiKeys         map[string]*apiKeyRecord
+	webhooks        map[string]*webhookRecord
+	deliveries      []*models.WebhookDelivery
+	exchangeRates   map[string]models.ExchangeRate
+	exchangeQuotes  map[string]*exchangeQuoteRecord
+	reconciliations []models.ReconciliationReport
+	outbox          []*outboxRecord
+	outboxSequence  int64
+	ledgerSequence  int64
+	lastTimestamp   time.Time
+
+	relayMu sync.Mutex
+}
+
+func NewMemoryWalletRepository(opts ...Option) *MemoryWalletRepository {
+	return &MemoryWalletRepository{
+		options:         newOptions(opts),
+		wallets:         make(map[string]*models.Wallet),
+		transactions:    make(map[string][]models.Transaction),
+		idempotencyKeys: make(map[string]idempotencyRecord),
+		holds:           make(map[string]*models.Hold),
+		limits:          make(map[string]models.WithdrawalLimitsOverride),
+		apiKeys:         make(map[string]*apiKeyRecord),
+		webhooks:        make(map[string]*webhookRecord),
+		exchangeRates:   make(map[string]models.ExchangeRate),
+		exchangeQuotes:  make(map[string]*exchangeQuoteRecord),
+	}
+}
+
+func (r *MemoryWalletRepository) CreateWallet(ctx context.Context, walletID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	walletID = strings.ToLower(walletID)
+	if _, ok := r.wallets[walletID]; ok {
+		return fmt.Errorf("failed to create wallet: %w", ErrConflict)
+	}
+
+	r.addWallet(walletID, "")
+	return nil
+}
+
+// addWallet creates the wallet in currency, or in the default currency if
+// currency is empty.
+func (r *MemoryWalletRepository) addWallet(walletID, currency string) *models.Wallet {
+	now := r.now()
+	wallet := &models.Wallet{
+		ID:        walletID,
+		Currency:  r.walletCurrency(currency),
+		Status:    models.WalletActive,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	r.wallets[walletID] = wallet
+	return wallet
+}
+
+func (r *MemoryWalletRepository) GetWallet(ctx context.Context, walletID string) (*models.Wallet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	wallet, ok := r.wallets[strings.ToLower(walletID)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrWalletNotFound, walletID)
+	}
+
+	copied := *wallet
+	copied.Held = r.heldAmount(copied.ID)
+	return &copied, nil
+}
+
+func (r *MemoryWalletRepository) UpdateWalletBalance(ctx context.Context, walletID string, operationType models.OperationType, amount int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err := r.applyOperation(models.WalletOperation{WalletID: walletID, OperationType: operationType, Amount: amount})
+	r.observeOperation(operationType, amount, err)
+	return err
+}
+
+func (r *MemoryWalletRepository) DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int64
+	now := time.Now()
+	for key, record := range r.idempotencyKeys {
+		if !record.expiresAt.After(now) {
+			delete(r.idempotencyKeys, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (r *MemoryWalletRepository) Transfer(ctx context.Context, fromWalletID, toWalletID string, amount int64) (*models.TransferResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fromWalletID = strings.ToLower(fromWalletID)
+	toWalletID = strings.ToLower(toWalletID)
+	if fromWalletID == toWalletID {
+		return nil, fmt.Errorf("%w: cannot transfer to the same wallet", ErrInvalidOperation)
+	}
+	if amount <= 0 {
+		return nil, fmt.Errorf("%w: amount must be positive", ErrInvalidOperation)
+	}
+
+	from, ok := r.wallets[fromWalletID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrWalletNotFound, fromWalletID)
+	}
+	to, ok := r.wallets[toWalletID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrWalletNotFound, toWalletID)
+	}
+	if err := r.checkWalletStatus(from, models.WITHDRAW); err != nil {
+		return nil, err
+	}
+	if err := r.checkWalletStatus(to, models.DEPOSIT); err != nil {
+		return nil, err
+	}
+	if err := r.checkCurrency(to, from.Currency); err != nil {
+		return nil, err
+	}
+	if from.Balance-r.heldAmount(from.ID) < amount {
+		return nil, ErrInsufficientFunds
+	}
+	if err := r.checkLimits(from.ID, amount); err != nil {
+		return nil, err
+	}
+
+	transferID := newUUID()
+	r.setBalance(from, from.Balance-amount, models.Transaction{OperationType: models.TRANSFER, Amount: amount, TransferID: transferID})
+	r.setBalance(to, to.Balance+amount, models.Transaction{OperationType: models.TRANSFER, Amount: amount, TransferID: transferID})
+
+	return &models.TransferResponse{
+		TransferID:   transferID,
+		FromWalletID: fromWalletID,
+		ToWalletID:   toWalletID,
+		Amount:       amount,
+		FromBalance:  from.Balance,
+		ToBalance:    to.Balance,
+	}, nil
+}
+
+func (r *MemoryWalletRepository) SetWalletStatus(ctx context.Context, walletID string, status models.WalletStatus, reason string) (*models.Wallet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	wallet, ok := r.wallets[strings.ToLower(walletID)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrWalletNotFound, walletID)
+	}
+
+	current := *wallet
+	current.Held = r.heldAmount(wallet.ID)
+	if err := checkStatusTransition(&current, status); err != nil {
+		return nil, err
+	}
+
+	wallet.Status = status
+	wallet.StatusReason = reason
+	wallet.UpdatedAt = r.now()
+
+	copied := *wallet
+	copied.Held = current.Held
+	return &copied, nil
+}
+
+func (r *MemoryWalletRepository) ListTransactions(ctx context.Context, walletID string, filter models.TransactionFilter) ([]models.Transaction, string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	history := r.transactions[strings.ToLower(walletID)]
+
+	transactions := make([]models.Transaction, 0, filter.Limit)
+	for _, t := range slices.Backward(history) {
+		if filter.OperationType != "" && t.OperationType != filter.OperationType {
+			continue
+		}
+		if !filter.From.IsZero() && t.CreatedAt.Before(filter.From) {
+			continue
+		}
+		if !filter.To.IsZero() && !t.CreatedAt.Before(filter.To) {
+			continue
+		}
+		if filter.After != nil && t.Sequence >= filter.After.Sequence {
+			continue
+		}
+
+		transactions = append(transactions, t)
+		if len(transactions) > filter.Limit {
+			break
+		}
+	}
+
+	var nextCursor string
+	if len(transactions) > filter.Limit {
+		transactions = transactions[:filter.Limit]
+		last := transactions[len(transactions)-1]
+		nextCursor = EncodeTransactionCursor(models.TransactionCursor{Sequence: last.Sequence})
+	}
+
+	return transactions, nextCursor, nil
+}
+
+func (r *MemoryWalletRepository) applyOperation(op models.WalletOperation) error {
+	wallet, ok := r.wallets[strings.ToLower(op.WalletID)]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrWalletNotFound, op.WalletID)
+	}
+	if err := r.checkCurrency(wallet, op.Currency); err != nil {
+		return err
+	}
+
+	var newBalance int64
+	switch op.OperationType {
+	case models.DEPOSIT:
+		if err := r.checkWalletStatus(wallet, models.DEPOSIT); err != nil {
+			return err
+		}
+		newBalance = wallet.Balance + op.Amount
+	case models.WITHDRAW:
+		if err := r.checkWalletStatus(wallet, models.WITHDRAW); err != nil {
+			return err
+		}
+		newBalance = wallet.Balance - op.Amount
+		if newBalance < r.heldAmount(wallet.ID) {
+			return ErrInsufficientFunds
+		}
+		if err := r.checkLimits(wallet.ID, op.Amount); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%w: invalid operation type %q", ErrInvalidOperation, op.OperationType)
+	}
+
+	r.setBalance(wallet, newBalance, models.Transaction{OperationType: op.OperationType, Amount: op.Amount})
+	return nil
+}
+
+// setBalance changes the wallet balance and appends entry to its ledger,
+// filling in the fields that come from the wallet itself, together with the
+// matching outbox event.
+func (r *MemoryWalletRepository) setBalance(wallet *models.Wallet, newBalance int64, entry models.Transaction) {
+	now := r.now()
+	entry.ID = newUUID()
+	entry.WalletID = wallet.ID
+	entry.SetBalances(wallet.Balance, newBalance)
+	entry.CreatedAt = now
+	r.ledgerSequence++
+	entry.Sequence = r.ledgerSequence
+	r.transactions[wallet.ID] = append(r.transactions[wallet.ID], entry)
+	for _, posting := range journalPostings(entry, wallet.Currency) {
+		posting.ID = newUUID()
+		r.journal = append(r.journal, posting)
+	}
+	r.addEvent(entry)
+
+	wallet.Balance = newBalance
+	wallet.UpdatedAt = now
+}
+
+// now returns a strictly increasing timestamp. Unlike Postgres, where every
+// entry of one transaction gets the same NOW(), each entry here gets its own;
+// both stores order the ledger by Transaction.Sequence, not by timestamp.
+func (r *MemoryWalletRepository) now() time.Time {
+	now := time.Now().UTC()
+	if !now.After(r.lastTimestamp) {
+		now = r.lastTimestamp.Add(time.Microsecond)
+	}
+	r.lastTimestamp = now
+	return now
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/NKV510/wallet-service/internal/models"
+)
+
+type apiKeyRecord struct {
+	key  models.APIKey
+	hash string
+}
+
+func (r *MemoryWalletRepository) CreateAPIKey(ctx context.Context, key models.APIKey, keyHash string) (*models.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, record := range r.apiKeys {
+		if record.hash == keyHash {
+			return nil, fmt.Errorf("failed to create api key: %w", ErrConflict)
+		}
+	}
+
+	key.ID = newUUID()
+	key.CreatedAt = r.now()
+	key.RevokedAt = nil
+	key.Scopes = slices.Clone(key.Scopes)
+	key.WalletIDs = slices.Clone(key.WalletIDs)
+	if key.WalletIDs == nil {
+		key.WalletIDs = []string{}
+	}
+	r.apiKeys[key.ID] = &apiKeyRecord{key: key, hash: keyHash}
+
+	return copyAPIKey(key), nil
+}
+
+func (r *MemoryWalletRepository) GetAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, record := range r.apiKeys {
+		if record.hash == keyHash {
+			return copyAPIKey(record.key), nil
+		}
+	}
+	return nil, ErrAPIKeyNotFound
+}
+
+func (r *MemoryWalletRepository) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := make([]models.APIKey, 0, len(r.apiKeys))
+	for _, record := range r.apiKeys {
+		keys = append(keys, *copyAPIKey(record.key))
+	}
+	slices.SortFunc(keys, func(a, b models.APIKey) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return keys, nil
+}
+
+func (r *MemoryWalletRepository) RevokeAPIKey(ctx context.Context, id string) (*models.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record, ok := r.apiKeys[strings.ToLower(id)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrAPIKeyNotFound, id)
+	}
+	if record.key.RevokedAt == nil {
+		now := r.now()
+		record.key.RevokedAt = &now
+	}
+	return copyAPIKey(record.key), nil
+}
+
+func copyAPIKey(key models.APIKey) *models.APIKey {
+	key.Scopes = slices.Clone(key.Scopes)
+	key.WalletIDs = slices.Clone(key.WalletIDs)
+	return &key
+}
@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NKV510/wallet-service/internal/models"
+)
+
+// batchSnapshot holds what applying a list of operations may change, so
+// that a failed batch can be undone. A nil wallet or key did not exist.
+type batchSnapshot struct {
+	wallets        map[string]*models.Wallet
+	transactions   map[string]int
+	keys           map[string]*idempotencyRecord
+	journal        int
+	outbox         int
+	outboxSequence int64
+}
+
+func (r *MemoryWalletRepository) ApplyBatch(ctx context.Context, mode models.BatchMode, operations []models.WalletOperation) ([]models.BatchItemResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	results := newBatchResults(operations)
+	switch mode {
+	case models.BatchAtomic:
+		snapshot := r.snapshot(operations)
+		for i, op := range operations {
+			status, balance, err := r.applyBatchOperation(op)
+			if err != nil {
+				r.restore(snapshot)
+				r.observeOperation(op.OperationType, op.Amount, err)
+				failBatch(results, i, err)
+				return results, fmt.Errorf("operation %d: %w", i, err)
+			}
+			setBatchResult(&results[i], status, balance)
+		}
+		for i, op := range operations {
+			if results[i].Status == models.BatchItemApplied {
+				r.observeOperation(op.OperationType, op.Amount, nil)
+			}
+		}
+		return results, nil
+	case models.BatchIndependent:
+		for i, op := range operations {
+			status, balance, err := r.processOperation(op)
+			if err != nil {
+				results[i].Status = models.BatchItemFailed
+				results[i].Err = err
+				continue
+			}
+			setBatchResult(&results[i], status, balance)
+		}
+		return results, nil
+	default:
+		return nil, fmt.Errorf("%w: invalid batch mode %q", ErrInvalidOperation, mode)
+	}
+}
+
+func (r *MemoryWalletRepository) ProcessOperation(ctx context.Context, operation models.WalletOperation) (int64, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status, balance, err := r.processOperation(operation)
+	return balance, status == models.BatchItemReplayed, err
+}
+
+// processOperation applies op on its own, leaving no trace if it fails.
+func (r *MemoryWalletRepository) processOperation(op models.WalletOperation) (models.BatchItemStatus, int64, error) {
+	snapshot := r.snapshot([]models.WalletOperation{op})
+	status, balance, err := r.applyBatchOperation(op)
+	if status != models.BatchItemReplayed {
+		r.observeOperation(op.OperationType, op.Amount, err)
+	}
+	if err != nil {
+		r.restore(snapshot)
+		return "", 0, err
+	}
+	return status, balance, nil
+}
+
+func (r *MemoryWalletRepository) applyBatchOperation(op models.WalletOperation) (models.BatchItemStatus, int64, error) {
+	wallet, ok := r.wallets[strings.ToLower(op.WalletID)]
+	if !ok {
+		wallet = r.addWallet(strings.ToLower(op.WalletID), op.Currency)
+	}
+
+	requestHash := operationHash(op)
+	if op.IdempotencyKey != "" {
+		if record, ok := r.idempotencyKeys[op.IdempotencyKey]; ok && record.expiresAt.After(time.Now()) {
+			if record.requestHash != requestHash {
+				return "", 0, ErrIdempotencyKeyReused
+			}
+			return models.BatchItemReplayed, record.balance, nil
+		}
+	}
+
+	if err := r.applyOperation(op); err != nil {
+		return "", 0, err
+	}
+
+	if op.IdempotencyKey != "" {
+		r.idempotencyKeys[op.IdempotencyKey] = idempotencyRecord{
+			requestHash: requestHash,
+			balance:     wallet.Balance,
+			expiresAt:   time.Now().Add(r.idempotencyTTL),
+		}
+	}
+	return models.BatchItemApplied, wallet.Balance, nil
+}
+
+func (r *MemoryWalletRepository) snapshot(operations []models.WalletOperation) *batchSnapshot {
+	s := &batchSnapshot{
+		wallets:        make(map[string]*models.Wallet),
+		transactions:   make(map[string]int),
+		keys:           make(map[string]*idempotencyRecord),
+		journal:        len(r.journal),
+		outbox:         len(r.outbox),
+		outboxSequence: r.outboxSequence,
+	}
+	for _, op := range operations {
+		walletID := strings.ToLower(op.WalletID)
+		if wallet, ok := r.wallets[walletID]; ok {
+			copied := *wallet
+			s.wallets[walletID] = &copied
+		} else {
+			s.wallets[walletID] = nil
+		}
+		s.transactions[walletID] = len(r.transactions[walletID])
+
+		if op.IdempotencyKey != "" {
+			if record, ok := r.idempotencyKeys[op.IdempotencyKey]; ok {
+				s.keys[op.IdempotencyKey] = &record
+			} else {
+				s.keys[op.IdempotencyKey] = nil
+			}
+		}
+	}
+	return s
+}
+
+// restore undoes the changes made since s was taken.
+func (r *MemoryWalletRepository) restore(s *batchSnapshot) {
+	for walletID, wallet := range s.wallets {
+		if wallet == nil {
+			delete(r.wallets, walletID)
+			delete(r.transactions, walletID)
+			continue
+		}
+		*r.wallets[walletID] = *wallet
+		r.transactions[walletID] = r.transactions[walletID][:s.transactions[walletID]]
+	}
+	for key, record := range s.keys {
+		if record == nil {
+			delete(r.idempotencyKeys, key)
+			continue
+		}
+		r.idempotencyKeys[key] = *record
+	}
+	r.journal = r.journal[:s.journal]
+	r.outbox = r.outbox[:s.outbox]
+	r.outboxSequence = s.outboxSequence
+}
@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/NKV510/wallet-service/internal/models"
+)
+
+type exchangeQuoteRecord struct {
+	quote models.ExchangeQuote
+	used  bool
+}
+
+func exchangeRateKey(from, to string) string {
+	return from + "/" + to
+}
+
+func (r *MemoryWalletRepository) GetExchangeRate(ctx context.Context, from, to string) (*models.ExchangeRate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rate, ok := r.exchangeRates[exchangeRateKey(from, to)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s to %s", ErrRateNotFound, from, to)
+	}
+	return &rate, nil
+}
+
+func (r *MemoryWalletRepository) ListExchangeRates(ctx context.Context) ([]models.ExchangeRate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rates := make([]models.ExchangeRate, 0, len(r.exchangeRates))
+	for _, rate := range r.exchangeRates {
+		rates = append(rates, rate)
+	}
+	slices.SortFunc(rates, func(a, b models.ExchangeRate) int {
+		return strings.Compare(exchangeRateKey(a.From, a.To), exchangeRateKey(b.From, b.To))
+	})
+	return rates, nil
+}
+
+func (r *MemoryWalletRepository) SetExchangeRate(ctx context.Context, rate models.ExchangeRate) (*models.ExchangeRate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rate.UpdatedAt = r.now()
+	r.exchangeRates[exchangeRateKey(rate.From, rate.To)] = rate
+	return &rate, nil
+}
+
+func (r *MemoryWalletRepository) CreateExchangeQuote(ctx context.Context, quote models.ExchangeQuote, ttl time.Duration) (*models.ExchangeQuote, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	created := models.ExchangeQuote{
+		ID:           newUUID(),
+		FromCurrency: quote.FromCurrency,
+		ToCurrency:   quote.ToCurrency,
+		Rate:         quote.Rate,
+		ExpiresAt:    time.Now().Add(ttl),
+	}
+	r.exchangeQuotes[created.ID] = &exchangeQuoteRecord{quote: created}
+	return &created, nil
+}
+
+func (r *MemoryWalletRepository) GetExchangeQuote(ctx context.Context, id string) (*models.ExchangeQuote, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record, ok := r.exchangeQuotes[strings.ToLower(id)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrQuoteNotFound, id)
+	}
+	quote := record.quote
+	return &quote, nil
+}
+
+func (r *MemoryWalletRepository) DeleteExpiredExchangeQuotes(ctx context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int64
+	now := time.Now()
+	for id, record := range r.exchangeQuotes {
+		if !record.quote.ExpiresAt.After(now) {
+			delete(r.exchangeQuotes, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (r *MemoryWalletRepository) Exchange(ctx context.Context, exchange models.Exchange) (*models.ExchangeResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fromWalletID := strings.ToLower(exchange.FromWalletID)
+	toWalletID := strings.ToLower(exchange.ToWalletID)
+	if err := checkExchange(fromWalletID, toWalletID, exchange); err != nil {
+		return nil, err
+	}
+
+	var quote *exchangeQuoteRecord
+	if exchange.QuoteID != "" {
+		var ok bool
+		quote, ok = r.exchangeQuotes[strings.ToLower(exchange.QuoteID)]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrQuoteNotFound, exchange.QuoteID)
+		}
+		if quote.used || !quote.quote.ExpiresAt.After(time.Now()) {
+			return nil, ErrQuoteExpired
+		}
+	}
+
+	from, ok := r.wallets[fromWalletID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrWalletNotFound, fromWalletID)
+	}
+	to, ok := r.wallets[toWalletID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrWalletNotFound, toWalletID)
+	}
+	if err := r.checkCurrency(from, exchange.FromCurrency); err != nil {
+		return nil, err
+	}
+	if err := r.checkCurrency(to, exchange.ToCurrency); err != nil {
+		return nil, err
+	}
+	if err := r.checkWalletStatus(from, models.WITHDRAW); err != nil {
+		return nil, err
+	}
+	if err := r.checkWalletStatus(to, models.DEPOSIT); err != nil {
+		return nil, err
+	}
+	if from.Balance-r.heldAmount(from.ID) < exchange.Debit {
+		return nil, ErrInsufficientFunds
+	}
+	if err := r.checkLimits(from.ID, exchange.Debit); err != nil {
+		return nil, err
+	}
+
+	if quote != nil {
+		quote.used = true
+	}
+	exchangeID := newUUID()
+	r.setBalance(from, from.Balance-exchange.Debit, models.Transaction{OperationType: models.EXCHANGE, Amount: exchange.Debit, ExchangeID: exchangeID})
+	r.setBalance(to, to.Balance+exchange.Credit, models.Transaction{OperationType: models.EXCHANGE, Amount: exchange.Credit, ExchangeID: exchangeID})
+
+	return exchangeResponse(exchangeID, fromWalletID, toWalletID, exchange, from.Balance, to.Balance), nil
+}
@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NKV510/wallet-service/internal/models"
+)
+
+func (r *MemoryWalletRepository) CreateHold(ctx context.Context, walletID string, amount int64, ttl time.Duration) (*models.Hold, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if amount <= 0 {
+		return nil, fmt.Errorf("%w: amount must be positive", ErrInvalidOperation)
+	}
+	if ttl <= 0 {
+		ttl = r.holdTTL
+	}
+
+	wallet, ok := r.wallets[strings.ToLower(walletID)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrWalletNotFound, walletID)
+	}
+	if err := r.checkWalletStatus(wallet, models.WITHDRAW); err != nil {
+		return nil, err
+	}
+	if wallet.Balance-r.heldAmount(wallet.ID) < amount {
+		return nil, ErrInsufficientFunds
+	}
+
+	now := r.now()
+	hold := &models.Hold{
+		ID:        newUUID(),
+		WalletID:  wallet.ID,
+		Amount:    amount,
+		Status:    models.HoldActive,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	r.holds[hold.ID] = hold
+
+	copied := *hold
+	return &copied, nil
+}
+
+func (r *MemoryWalletRepository) CaptureHold(ctx context.Context, walletID, holdID string, amount int64) (*models.Hold, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if amount < 0 {
+		return nil, fmt.Errorf("%w: amount must be positive", ErrInvalidOperation)
+	}
+
+	wallet, ok := r.wallets[strings.ToLower(walletID)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrWalletNotFound, walletID)
+	}
+	if err := r.checkWalletStatus(wallet, models.WITHDRAW); err != nil {
+		return nil, err
+	}
+
+	hold, err := r.activeHold(wallet.ID, holdID)
+	if err != nil {
+		return nil, err
+	}
+
+	if amount == 0 {
+		amount = hold.Amount
+	}
+	if amount > hold.Amount {
+		return nil, fmt.Errorf("%w: capture amount exceeds hold amount %d", ErrInvalidOperation, hold.Amount)
+	}
+	if err := r.checkLimits(wallet.ID, amount); err != nil {
+		return nil, err
+	}
+	if wallet.Balance < amount {
+		return nil, ErrInsufficientFunds
+	}
+
+	r.setBalance(wallet, wallet.Balance-amount, models.Transaction{
+		OperationType: models.WITHDRAW,
+		Amount:        amount,
+		HoldID:        hold.ID,
+	})
+
+	hold.Status = models.HoldCaptured
+	hold.CapturedAmount = amount
+	hold.UpdatedAt = r.now()
+
+	copied := *hold
+	return &copied, nil
+}
+
+func (r *MemoryWalletRepository) VoidHold(ctx context.Context, walletID, holdID string) (*models.Hold, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	hold, err := r.activeHold(strings.ToLower(walletID), holdID)
+	if err != nil {
+		return nil, err
+	}
+
+	hold.Status = models.HoldVoided
+	hold.UpdatedAt = r.now()
+
+	copied := *hold
+	return &copied, nil
+}
+
+func (r *MemoryWalletRepository) ExpireHolds(ctx context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var expired int64
+	now := time.Now()
+	for _, hold := range r.holds {
+		if hold.Status == models.HoldActive && !hold.ExpiresAt.After(now) {
+			hold.Status = models.HoldExpired
+			hold.UpdatedAt = r.now()
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+func (r *MemoryWalletRepository) activeHold(walletID, holdID string) (*models.Hold, error) {
+	hold, ok := r.holds[strings.ToLower(holdID)]
+	if !ok || hold.WalletID != walletID {
+		return nil, fmt.Errorf("%w: %s", ErrHoldNotFound, holdID)
+	}
+
+	status := hold.Status
+	if status == models.HoldActive && !hold.ExpiresAt.After(time.Now()) {
+		status = models.HoldExpired
+	}
+	if status != models.HoldActive {
+		return nil, fmt.Errorf("%w: hold is %s", ErrConflict, status)
+	}
+	return hold, nil
+}
+
+func (r *MemoryWalletRepository) heldAmount(walletID string) int64 {
+	var held int64
+	now := time.Now()
+	for _, hold := range r.holds {
+		if hold.WalletID == walletID && hold.Status == models.HoldActive && hold.ExpiresAt.After(now) {
+			held += hold.Amount
+		}
+	}
+	return held
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/NKV510/wallet-service/internal/models"
+)
+
+func (r *MemoryWalletRepository) TrialBalance(ctx context.Context) (*models.TrialBalance, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	type key struct{ account, currency string }
+	sums := make(map[key]*models.AccountBalance)
+	accounts := []models.AccountBalance{}
+	for _, entry := range r.journal {
+		account := entry.Account
+		if strings.HasPrefix(account, models.WalletAccount("")) {
+			account = models.AccountWallets
+		}
+
+		k := key{account, entry.Currency}
+		sum, ok := sums[k]
+		if !ok {
+			sum = &models.AccountBalance{Account: account, Currency: entry.Currency}
+			sums[k] = sum
+		}
+		sum.Debit += entry.Debit
+		sum.Credit += entry.Credit
+	}
+	for _, sum := range sums {
+		accounts = append(accounts, *sum)
+	}
+	return newTrialBalance(accounts), nil
+}
+
+func (r *MemoryWalletRepository) GetJournal(ctx context.Context, journalID string) ([]models.JournalEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id, ok := models.NormalizeID(journalID)
+	var entries []models.JournalEntry
+	for _, entry := range r.journal {
+		if ok && entry.JournalID == id {
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrJournalNotFound, journalID)
+	}
+	return entries, nil
+}
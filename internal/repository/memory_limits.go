@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NKV510/wallet-service/internal/models"
+)
+
+func (r *MemoryWalletRepository) GetWalletLimits(ctx context.Context, walletID string) (*models.WalletLimitsResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	wallet, ok := r.wallets[strings.ToLower(walletID)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrWalletNotFound, walletID)
+	}
+	return r.walletLimits(wallet.ID), nil
+}
+
+func (r *MemoryWalletRepository) SetWalletLimits(ctx context.Context, walletID string, override models.WithdrawalLimitsOverride) (*models.WalletLimitsResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := validateLimitsOverride(override); err != nil {
+		return nil, err
+	}
+
+	wallet, ok := r.wallets[strings.ToLower(walletID)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrWalletNotFound, walletID)
+	}
+
+	r.limits[wallet.ID] = override
+	return r.walletLimits(wallet.ID), nil
+}
+
+func (r *MemoryWalletRepository) checkLimits(walletID string, amount int64) error {
+	// Summing the ledger is only worth it when some limit applies.
+	if effectiveLimits(r.withdrawalLimits, r.limits[walletID]) == (models.WithdrawalLimits{}) {
+		return nil
+	}
+	limits := r.walletLimits(walletID)
+	return checkWithdrawalLimits(limits.Limits, amount, limits.DailyUsed, limits.MonthlyUsed)
+}
+
+func (r *MemoryWalletRepository) walletLimits(walletID string) *models.WalletLimitsResponse {
+	override := r.limits[walletID]
+	limits := &models.WalletLimitsResponse{
+		WalletID: walletID,
+		Limits:   effectiveLimits(r.withdrawalLimits, override),
+		Override: override,
+	}
+
+	now := time.Now()
+	for _, t := range r.transactions[walletID] {
+		if t.BalanceChange >= 0 || t.OperationType == models.ADJUSTMENT || t.OperationType == models.OPENING {
+			continue
+		}
+		if t.CreatedAt.After(now.Add(-dailyLimitWindow)) {
+			limits.DailyUsed += t.Amount
+		}
+		if t.CreatedAt.After(now.Add(-monthlyLimitWindow)) {
+			limits.MonthlyUsed += t.Amount
+		}
+	}
+	return limits
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/NKV510/wallet-service/internal/models"
+)
+
+type outboxRecord struct {
+	event       models.Event
+	publishedAt *time.Time
+}
+
+// addEvent appends a wallet.balance_changed event for entry. The caller holds
+// r.mu.
+func (r *MemoryWalletRepository) addEvent(entry models.Transaction) {
+	payload, _ := json.Marshal(entry)
+	r.outboxSequence++
+	r.outbox = append(r.outbox, &outboxRecord{event: models.Event{
+		ID:        newUUID(),
+		Sequence:  r.outboxSequence,
+		Type:      models.EventBalanceChanged,
+		WalletID:  entry.WalletID,
+		Payload:   payload,
+		CreatedAt: entry.CreatedAt,
+	}})
+}
+
+// RelayOutbox publishes without holding r.mu, so a slow publisher does not
+// block wallet operations. relayMu keeps relays from overlapping.
+func (r *MemoryWalletRepository) RelayOutbox(ctx context.Context, limit int, publish PublishFunc) (int64, error) {
+	r.relayMu.Lock()
+	defer r.relayMu.Unlock()
+
+	r.mu.Lock()
+	var pending []*outboxRecord
+	for _, record := range r.outbox {
+		if len(pending) == limit {
+			break
+		}
+		if record.publishedAt == nil {
+			pending = append(pending, record)
+		}
+	}
+	r.mu.Unlock()
+
+	var published []*outboxRecord
+	var publishErr error
+	for _, record := range pending {
+		if err := publish(ctx, record.event); err != nil {
+			publishErr = fmt.Errorf("failed to publish event %d: %w", record.event.Sequence, err)
+			break
+		}
+		published = append(published, record)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	for _, record := range published {
+		record.publishedAt = &now
+	}
+	return int64(len(published)), publishErr
+}
+
+func (r *MemoryWalletRepository) DeletePublishedEvents(ctx context.Context, retention time.Duration) (int64, error) {
+	r.relayMu.Lock()
+	defer r.relayMu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cutoff := time.Now().Add(-retention)
+	kept := r.outbox[:0]
+	for _, record := range r.outbox {
+		if record.publishedAt == nil || record.publishedAt.After(cutoff) {
+			kept = append(kept, record)
+		}
+	}
+	deleted := int64(len(r.outbox) - len(kept))
+	clear(r.outbox[len(kept):])
+	r.outbox = kept
+	return deleted, nil
+}
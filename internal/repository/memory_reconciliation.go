@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/NKV510/wallet-service/internal/models"
+)
+
+func (r *MemoryWalletRepository) FindBalanceDrift(ctx context.Context) (int64, []models.BalanceDrift, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	drifts := []models.BalanceDrift{}
+	for _, wallet := range r.wallets {
+		if expected := r.ledgerTotal(wallet.ID); expected != wallet.Balance {
+			drifts = append(drifts, newBalanceDrift(wallet.ID, expected, wallet.Balance))
+		}
+	}
+	slices.SortFunc(drifts, func(a, b models.BalanceDrift) int {
+		return strings.Compare(a.WalletID, b.WalletID)
+	})
+	return int64(len(r.wallets)), drifts, nil
+}
+
+func (r *MemoryWalletRepository) CorrectBalanceDrift(ctx context.Context, walletID string) (*models.BalanceDrift, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	wallet, ok := r.wallets[strings.ToLower(walletID)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrWalletNotFound, walletID)
+	}
+	expected := r.ledgerTotal(wallet.ID)
+	if expected == wallet.Balance {
+		return nil, nil
+	}
+
+	drift := newBalanceDrift(wallet.ID, expected, wallet.Balance)
+	// setBalance records the entry as a change from the current balance,
+	// which for an adjustment is the expected one; the balance ends where
+	// it was.
+	wallet.Balance = drift.Expected
+	r.setBalance(wallet, drift.Actual, adjustmentEntry(drift))
+	drift.Corrected = true
+	return &drift, nil
+}
+
+func (r *MemoryWalletRepository) SaveReconciliationReport(ctx context.Context, report models.ReconciliationReport) (*models.ReconciliationReport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report.ID = newUUID()
+	report.Drifts = slices.Clone(report.Drifts)
+	r.reconciliations = append(r.reconciliations, report)
+	return &report, nil
+}
+
+func (r *MemoryWalletRepository) LastReconciliationReport(ctx context.Context) (*models.ReconciliationReport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.reconciliations) == 0 {
+		return nil, ErrNoReconciliation
+	}
+	report := r.reconciliations[len(r.reconciliations)-1]
+	return &report, nil
+}
+
+// ledgerTotal is the balance the wallet's ledger entries add up to.
+func (r *MemoryWalletRepository) ledgerTotal(walletID string) int64 {
+	var total int64
+	for _, entry := range r.transactions[walletID] {
+		total += entry.BalanceChange
+	}
+	return total
+}
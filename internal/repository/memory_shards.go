@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/NKV510/wallet-service/internal/models"
+)
+
+// SetWalletShards only records the shard count: the memory store serializes
+// every operation on one mutex anyway, so a sharded wallet behaves like any
+// other.
+func (r *MemoryWalletRepository) SetWalletShards(ctx context.Context, walletID string, shards int) (*models.Wallet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := validateShardCount(shards); err != nil {
+		return nil, err
+	}
+
+	wallet, ok := r.wallets[strings.ToLower(walletID)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrWalletNotFound, walletID)
+	}
+
+	wallet.Shards = shards
+	wallet.UpdatedAt = r.now()
+
+	copied := *wallet
+	copied.Held = r.heldAmount(wallet.ID)
+	return &copied, nil
+}
+
+func (r *MemoryWalletRepository) CompactWalletShards(ctx context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var compacted int64
+	for _, wallet := range r.wallets {
+		if wallet.Shards > 0 {
+			compacted++
+		}
+	}
+	return compacted, nil
+}
@@ -0,0 +1,56 @@
+package repository
+
+import "testing"
+
+func TestMemoryWalletRepository(t *testing.T) {
+	runWalletStoreSuite(t, func(t *testing.T, opts ...Option) WalletStore {
+		return NewMemoryWalletRepository(opts...)
+	})
+}
+
+func TestMemoryAPIKeyStore(t *testing.T) {
+	runAPIKeyStoreSuite(t, func(t *testing.T) APIKeyStore {
+		return NewMemoryWalletRepository()
+	})
+}
+
+func TestMemoryWebhookStore(t *testing.T) {
+	runWebhookStoreSuite(t, func(t *testing.T) WebhookStore {
+		return NewMemoryWalletRepository()
+	})
+}
+
+func TestMemoryExchangeStore(t *testing.T) {
+	runExchangeStoreSuite(t, func(t *testing.T) exchangeTestStore {
+		return NewMemoryWalletRepository()
+	})
+}
+
+func TestMemoryLedgerStore(t *testing.T) {
+	runLedgerStoreSuite(t, func(t *testing.T) ledgerTestStore {
+		return NewMemoryWalletRepository()
+	})
+}
+
+func TestMemoryReconciliationStore(t *testing.T) {
+	runReconciliationStoreSuite(t, func(t *testing.T) (reconciliationTestStore, func(string, int64)) {
+		repo := NewMemoryWalletRepository()
+		return repo, func(walletID string, balance int64) {
+			repo.mu.Lock()
+			defer repo.mu.Unlock()
+			repo.wallets[walletID].Balance = balance
+		}
+	})
+}
+
+func TestMemoryShardStore(t *testing.T) {
+	runShardStoreSuite(t, func(t *testing.T) shardTestStore {
+		return NewMemoryWalletRepository()
+	})
+}
+
+func BenchmarkMemoryWalletRepository_ProcessOperation(b *testing.B) {
+	benchmarkProcessOperation(b, func(b *testing.B) shardTestStore {
+		return NewMemoryWalletRepository()
+	})
+}
@@ -0,0 +1,267 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/NKV510/wallet-service/internal/models"
+)
+
+type webhookRecord struct {
+	webhook models.WebhookSubscription
+	secret  string
+}
+
+func (r *MemoryWalletRepository) CreateWebhook(ctx context.Context, webhook models.WebhookSubscription, secret string) (*models.WebhookSubscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	webhook.ID = newUUID()
+	webhook.CreatedAt = now
+	webhook.UpdatedAt = now
+	webhook = copyWebhook(webhook)
+	r.webhooks[webhook.ID] = &webhookRecord{webhook: webhook, secret: secret}
+
+	copied := copyWebhook(webhook)
+	return &copied, nil
+}
+
+func (r *MemoryWalletRepository) GetWebhook(ctx context.Context, id string) (*models.WebhookSubscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record, ok := r.webhooks[strings.ToLower(id)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrWebhookNotFound, id)
+	}
+	copied := copyWebhook(record.webhook)
+	return &copied, nil
+}
+
+func (r *MemoryWalletRepository) ListWebhooks(ctx context.Context) ([]models.WebhookSubscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	webhooks := make([]models.WebhookSubscription, 0, len(r.webhooks))
+	for _, record := range r.webhooks {
+		webhooks = append(webhooks, copyWebhook(record.webhook))
+	}
+	slices.SortFunc(webhooks, func(a, b models.WebhookSubscription) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return webhooks, nil
+}
+
+func (r *MemoryWalletRepository) UpdateWebhook(ctx context.Context, webhook models.WebhookSubscription, secret string) (*models.WebhookSubscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record, ok := r.webhooks[strings.ToLower(webhook.ID)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrWebhookNotFound, webhook.ID)
+	}
+
+	webhook.ID = record.webhook.ID
+	webhook.CreatedAt = record.webhook.CreatedAt
+	webhook.UpdatedAt = r.now()
+	record.webhook = copyWebhook(webhook)
+	if secret != "" {
+		record.secret = secret
+	}
+
+	copied := copyWebhook(record.webhook)
+	return &copied, nil
+}
+
+func (r *MemoryWalletRepository) DeleteWebhook(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id = strings.ToLower(id)
+	if _, ok := r.webhooks[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrWebhookNotFound, id)
+	}
+	delete(r.webhooks, id)
+	r.deliveries = slices.DeleteFunc(r.deliveries, func(d *models.WebhookDelivery) bool {
+		return d.WebhookID == id
+	})
+	return nil
+}
+
+func (r *MemoryWalletRepository) EnqueueWebhookDeliveries(ctx context.Context, delivery models.WebhookDelivery) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	walletID := strings.ToLower(delivery.WalletID)
+	var created int64
+	for _, record := range r.webhooks {
+		webhook := record.webhook
+		if !webhook.Active || !slices.Contains(webhook.EventTypes, delivery.EventType) {
+			continue
+		}
+		if len(webhook.WalletIDs) > 0 && !slices.Contains(webhook.WalletIDs, walletID) {
+			continue
+		}
+		duplicate := slices.ContainsFunc(r.deliveries, func(d *models.WebhookDelivery) bool {
+			return d.WebhookID == webhook.ID && d.EventID == delivery.EventID
+		})
+		if duplicate {
+			continue
+		}
+
+		now := r.now()
+		d := delivery
+		d.ID = newUUID()
+		d.WebhookID = webhook.ID
+		d.WalletID = walletID
+		d.Payload = slices.Clone(delivery.Payload)
+		d.Status = models.DeliveryPending
+		d.Attempts = 0
+		d.NextAttemptAt = &now
+		d.LastAttemptAt = nil
+		d.LastStatusCode = 0
+		d.LastError = ""
+		d.CreatedAt = now
+		d.UpdatedAt = now
+		r.deliveries = append(r.deliveries, &d)
+		created++
+	}
+	return created, nil
+}
+
+func (r *MemoryWalletRepository) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]WebhookDeliveryTask, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	var due []*models.WebhookDelivery
+	for _, d := range r.deliveries {
+		record, ok := r.webhooks[d.WebhookID]
+		if d.Status != models.DeliveryPending || d.NextAttemptAt.After(now) || !ok || !record.webhook.Active {
+			continue
+		}
+		due = append(due, d)
+	}
+	slices.SortStableFunc(due, func(a, b *models.WebhookDelivery) int {
+		return a.NextAttemptAt.Compare(*b.NextAttemptAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	leaseUntil := now.Add(lease)
+	tasks := make([]WebhookDeliveryTask, 0, len(due))
+	for _, d := range due {
+		d.NextAttemptAt = &leaseUntil
+		record := r.webhooks[d.WebhookID]
+		tasks = append(tasks, WebhookDeliveryTask{
+			WebhookDelivery: copyDelivery(d),
+			URL:             record.webhook.URL,
+			Secret:          record.secret,
+		})
+	}
+	return tasks, nil
+}
+
+func (r *MemoryWalletRepository) RecordWebhookAttempt(ctx context.Context, deliveryID string, attempt WebhookAttempt) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d := r.findDelivery(deliveryID)
+	if d == nil {
+		return fmt.Errorf("%w: %s", ErrDeliveryNotFound, deliveryID)
+	}
+
+	now := r.now()
+	d.Status = attempt.Status
+	d.Attempts++
+	d.NextAttemptAt = nil
+	if attempt.Status == models.DeliveryPending {
+		next := attempt.NextAttemptAt
+		d.NextAttemptAt = &next
+	}
+	d.LastAttemptAt = &now
+	d.LastStatusCode = attempt.StatusCode
+	d.LastError = attempt.Error
+	d.UpdatedAt = now
+	return nil
+}
+
+func (r *MemoryWalletRepository) ListWebhookDeliveries(ctx context.Context, webhookID string, filter models.WebhookDeliveryFilter) ([]models.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	webhookID = strings.ToLower(webhookID)
+	if _, ok := r.webhooks[webhookID]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrWebhookNotFound, webhookID)
+	}
+
+	deliveries := []models.WebhookDelivery{}
+	for i := len(r.deliveries) - 1; i >= 0 && len(deliveries) < filter.Limit; i-- {
+		d := r.deliveries[i]
+		if d.WebhookID != webhookID || (filter.Status != "" && d.Status != filter.Status) {
+			continue
+		}
+		deliveries = append(deliveries, copyDelivery(d))
+	}
+	return deliveries, nil
+}
+
+func (r *MemoryWalletRepository) RedeliverWebhook(ctx context.Context, webhookID, deliveryID string) (*models.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d := r.findDelivery(deliveryID)
+	if d == nil || d.WebhookID != strings.ToLower(webhookID) {
+		return nil, fmt.Errorf("%w: %s", ErrDeliveryNotFound, deliveryID)
+	}
+
+	now := r.now()
+	d.Status = models.DeliveryPending
+	d.Attempts = 0
+	d.NextAttemptAt = &now
+	d.UpdatedAt = now
+
+	copied := copyDelivery(d)
+	return &copied, nil
+}
+
+func (r *MemoryWalletRepository) findDelivery(id string) *models.WebhookDelivery {
+	id = strings.ToLower(id)
+	for _, d := range r.deliveries {
+		if d.ID == id {
+			return d
+		}
+	}
+	return nil
+}
+
+func copyWebhook(webhook models.WebhookSubscription) models.WebhookSubscription {
+	webhook.EventTypes = slices.Clone(webhook.EventTypes)
+	webhook.WalletIDs = slices.Clone(webhook.WalletIDs)
+	if webhook.WalletIDs == nil {
+		webhook.WalletIDs = []string{}
+	}
+	return webhook
+}
+
+func copyDelivery(d *models.WebhookDelivery) models.WebhookDelivery {
+	copied := *d
+	copied.Payload = slices.Clone(d.Payload)
+	if d.NextAttemptAt != nil {
+		next := *d.NextAttemptAt
+		copied.NextAttemptAt = &next
+	}
+	if d.LastAttemptAt != nil {
+		last := *d.LastAttemptAt
+		copied.LastAttemptAt = &last
+	}
+	return copied
+}
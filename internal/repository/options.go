@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/NKV510/wallet-service/internal/metrics"
+	"github.com/NKV510/wallet-service/internal/models"
+)
+
+const (
+	defaultIdempotencyTTL = 24 * time.Hour
+	defaultHoldTTL        = 15 * time.Minute
+	defaultCurrency       = "USD"
+)
+
+type options struct {
+	idempotencyTTL       time.Duration
+	holdTTL              time.Duration
+	frozenAllowsDeposits bool
+	withdrawalLimits     models.WithdrawalLimits
+	defaultCurrency      string
+	metrics              *metrics.Metrics
+}
+
+type Option func(*options)
+
+// WithIdempotencyTTL sets how long an idempotency key blocks replays.
+func WithIdempotencyTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.idempotencyTTL = ttl
+	}
+}
+
+// WithHoldTTL sets how long a hold reserves funds when the caller does not
+// ask for a specific duration.
+func WithHoldTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.holdTTL = ttl
+	}
+}
+
+// WithFrozenDeposits sets whether frozen wallets still accept deposits.
+// Withdrawals from frozen wallets are always rejected.
+func WithFrozenDeposits(allowed bool) Option {
+	return func(o *options) {
+		o.frozenAllowsDeposits = allowed
+	}
+}
+
+// WithWithdrawalLimits sets the limits for wallets without their own
+// override. Zero fields mean no limit.
+func WithWithdrawalLimits(limits models.WithdrawalLimits) Option {
+	return func(o *options) {
+		o.withdrawalLimits = limits
+	}
+}
+
+// WithDefaultCurrency sets the currency of wallets created without one.
+func WithDefaultCurrency(code string) Option {
+	return func(o *options) {
+		o.defaultCurrency = code
+	}
+}
+
+// WithMetrics records operation outcomes and lock wait times in m.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(o *options) {
+		o.metrics = m
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		idempotencyTTL:       defaultIdempotencyTTL,
+		holdTTL:              defaultHoldTTL,
+		frozenAllowsDeposits: true,
+		defaultCurrency:      defaultCurrency,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// walletCurrency returns the currency of a wallet created by an operation in
+// requested, which is empty when the operation names none.
+func (o options) walletCurrency(requested string) string {
+	if requested == "" {
+		return o.defaultCurrency
+	}
+	return requested
+}
+
+// checkCurrency rejects an operation in another currency than the wallet's.
+// Operations without a currency are not checked.
+func (o options) checkCurrency(wallet *models.Wallet, currency string) error {
+	if currency == "" || currency == wallet.Currency {
+		return nil
+	}
+	return fmt.Errorf("%w: wallet is in %s, operation is in %s", ErrCurrencyMismatch, wallet.Currency, currency)
+}
+
+// observeOperation records the outcome of a deposit or withdrawal.
+func (o options) observeOperation(operationType models.OperationType, amount int64, err error) {
+	o.metrics.ObserveOperation(string(operationType), operationOutcome(err), amount)
+}
+
+func operationOutcome(err error) string {
+	switch {
+	case err == nil:
+		return metrics.OutcomeSuccess
+	case errors.Is(err, ErrInsufficientFunds):
+		return metrics.OutcomeInsufficientFunds
+	case errors.Is(err, ErrWalletNotFound):
+		return metrics.OutcomeNotFound
+	case errors.Is(err, ErrLimitExceeded),
+		errors.Is(err, ErrWalletNotActive),
+		errors.Is(err, ErrCurrencyMismatch),
+		errors.Is(err, ErrInvalidOperation):
+		return metrics.OutcomeRejected
+	default:
+		return metrics.OutcomeError
+	}
+}
@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/NKV510/wallet-service/internal/models"
+)
+
+// PublishFunc delivers one outbox event. An error stops the relay batch; the
+// event and everything after it stay in the outbox for the next attempt.
+type PublishFunc func(ctx context.Context, event models.Event) error
+
+// OutboxStore gives the event relay access to the outbox that balance
+// changes write to.
+type OutboxStore interface {
+	// RelayOutbox hands up to limit unpublished events to publish, oldest
+	// first, and marks the ones it accepted as published. Events may be
+	// delivered more than once if the process dies in between.
+	RelayOutbox(ctx context.Context, limit int, publish PublishFunc) (int64, error)
+	// DeletePublishedEvents removes events published more than retention
+	// ago.
+	DeletePublishedEvents(ctx context.Context, retention time.Duration) (int64, error)
+}
+
+var (
+	_ OutboxStore = (*WalletRepository)(nil)
+	_ OutboxStore = (*MemoryWalletRepository)(nil)
+)
@@ -2,7 +2,14 @@ package repository
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/NKV510/wallet-service/internal/models"
 	"github.com/jackc/pgx/v5"
@@ -10,74 +17,681 @@ import (
 )
 
 type WalletRepository struct {
+	options
 	db *pgxpool.Pool
 }
 
-func NewWalletRepository(db *pgxpool.Pool) *WalletRepository {
-	return &WalletRepository{db: db}
+func NewWalletRepository(db *pgxpool.Pool, opts ...Option) *WalletRepository {
+	return &WalletRepository{
+		options: newOptions(opts),
+		db:      db,
+	}
 }
 
 func (r *WalletRepository) CreateWallet(ctx context.Context, walletID string) error {
-	query := `INSERT INTO wallets (id, balance) VALUES ($1, $2)`
-	_, err := r.db.Exec(ctx, query, walletID, 0)
-	return err
+	query := `INSERT INTO wallets (id, balance, currency) VALUES ($1, $2, $3)`
+	_, err := r.db.Exec(ctx, query, walletID, 0, r.defaultCurrency)
+	if err != nil {
+		return dbError("create wallet", err)
+	}
+	return nil
 }
 
 func (r *WalletRepository) GetWallet(ctx context.Context, walletID string) (*models.Wallet, error) {
-	query := `SELECT id, balance, created_at, updated_at FROM wallets WHERE id = $1`
+	query := `SELECT w.id, w.balance + ` + shardBalanceSubquery + `, ` + heldAmountSubquery + `, w.currency, w.status, w.status_reason,
+			w.shard_count, w.created_at, GREATEST(w.updated_at, (SELECT MAX(s.updated_at) FROM wallet_balance_shards s WHERE s.wallet_id = w.id))
+		FROM wallets w WHERE w.id = $1`
 
 	var wallet models.Wallet
 	err := r.db.QueryRow(ctx, query, walletID).Scan(
 		&wallet.ID,
 		&wallet.Balance,
+		&wallet.Held,
+		&wallet.Currency,
+		&wallet.Status,
+		&wallet.StatusReason,
+		&wallet.Shards,
 		&wallet.CreatedAt,
 		&wallet.UpdatedAt,
 	)
 
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("wallet not found")
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrWalletNotFound, walletID)
 		}
-		return nil, err
+		return nil, dbError("get wallet", err)
 	}
 
 	return &wallet, nil
 }
 
-func (r *WalletRepository) UpdateWalletBalance(ctx context.Context, walletID string, operationType models.OperationType, amount int64) error {
+func (r *WalletRepository) UpdateWalletBalance(ctx context.Context, walletID string, operationType models.OperationType, amount int64) (err error) {
+	defer func() { r.observeOperation(operationType, amount, err) }()
+
+	_, err = r.updateBalance(ctx, models.WalletOperation{WalletID: walletID, OperationType: operationType, Amount: amount}, false)
+	return err
+}
+
+// ProcessOperation applies a deposit or withdrawal the way POST
+// /api/v1/wallet does and returns the new balance. The first deposit creates
+// the wallet in the operation's currency; a wallet that does not exist yet
+// has nothing to withdraw. The idempotency key is optional, and replays
+// return the balance the original operation left. Without a key, the common
+// case is a single statement.
+func (r *WalletRepository) ProcessOperation(ctx context.Context, operation models.WalletOperation) (balance int64, replayed bool, err error) {
+	defer func() {
+		if !replayed {
+			r.observeOperation(operation.OperationType, operation.Amount, err)
+		}
+	}()
+
+	balance, replayed, err = r.processOperation(ctx, operation)
+	if operation.OperationType == models.WITHDRAW && errors.Is(err, ErrWalletNotFound) {
+		err = ErrInsufficientFunds
+	}
+	return balance, replayed, err
+}
+
+func (r *WalletRepository) processOperation(ctx context.Context, operation models.WalletOperation) (int64, bool, error) {
+	if operation.IdempotencyKey == "" {
+		balance, err := r.updateBalance(ctx, operation, true)
+		return balance, false, err
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, false, dbError("begin transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	claimed, stored, err := r.claimIdempotencyKey(ctx, tx, operation.IdempotencyKey, operationHash(operation))
+	if err != nil {
+		return 0, false, err
+	}
+	if !claimed {
+		balance, err := r.replayedBalance(ctx, tx, operation, stored)
+		return balance, true, err
+	}
+
+	balance, err := r.changeBalance(ctx, tx, operation, true)
+	if err != nil {
+		return 0, false, err
+	}
+	if err := storeIdempotentBalance(ctx, tx, operation.IdempotencyKey, balance); err != nil {
+		return 0, false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, false, dbError("commit transaction", err)
+	}
+	return balance, false, nil
+}
+
+// updateBalance applies the operation without locking the wallet row when it
+// can and in a locking transaction otherwise. With create, a deposit creates
+// the wallet if it does not exist.
+func (r *WalletRepository) updateBalance(ctx context.Context, op models.WalletOperation, create bool) (int64, error) {
+	balance, ok, err := r.updateBalanceUnlocked(ctx, r.db, op, create)
+	if err != nil || ok {
+		return balance, err
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, dbError("begin transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	balance, err = r.applyOperation(ctx, tx, op)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, dbError("commit transaction", err)
+	}
+	return balance, nil
+}
+
+// changeBalance is updateBalance inside tx.
+func (r *WalletRepository) changeBalance(ctx context.Context, tx pgx.Tx, op models.WalletOperation, create bool) (int64, error) {
+	balance, ok, err := r.updateBalanceUnlocked(ctx, tx, op, create)
+	if err != nil || ok {
+		return balance, err
+	}
+	return r.applyOperation(ctx, tx, op)
+}
+
+// updateBalanceUnlocked tries the ways to apply op that do not need
+// applyOperation: the single statement, then the shards of a sharded wallet,
+// then, with create, the upsert that creates the wallet on its first
+// deposit. The upsert comes last because it locks an existing row even when
+// it leaves it alone, which would queue deposits into a sharded wallet.
+func (r *WalletRepository) updateBalanceUnlocked(ctx context.Context, db shardDB, op models.WalletOperation, create bool) (int64, bool, error) {
+	balance, ok, err := r.updateBalanceStatement(ctx, db, op, false)
+	if err != nil || ok {
+		return balance, ok, err
+	}
+	balance, ok, err = r.updateShards(ctx, db, op)
+	if err != nil || ok || !create || op.OperationType != models.DEPOSIT {
+		return balance, ok, err
+	}
+	return r.updateBalanceStatement(ctx, db, op, true)
+}
+
+// The single-statement updates return the columns of the updated CTE of
+// balanceChangeQuery. They only match wallets whose
+// row alone shows the operation is allowed, including that it is in the
+// operation's currency unless that is empty: holds and limits live in other
+// tables, which a statement that waited for the row lock would read from a
+// snapshot taken before the wait, so wallets that have them are left to
+// applyOperation. Sharded wallets are left to updateShards.
+const (
+	depositUpsertQuery = `INSERT INTO wallets (id, balance, currency) VALUES ($1, $2, $7)
+		ON CONFLICT (id) DO UPDATE SET balance = wallets.balance + EXCLUDED.balance, updated_at = NOW()
+		WHERE (wallets.status = 'ACTIVE' OR (wallets.status = 'FROZEN' AND $5))
+			AND ($6 = '' OR wallets.currency = $6) AND wallets.shard_count = 0
+		RETURNING id, currency, balance, balance - $2 AS balance_before, balance AS balance_after, $2 AS balance_change`
+	depositQuery = `UPDATE wallets SET balance = balance + $2, updated_at = NOW()
+		WHERE id = $1 AND (status = 'ACTIVE' OR (status = 'FROZEN' AND $5)) AND ($6 = '' OR currency = $6)
+			AND shard_count = 0
+		RETURNING id, currency, balance, balance - $2 AS balance_before, balance AS balance_after, $2 AS balance_change`
+	withdrawQuery = `UPDATE wallets SET balance = balance - $2, updated_at = NOW()
+		WHERE id = $1 AND status = 'ACTIVE' AND balance >= $2 AND NOT has_holds AND NOT has_limits
+			AND ($5 = '' OR currency = $5) AND shard_count = 0
+		RETURNING id, currency, balance, balance + $2 AS balance_before, balance AS balance_after, -$2 AS balance_change`
+)
+
+// updateBalanceStatement changes the balance, records the ledger and journal
+// entries and adds the outbox event in one statement. It reports ok=false,
+// changing nothing, when the wallet needs the checks of applyOperation: it
+// does not exist, is not active, is in another currency, lacks funds, has
+// holds or withdrawal limits, or is sharded.
+func (r *WalletRepository) updateBalanceStatement(ctx context.Context, db queryRower, op models.WalletOperation, create bool) (balance int64, ok bool, err error) {
+	args := []any{op.WalletID, op.Amount, op.OperationType, models.EventBalanceChanged}
+
+	var update string
+	switch op.OperationType {
+	case models.DEPOSIT:
+		update = depositQuery
+		args = append(args, r.frozenAllowsDeposits, op.Currency)
+		if create {
+			update = depositUpsertQuery
+			args = append(args, r.walletCurrency(op.Currency))
+		}
+	case models.WITHDRAW:
+		if r.withdrawalLimits != (models.WithdrawalLimits{}) {
+			return 0, false, nil
+		}
+		update = withdrawQuery
+		args = append(args, op.Currency)
+	default:
+		return 0, false, nil
+	}
+
+	err = db.QueryRow(ctx, balanceChangeQuery(`updated AS (`+update+`)`), args...).Scan(&balance)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, false, nil
+		}
+		return 0, false, dbError("update wallet balance", err)
+	}
+	return balance, true, nil
+}
+
+// balanceChangeQuery completes a statement whose CTEs end with updated, the
+// changed wallet's id, currency and balance, and the balance_before,
+// balance_after and balance_change of its ledger entry, with that entry,
+// journal entries and outbox event. The statement returns balance. $2, $3
+// and $4 are the amount, the operation type and the event type.
+func balanceChangeQuery(ctes string) string {
+	return `WITH ` + ctes + `,
+		entry AS (
+			INSERT INTO wallet_transactions (wallet_id, operation_type, amount, balance_before, balance_after, balance_change)
+			SELECT id, $3, $2, balance_before, balance_after, balance_change FROM updated
+			RETURNING *
+		),
+		event AS (` + outboxEventQuery("$4") + `),
+		journal AS (` + journalQuery("(SELECT currency FROM updated)") + `)
+		SELECT balance FROM updated`
+}
+
+// replayedBalance returns the balance stored with the key of a replayed
+// operation. Keys stored before idempotency_keys kept the balance get the
+// current one, with the currency checked like the original operation was.
+func (r *WalletRepository) replayedBalance(ctx context.Context, db queryRower, op models.WalletOperation, stored *int64) (int64, error) {
+	if stored != nil {
+		return *stored, nil
+	}
+
+	var wallet models.Wallet
+	query := `SELECT w.balance + ` + shardBalanceSubquery + `, w.currency FROM wallets w WHERE w.id = $1`
+	err := db.QueryRow(ctx, query, op.WalletID).Scan(&wallet.Balance, &wallet.Currency)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, fmt.Errorf("%w: %s", ErrWalletNotFound, op.WalletID)
+		}
+		return 0, dbError("get wallet balance", err)
+	}
+	if err := r.checkCurrency(&wallet, op.Currency); err != nil {
+		return 0, err
+	}
+	return wallet.Balance, nil
+}
+
+// claimIdempotencyKey stores key for the request in tx. It reports
+// claimed=false, with the balance stored by storeIdempotentBalance, when the
+// key is already stored for the same request.
+func (r *WalletRepository) claimIdempotencyKey(ctx context.Context, tx pgx.Tx, key, requestHash string) (claimed bool, balance *int64, err error) {
+	// A concurrent request with the same key blocks on the primary key until
+	// the first one commits or rolls back.
+	query := `INSERT INTO idempotency_keys (key, request_hash, expires_at)
+		VALUES ($1, $2, NOW() + $3::interval)
+		ON CONFLICT (key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash, balance = NULL, created_at = NOW(), expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= NOW()`
+	tag, err := tx.Exec(ctx, query, key, requestHash, r.idempotencyTTL)
+	if err != nil {
+		return false, nil, dbError("store idempotency key", err)
+	}
+	if tag.RowsAffected() == 1 {
+		return true, nil, nil
+	}
+
+	var storedHash string
+	err = tx.QueryRow(ctx, "SELECT request_hash, balance FROM idempotency_keys WHERE key = $1", key).Scan(&storedHash, &balance)
+	if err != nil {
+		return false, nil, dbError("get idempotency key", err)
+	}
+	if storedHash != requestHash {
+		return false, nil, ErrIdempotencyKeyReused
+	}
+	return false, balance, nil
+}
+
+// storeIdempotentBalance keeps the balance left by the operation of a key
+// claimed in tx, so that replays answer with it.
+func storeIdempotentBalance(ctx context.Context, tx pgx.Tx, key string, balance int64) error {
+	if _, err := tx.Exec(ctx, "UPDATE idempotency_keys SET balance = $2 WHERE key = $1", key, balance); err != nil {
+		return dbError("store idempotent balance", err)
+	}
+	return nil
+}
+
+func (r *WalletRepository) DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	tag, err := r.db.Exec(ctx, "DELETE FROM idempotency_keys WHERE expires_at <= NOW()")
+	if err != nil {
+		return 0, dbError("delete expired idempotency keys", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// SetWalletStatus moves the wallet to status, recording reason. Closing is
+// only allowed once the wallet is empty and has no active holds.
+func (r *WalletRepository) SetWalletStatus(ctx context.Context, walletID string, status models.WalletStatus, reason string) (*models.Wallet, error) {
 	tx, err := r.db.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, dbError("begin transaction", err)
 	}
 	defer tx.Rollback(ctx)
 
-	var currentBalance int64
-	err = tx.QueryRow(ctx, "SELECT balance FROM wallets WHERE id = $1 FOR UPDATE", walletID).Scan(&currentBalance)
+	wallet, err := lockWallet(ctx, tx, walletID)
+	if err != nil {
+		return nil, err
+	}
+	wallet.Held, err = heldAmount(ctx, tx, walletID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkStatusTransition(wallet, status); err != nil {
+		return nil, err
+	}
+
+	query := `UPDATE wallets SET status = $1, status_reason = $2, status_changed_at = NOW(), updated_at = NOW()
+		WHERE id = $3
+		RETURNING status, status_reason, created_at, updated_at`
+	err = tx.QueryRow(ctx, query, status, reason, walletID).Scan(
+		&wallet.Status,
+		&wallet.StatusReason,
+		&wallet.CreatedAt,
+		&wallet.UpdatedAt,
+	)
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return fmt.Errorf("wallet not found")
+		return nil, dbError("update wallet status", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, dbError("commit transaction", err)
+	}
+	return wallet, nil
+}
+
+// lockWallet locks the wallet row for the rest of tx and returns its id,
+// balance, currency, status and shard count. The shards of a sharded
+// wallet are emptied into the row, so callers can treat the row balance as
+// the whole balance.
+func lockWallet(ctx context.Context, tx pgx.Tx, walletID string) (*models.Wallet, error) {
+	var wallet models.Wallet
+	query := "SELECT id, balance, currency, status, shard_count FROM wallets WHERE id = $1 FOR UPDATE"
+	err := tx.QueryRow(ctx, query, walletID).Scan(
+		&wallet.ID,
+		&wallet.Balance,
+		&wallet.Currency,
+		&wallet.Status,
+		&wallet.Shards,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrWalletNotFound, walletID)
 		}
-		return fmt.Errorf("failed to get wallet balance: %w", err)
+		return nil, dbError("get wallet balance", err)
+	}
+	if wallet.Shards > 0 {
+		if wallet.Balance, err = collapseShards(ctx, tx, wallet.ID); err != nil {
+			return nil, err
+		}
+	}
+	return &wallet, nil
+}
+
+// applyOperation applies a deposit or withdrawal inside tx and returns the
+// new balance.
+func (r *WalletRepository) applyOperation(ctx context.Context, tx pgx.Tx, op models.WalletOperation) (int64, error) {
+	lockStart := time.Now()
+	wallet, err := lockWallet(ctx, tx, op.WalletID)
+	r.metrics.ObserveLockWait(string(op.OperationType), time.Since(lockStart))
+	if err != nil {
+		return 0, err
+	}
+	if err := r.checkCurrency(wallet, op.Currency); err != nil {
+		return 0, err
 	}
 
 	var newBalance int64
-	switch operationType {
+	hasHolds := true
+	switch op.OperationType {
 	case models.DEPOSIT:
-		newBalance = currentBalance + amount
+		if err := r.checkWalletStatus(wallet, models.DEPOSIT); err != nil {
+			return 0, err
+		}
+		newBalance = wallet.Balance + op.Amount
 	case models.WITHDRAW:
-		newBalance = currentBalance - amount
-		if newBalance < 0 {
-			return fmt.Errorf("insufficient funds")
+		if err := r.checkWalletStatus(wallet, models.WITHDRAW); err != nil {
+			return 0, err
+		}
+		held, err := heldAmount(ctx, tx, op.WalletID)
+		if err != nil {
+			return 0, err
+		}
+		// has_holds is only set by CreateHold; with the wallet locked, the
+		// sum tells whether it can be cleared for the single statement.
+		hasHolds = held > 0
+		newBalance = wallet.Balance - op.Amount
+		if newBalance < held {
+			return 0, ErrInsufficientFunds
+		}
+		if err := r.checkLimits(ctx, tx, op.WalletID, op.Amount); err != nil {
+			return 0, err
 		}
 	default:
-		return fmt.Errorf("invalid operation type")
+		return 0, fmt.Errorf("%w: invalid operation type %q", ErrInvalidOperation, op.OperationType)
+	}
+
+	query := `UPDATE wallets SET balance = $1, has_holds = has_holds AND $3, updated_at = NOW() WHERE id = $2`
+	_, err = tx.Exec(ctx, query, newBalance, op.WalletID, hasHolds)
+	if err != nil {
+		return 0, dbError("update wallet balance", err)
+	}
+
+	entry := models.Transaction{WalletID: op.WalletID, OperationType: op.OperationType, Amount: op.Amount}
+	entry.SetBalances(wallet.Balance, newBalance)
+	if err := recordTransactions(ctx, tx, entry); err != nil {
+		return 0, err
+	}
+	return newBalance, nil
+}
+
+// recordTransactions appends ledger entries and, in the same statement, their
+// journal entries and the wallet.balance_changed outbox events carrying them.
+// Every balance change goes through here or updateBalanceStatement, which does
+// the same, so no change can commit without its event or its journal. The
+// legs of a transfer or exchange must be recorded together: the journal is
+// checked to balance at the end of each statement.
+func recordTransactions(ctx context.Context, tx pgx.Tx, ts ...models.Transaction) error {
+	var (
+		walletIDs, operationTypes         []string
+		amounts, balanceChanges           []int64
+		balancesBefore, balancesAfter     []*int64
+		transferIDs, holdIDs, exchangeIDs []string
+	)
+	for _, t := range ts {
+		walletIDs = append(walletIDs, t.WalletID)
+		operationTypes = append(operationTypes, string(t.OperationType))
+		amounts = append(amounts, t.Amount)
+		balancesBefore = append(balancesBefore, t.BalanceBefore)
+		balancesAfter = append(balancesAfter, t.BalanceAfter)
+		balanceChanges = append(balanceChanges, t.BalanceChange)
+		transferIDs = append(transferIDs, t.TransferID)
+		holdIDs = append(holdIDs, t.HoldID)
+		exchangeIDs = append(exchangeIDs, t.ExchangeID)
+	}
+
+	query := `WITH entry AS (
+			INSERT INTO wallet_transactions (wallet_id, operation_type, amount, balance_before, balance_after, balance_change, transfer_id, hold_id, exchange_id)
+			SELECT wallet_id::uuid, operation_type, amount, balance_before, balance_after, balance_change,
+				NULLIF(transfer_id, '')::uuid, NULLIF(hold_id, '')::uuid, NULLIF(exchange_id, '')::uuid
+			FROM unnest($1::text[], $2::text[], $3::bigint[], $4::bigint[], $5::bigint[], $6::bigint[], $7::text[], $8::text[], $9::text[])
+				WITH ORDINALITY AS t(wallet_id, operation_type, amount, balance_before, balance_after, balance_change, transfer_id, hold_id, exchange_id, n)
+			ORDER BY n
+			RETURNING *
+		),
+		journal AS (` + journalQuery("(SELECT currency FROM wallets WHERE id = entry.wallet_id)") + `)
+		` + outboxEventQuery("$10")
+	_, err := tx.Exec(ctx, query, walletIDs, operationTypes, amounts, balancesBefore, balancesAfter, balanceChanges, transferIDs, holdIDs, exchangeIDs, models.EventBalanceChanged)
+	if err != nil {
+		return dbError("record transaction", err)
+	}
+	return nil
+}
+
+// outboxEventQuery inserts the outbox event for every ledger entry returned
+// by the entry CTE, taking the event type from the eventType placeholder.
+func outboxEventQuery(eventType string) string {
+	return `INSERT INTO outbox_events (event_type, wallet_id, payload)
+		SELECT ` + eventType + `, wallet_id, jsonb_strip_nulls(jsonb_build_object(
+			'id', id,
+			'walletId', wallet_id,
+			'operationType', operation_type,
+			'amount', amount,
+			'balanceBefore', balance_before,
+			'balanceAfter', balance_after,
+			'balanceChange', balance_change,
+			'shardEntry', CASE WHEN balance_before IS NULL THEN TRUE END,
+			'transferId', transfer_id,
+			'holdId', hold_id,
+			'exchangeId', exchange_id,
+			'createdAt', created_at
+		))
+		FROM entry`
+}
+
+// Transfer moves amount between two wallets in one transaction. Both rows are
+// locked in ascending id order, so two opposite transfers running at the same
+// time wait on each other instead of deadlocking.
+func (r *WalletRepository) Transfer(ctx context.Context, fromWalletID, toWalletID string, amount int64) (*models.TransferResponse, error) {
+	if fromWalletID == toWalletID {
+		return nil, fmt.Errorf("%w: cannot transfer to the same wallet", ErrInvalidOperation)
+	}
+	if amount <= 0 {
+		return nil, fmt.Errorf("%w: amount must be positive", ErrInvalidOperation)
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, dbError("begin transaction", err)
 	}
+	defer tx.Rollback(ctx)
+
+	lockOrder := []string{fromWalletID, toWalletID}
+	slices.Sort(lockOrder)
+
+	wallets := make(map[string]*models.Wallet, len(lockOrder))
+	for _, id := range lockOrder {
+		wallet, err := lockWallet(ctx, tx, id)
+		if err != nil {
+			return nil, err
+		}
+		wallets[id] = wallet
+	}
+
+	if err := r.checkWalletStatus(wallets[fromWalletID], models.WITHDRAW); err != nil {
+		return nil, err
+	}
+	if err := r.checkWalletStatus(wallets[toWalletID], models.DEPOSIT); err != nil {
+		return nil, err
+	}
+	if err := r.checkCurrency(wallets[toWalletID], wallets[fromWalletID].Currency); err != nil {
+		return nil, err
+	}
+
+	fromBefore, toBefore := wallets[fromWalletID].Balance, wallets[toWalletID].Balance
+	held, err := heldAmount(ctx, tx, fromWalletID)
+	if err != nil {
+		return nil, err
+	}
+	if fromBefore-held < amount {
+		return nil, ErrInsufficientFunds
+	}
+	if err := r.checkLimits(ctx, tx, fromWalletID, amount); err != nil {
+		return nil, err
+	}
+	fromAfter, toAfter := fromBefore-amount, toBefore+amount
 
 	query := `UPDATE wallets SET balance = $1, updated_at = NOW() WHERE id = $2`
-	_, err = tx.Exec(ctx, query, newBalance, walletID)
+	if _, err := tx.Exec(ctx, query, fromAfter, fromWalletID); err != nil {
+		return nil, dbError("update wallet balance", err)
+	}
+	if _, err := tx.Exec(ctx, query, toAfter, toWalletID); err != nil {
+		return nil, dbError("update wallet balance", err)
+	}
+
+	transferID := newUUID()
+	legs := []models.Transaction{{WalletID: fromWalletID}, {WalletID: toWalletID}}
+	legs[0].SetBalances(fromBefore, fromAfter)
+	legs[1].SetBalances(toBefore, toAfter)
+	for i := range legs {
+		legs[i].OperationType = models.TRANSFER
+		legs[i].Amount = amount
+		legs[i].TransferID = transferID
+	}
+	if err := recordTransactions(ctx, tx, legs...); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, dbError("commit transfer", err)
+	}
+
+	return &models.TransferResponse{
+		TransferID:   transferID,
+		FromWalletID: fromWalletID,
+		ToWalletID:   toWalletID,
+		Amount:       amount,
+		FromBalance:  fromAfter,
+		ToBalance:    toAfter,
+	}, nil
+}
+
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// operationHash identifies the request an idempotency key was used for. The
+// currency is left out when the operation has none, so such keys hash as
+// they did before currencies were tracked.
+func operationHash(op models.WalletOperation) string {
+	request := fmt.Sprintf("%s|%s|%d", op.WalletID, op.OperationType, op.Amount)
+	if op.Currency != "" {
+		request += "|" + op.Currency
+	}
+	sum := sha256.Sum256([]byte(request))
+	return hex.EncodeToString(sum[:])
+}
+
+// ListTransactions returns ledger entries newest first, plus the cursor of the
+// next page or "" when this is the last one.
+func (r *WalletRepository) ListTransactions(ctx context.Context, walletID string, filter models.TransactionFilter) ([]models.Transaction, string, error) {
+	conditions := []string{"wallet_id = $1"}
+	args := []any{walletID}
+
+	addCondition := func(format string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(format, len(args)))
+	}
+
+	if filter.OperationType != "" {
+		addCondition("operation_type = $%d", filter.OperationType)
+	}
+	if !filter.From.IsZero() {
+		addCondition("created_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		addCondition("created_at < $%d", filter.To)
+	}
+	if filter.After != nil {
+		addCondition("seq < $%d", filter.After.Sequence)
+	}
+
+	args = append(args, filter.Limit+1)
+	query := fmt.Sprintf(`SELECT id, wallet_id, operation_type, amount, balance_before, balance_after, balance_change,
+			COALESCE(transfer_id::text, ''), COALESCE(hold_id::text, ''), COALESCE(exchange_id::text, ''), created_at, seq
+		FROM wallet_transactions
+		WHERE %s
+		ORDER BY seq DESC
+		LIMIT $%d`, strings.Join(conditions, " AND "), len(args))
+
+	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
-		return fmt.Errorf("failed to update wallet balance: %w", err)
+		return nil, "", dbError("list transactions", err)
+	}
+	defer rows.Close()
+
+	transactions := make([]models.Transaction, 0, filter.Limit)
+	for rows.Next() {
+		var t models.Transaction
+		if err := rows.Scan(
+			&t.ID,
+			&t.WalletID,
+			&t.OperationType,
+			&t.Amount,
+			&t.BalanceBefore,
+			&t.BalanceAfter,
+			&t.BalanceChange,
+			&t.TransferID,
+			&t.HoldID,
+			&t.ExchangeID,
+			&t.CreatedAt,
+			&t.Sequence,
+		); err != nil {
+			return nil, "", dbError("scan transaction", err)
+		}
+		t.ShardEntry = t.BalanceBefore == nil
+		transactions = append(transactions, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", dbError("list transactions", err)
+	}
+
+	var nextCursor string
+	if len(transactions) > filter.Limit {
+		transactions = transactions[:filter.Limit]
+		last := transactions[len(transactions)-1]
+		nextCursor = EncodeTransactionCursor(models.TransactionCursor{Sequence: last.Sequence})
 	}
 
-	return tx.Commit(ctx)
+	return transactions, nextCursor, nil
 }
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/NKV510/wallet-service/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+const apiKeyColumns = `id, name, prefix, scopes, wallet_ids, created_at, revoked_at`
+
+func (r *WalletRepository) CreateAPIKey(ctx context.Context, key models.APIKey, keyHash string) (*models.APIKey, error) {
+	if key.WalletIDs == nil {
+		key.WalletIDs = []string{}
+	}
+
+	query := `INSERT INTO api_keys (name, prefix, key_hash, scopes, wallet_ids)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING ` + apiKeyColumns
+	created, err := scanAPIKey(r.db.QueryRow(ctx, query, key.Name, key.Prefix, keyHash, key.Scopes, key.WalletIDs))
+	if err != nil {
+		return nil, dbError("create api key", err)
+	}
+	return created, nil
+}
+
+func (r *WalletRepository) GetAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_hash = $1`
+	key, err := scanAPIKey(r.db.QueryRow(ctx, query, keyHash))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, dbError("get api key", err)
+	}
+	return key, nil
+}
+
+func (r *WalletRepository) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	rows, err := r.db.Query(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY created_at, id`)
+	if err != nil {
+		return nil, dbError("list api keys", err)
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, dbError("scan api key", err)
+		}
+		keys = append(keys, *key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError("list api keys", err)
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey revokes the key. Revoking an already revoked key keeps the
+// original revocation time.
+func (r *WalletRepository) RevokeAPIKey(ctx context.Context, id string) (*models.APIKey, error) {
+	query := `UPDATE api_keys SET revoked_at = COALESCE(revoked_at, NOW())
+		WHERE id::text = $1
+		RETURNING ` + apiKeyColumns
+	key, err := scanAPIKey(r.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrAPIKeyNotFound, id)
+		}
+		return nil, dbError("revoke api key", err)
+	}
+	return key, nil
+}
+
+func scanAPIKey(row pgx.Row) (*models.APIKey, error) {
+	var key models.APIKey
+	err := row.Scan(
+		&key.ID,
+		&key.Name,
+		&key.Prefix,
+		&key.Scopes,
+		&key.WalletIDs,
+		&key.CreatedAt,
+		&key.RevokedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
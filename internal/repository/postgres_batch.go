@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/NKV510/wallet-service/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// ApplyBatch applies a list of deposits and withdrawals. Wallets are created
+// on their first operation, in its currency, as with POST /api/v1/wallet, and
+// operations with an idempotency key are applied at most once per key.
+//
+// An atomic batch runs in one transaction that locks every wallet up front in
+// ascending id order, so concurrent batches and transfers wait on each other
+// instead of deadlocking. If an operation fails nothing is applied: the
+// results mark it failed, the operations before it rolled back and the ones
+// after it skipped, and the returned error names its index. An independent
+// batch applies each operation like ProcessOperation and reports failures
+// only in the results.
+func (r *WalletRepository) ApplyBatch(ctx context.Context, mode models.BatchMode, operations []models.WalletOperation) ([]models.BatchItemResult, error) {
+	switch mode {
+	case models.BatchAtomic:
+		return r.applyAtomicBatch(ctx, operations)
+	case models.BatchIndependent:
+		results := newBatchResults(operations)
+		for i, op := range operations {
+			balance, replayed, err := r.ProcessOperation(ctx, op)
+			if err != nil {
+				results[i].Status = models.BatchItemFailed
+				results[i].Err = err
+				continue
+			}
+			status := models.BatchItemApplied
+			if replayed {
+				status = models.BatchItemReplayed
+			}
+			setBatchResult(&results[i], status, balance)
+		}
+		return results, nil
+	default:
+		return nil, fmt.Errorf("%w: invalid batch mode %q", ErrInvalidOperation, mode)
+	}
+}
+
+func (r *WalletRepository) applyAtomicBatch(ctx context.Context, operations []models.WalletOperation) ([]models.BatchItemResult, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, dbError("begin transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// A wallet created by the batch gets the currency of its first
+	// operation.
+	currencies := make(map[string]string, len(operations))
+	walletIDs := make([]string, 0, len(operations))
+	for _, op := range operations {
+		if _, ok := currencies[op.WalletID]; !ok {
+			currencies[op.WalletID] = r.walletCurrency(op.Currency)
+			walletIDs = append(walletIDs, op.WalletID)
+		}
+	}
+	slices.Sort(walletIDs)
+	walletCurrencies := make([]string, 0, len(walletIDs))
+	for _, id := range walletIDs {
+		walletCurrencies = append(walletCurrencies, currencies[id])
+	}
+
+	query := `INSERT INTO wallets (id, balance, currency)
+		SELECT id::uuid, 0, currency FROM unnest($1::text[], $2::text[]) AS w(id, currency)
+		ON CONFLICT (id) DO NOTHING`
+	if _, err := tx.Exec(ctx, query, walletIDs, walletCurrencies); err != nil {
+		return nil, dbError("create wallets", err)
+	}
+	for _, id := range walletIDs {
+		if _, err := lockWallet(ctx, tx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	results := newBatchResults(operations)
+	for i, op := range operations {
+		status, balance, err := r.applyBatchOperation(ctx, tx, op)
+		if err != nil {
+			r.observeOperation(op.OperationType, op.Amount, err)
+			failBatch(results, i, err)
+			return results, fmt.Errorf("operation %d: %w", i, err)
+		}
+		setBatchResult(&results[i], status, balance)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, dbError("commit batch", err)
+	}
+
+	for i, op := range operations {
+		if results[i].Status == models.BatchItemApplied {
+			r.observeOperation(op.OperationType, op.Amount, nil)
+		}
+	}
+	return results, nil
+}
+
+// applyBatchOperation applies op inside tx, claiming its idempotency key
+// first if it has one.
+func (r *WalletRepository) applyBatchOperation(ctx context.Context, tx pgx.Tx, op models.WalletOperation) (models.BatchItemStatus, int64, error) {
+	if op.IdempotencyKey != "" {
+		claimed, stored, err := r.claimIdempotencyKey(ctx, tx, op.IdempotencyKey, operationHash(op))
+		if err != nil {
+			return "", 0, err
+		}
+		if !claimed {
+			balance, err := r.replayedBalance(ctx, tx, op, stored)
+			if err != nil {
+				return "", 0, err
+			}
+			return models.BatchItemReplayed, balance, nil
+		}
+	}
+
+	balance, err := r.applyOperation(ctx, tx, op)
+	if err != nil {
+		return "", 0, err
+	}
+	if op.IdempotencyKey != "" {
+		if err := storeIdempotentBalance(ctx, tx, op.IdempotencyKey, balance); err != nil {
+			return "", 0, err
+		}
+	}
+	return models.BatchItemApplied, balance, nil
+}
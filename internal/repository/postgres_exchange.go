@@ -0,0 +1,221 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/NKV510/wallet-service/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+const exchangeQuoteColumns = `id, from_currency, to_currency, rate::text, expires_at`
+
+func (r *WalletRepository) GetExchangeRate(ctx context.Context, from, to string) (*models.ExchangeRate, error) {
+	query := `SELECT from_currency, to_currency, rate::text, updated_at FROM exchange_rates
+		WHERE from_currency = $1 AND to_currency = $2`
+	rate, err := scanExchangeRate(r.db.QueryRow(ctx, query, from, to))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s to %s", ErrRateNotFound, from, to)
+		}
+		return nil, dbError("get exchange rate", err)
+	}
+	return rate, nil
+}
+
+func (r *WalletRepository) ListExchangeRates(ctx context.Context) ([]models.ExchangeRate, error) {
+	rows, err := r.db.Query(ctx, `SELECT from_currency, to_currency, rate::text, updated_at FROM exchange_rates
+		ORDER BY from_currency, to_currency`)
+	if err != nil {
+		return nil, dbError("list exchange rates", err)
+	}
+	defer rows.Close()
+
+	rates := []models.ExchangeRate{}
+	for rows.Next() {
+		rate, err := scanExchangeRate(rows)
+		if err != nil {
+			return nil, dbError("scan exchange rate", err)
+		}
+		rates = append(rates, *rate)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError("list exchange rates", err)
+	}
+	return rates, nil
+}
+
+func (r *WalletRepository) SetExchangeRate(ctx context.Context, rate models.ExchangeRate) (*models.ExchangeRate, error) {
+	query := `INSERT INTO exchange_rates (from_currency, to_currency, rate) VALUES ($1, $2, $3::numeric)
+		ON CONFLICT (from_currency, to_currency) DO UPDATE SET rate = EXCLUDED.rate, updated_at = NOW()
+		RETURNING from_currency, to_currency, rate::text, updated_at`
+	stored, err := scanExchangeRate(r.db.QueryRow(ctx, query, rate.From, rate.To, rate.Rate))
+	if err != nil {
+		return nil, dbError("set exchange rate", err)
+	}
+	return stored, nil
+}
+
+func (r *WalletRepository) CreateExchangeQuote(ctx context.Context, quote models.ExchangeQuote, ttl time.Duration) (*models.ExchangeQuote, error) {
+	query := `INSERT INTO exchange_quotes (from_currency, to_currency, rate, expires_at)
+		VALUES ($1, $2, $3::numeric, NOW() + $4::interval)
+		RETURNING ` + exchangeQuoteColumns
+	created, err := scanExchangeQuote(r.db.QueryRow(ctx, query, quote.FromCurrency, quote.ToCurrency, quote.Rate, ttl))
+	if err != nil {
+		return nil, dbError("create exchange quote", err)
+	}
+	return created, nil
+}
+
+func (r *WalletRepository) GetExchangeQuote(ctx context.Context, id string) (*models.ExchangeQuote, error) {
+	if _, ok := models.NormalizeID(id); !ok {
+		return nil, fmt.Errorf("%w: %s", ErrQuoteNotFound, id)
+	}
+
+	query := `SELECT ` + exchangeQuoteColumns + ` FROM exchange_quotes WHERE id = $1`
+	quote, err := scanExchangeQuote(r.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrQuoteNotFound, id)
+		}
+		return nil, dbError("get exchange quote", err)
+	}
+	return quote, nil
+}
+
+func (r *WalletRepository) DeleteExpiredExchangeQuotes(ctx context.Context) (int64, error) {
+	tag, err := r.db.Exec(ctx, "DELETE FROM exchange_quotes WHERE expires_at <= NOW()")
+	if err != nil {
+		return 0, dbError("delete expired exchange quotes", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// Exchange locks both wallets in ascending id order, like Transfer, and
+// checks that they are still in the currencies the amounts were converted
+// between.
+func (r *WalletRepository) Exchange(ctx context.Context, exchange models.Exchange) (*models.ExchangeResponse, error) {
+	fromWalletID, toWalletID := exchange.FromWalletID, exchange.ToWalletID
+	if err := checkExchange(fromWalletID, toWalletID, exchange); err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, dbError("begin transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if exchange.QuoteID != "" {
+		if err := useExchangeQuote(ctx, tx, exchange.QuoteID); err != nil {
+			return nil, err
+		}
+	}
+
+	lockOrder := []string{fromWalletID, toWalletID}
+	slices.Sort(lockOrder)
+
+	wallets := make(map[string]*models.Wallet, len(lockOrder))
+	for _, id := range lockOrder {
+		wallet, err := lockWallet(ctx, tx, id)
+		if err != nil {
+			return nil, err
+		}
+		wallets[id] = wallet
+	}
+	from, to := wallets[fromWalletID], wallets[toWalletID]
+
+	if err := r.checkCurrency(from, exchange.FromCurrency); err != nil {
+		return nil, err
+	}
+	if err := r.checkCurrency(to, exchange.ToCurrency); err != nil {
+		return nil, err
+	}
+	if err := r.checkWalletStatus(from, models.WITHDRAW); err != nil {
+		return nil, err
+	}
+	if err := r.checkWalletStatus(to, models.DEPOSIT); err != nil {
+		return nil, err
+	}
+
+	held, err := heldAmount(ctx, tx, fromWalletID)
+	if err != nil {
+		return nil, err
+	}
+	if from.Balance-held < exchange.Debit {
+		return nil, ErrInsufficientFunds
+	}
+	if err := r.checkLimits(ctx, tx, fromWalletID, exchange.Debit); err != nil {
+		return nil, err
+	}
+	fromAfter, toAfter := from.Balance-exchange.Debit, to.Balance+exchange.Credit
+
+	query := `UPDATE wallets SET balance = $1, updated_at = NOW() WHERE id = $2`
+	if _, err := tx.Exec(ctx, query, fromAfter, fromWalletID); err != nil {
+		return nil, dbError("update wallet balance", err)
+	}
+	if _, err := tx.Exec(ctx, query, toAfter, toWalletID); err != nil {
+		return nil, dbError("update wallet balance", err)
+	}
+
+	exchangeID := newUUID()
+	legs := []models.Transaction{
+		{WalletID: fromWalletID, Amount: exchange.Debit},
+		{WalletID: toWalletID, Amount: exchange.Credit},
+	}
+	legs[0].SetBalances(from.Balance, fromAfter)
+	legs[1].SetBalances(to.Balance, toAfter)
+	for i := range legs {
+		legs[i].OperationType = models.EXCHANGE
+		legs[i].ExchangeID = exchangeID
+	}
+	if err := recordTransactions(ctx, tx, legs...); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, dbError("commit exchange", err)
+	}
+	return exchangeResponse(exchangeID, fromWalletID, toWalletID, exchange, fromAfter, toAfter), nil
+}
+
+// useExchangeQuote marks the quote used in tx, so that it cannot back a
+// second exchange.
+func useExchangeQuote(ctx context.Context, tx pgx.Tx, id string) error {
+	tag, err := tx.Exec(ctx, `UPDATE exchange_quotes SET used_at = NOW()
+		WHERE id = $1 AND used_at IS NULL AND expires_at > NOW()`, id)
+	if err != nil {
+		return dbError("use exchange quote", err)
+	}
+	if tag.RowsAffected() == 1 {
+		return nil
+	}
+
+	var exists bool
+	if err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM exchange_quotes WHERE id = $1)", id).Scan(&exists); err != nil {
+		return dbError("get exchange quote", err)
+	}
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrQuoteNotFound, id)
+	}
+	return ErrQuoteExpired
+}
+
+func scanExchangeRate(row pgx.Row) (*models.ExchangeRate, error) {
+	var rate models.ExchangeRate
+	if err := row.Scan(&rate.From, &rate.To, &rate.Rate, &rate.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+func scanExchangeQuote(row pgx.Row) (*models.ExchangeQuote, error) {
+	var quote models.ExchangeQuote
+	if err := row.Scan(&quote.ID, &quote.FromCurrency, &quote.ToCurrency, &quote.Rate, &quote.ExpiresAt); err != nil {
+		return nil, err
+	}
+	return &quote, nil
+}
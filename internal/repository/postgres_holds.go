@@ -0,0 +1,235 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/NKV510/wallet-service/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// heldAmountSubquery sums the active holds of the wallet aliased as w.
+const heldAmountSubquery = `COALESCE((SELECT SUM(h.amount) FROM holds h
+	WHERE h.wallet_id = w.id AND h.status = 'ACTIVE' AND h.expires_at > NOW()), 0)`
+
+const holdColumns = `id, wallet_id, amount, captured_amount, status, expires_at, created_at, updated_at`
+
+// CreateHold reserves amount from the wallet's available balance for ttl, or
+// for the repository default when ttl is zero.
+func (r *WalletRepository) CreateHold(ctx context.Context, walletID string, amount int64, ttl time.Duration) (*models.Hold, error) {
+	if amount <= 0 {
+		return nil, fmt.Errorf("%w: amount must be positive", ErrInvalidOperation)
+	}
+	if ttl <= 0 {
+		ttl = r.holdTTL
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, dbError("begin transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	wallet, err := lockWallet(ctx, tx, walletID)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.checkWalletStatus(wallet, models.WITHDRAW); err != nil {
+		return nil, err
+	}
+
+	held, err := heldAmount(ctx, tx, walletID)
+	if err != nil {
+		return nil, err
+	}
+	if wallet.Balance-held < amount {
+		return nil, ErrInsufficientFunds
+	}
+
+	query := `INSERT INTO holds (wallet_id, amount, expires_at)
+		VALUES ($1, $2, NOW() + $3::interval)
+		RETURNING ` + holdColumns
+	hold, err := scanHold(tx.QueryRow(ctx, query, walletID, amount, ttl))
+	if err != nil {
+		return nil, dbError("create hold", err)
+	}
+
+	// Keeps withdrawals off the single-statement path, which cannot see
+	// holds; applyOperation clears it once the holds are gone.
+	if _, err := tx.Exec(ctx, "UPDATE wallets SET has_holds = TRUE WHERE id = $1", walletID); err != nil {
+		return nil, dbError("flag wallet holds", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, dbError("commit transaction", err)
+	}
+	return hold, nil
+}
+
+// CaptureHold withdraws amount (the whole hold when zero) from the wallet and
+// releases the rest of the hold.
+func (r *WalletRepository) CaptureHold(ctx context.Context, walletID, holdID string, amount int64) (*models.Hold, error) {
+	if amount < 0 {
+		return nil, fmt.Errorf("%w: amount must be positive", ErrInvalidOperation)
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, dbError("begin transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	wallet, err := lockWallet(ctx, tx, walletID)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.checkWalletStatus(wallet, models.WITHDRAW); err != nil {
+		return nil, err
+	}
+
+	hold, err := lockActiveHold(ctx, tx, walletID, holdID)
+	if err != nil {
+		return nil, err
+	}
+
+	if amount == 0 {
+		amount = hold.Amount
+	}
+	if amount > hold.Amount {
+		return nil, fmt.Errorf("%w: capture amount exceeds hold amount %d", ErrInvalidOperation, hold.Amount)
+	}
+	if err := r.checkLimits(ctx, tx, walletID, amount); err != nil {
+		return nil, err
+	}
+
+	// The hold reserved amount, but an adjustment can have lowered the
+	// balance below it since.
+	newBalance := wallet.Balance - amount
+	if newBalance < 0 {
+		return nil, ErrInsufficientFunds
+	}
+	query := `UPDATE wallets SET balance = $1, updated_at = NOW() WHERE id = $2`
+	if _, err := tx.Exec(ctx, query, newBalance, walletID); err != nil {
+		return nil, dbError("update wallet balance", err)
+	}
+
+	entry := models.Transaction{WalletID: walletID, OperationType: models.WITHDRAW, Amount: amount, HoldID: holdID}
+	entry.SetBalances(wallet.Balance, newBalance)
+	if err := recordTransactions(ctx, tx, entry); err != nil {
+		return nil, err
+	}
+
+	query = `UPDATE holds SET status = $1, captured_amount = $2, updated_at = NOW()
+		WHERE id = $3
+		RETURNING ` + holdColumns
+	hold, err = scanHold(tx.QueryRow(ctx, query, models.HoldCaptured, amount, holdID))
+	if err != nil {
+		return nil, dbError("capture hold", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, dbError("commit transaction", err)
+	}
+	return hold, nil
+}
+
+// VoidHold releases an active hold without moving money.
+func (r *WalletRepository) VoidHold(ctx context.Context, walletID, holdID string) (*models.Hold, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, dbError("begin transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := lockActiveHold(ctx, tx, walletID, holdID); err != nil {
+		return nil, err
+	}
+
+	query := `UPDATE holds SET status = $1, updated_at = NOW()
+		WHERE id = $2
+		RETURNING ` + holdColumns
+	hold, err := scanHold(tx.QueryRow(ctx, query, models.HoldVoided, holdID))
+	if err != nil {
+		return nil, dbError("void hold", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, dbError("commit transaction", err)
+	}
+	return hold, nil
+}
+
+// ExpireHolds marks active holds past their expiry as expired. Expired holds
+// stop reserving funds as soon as expires_at passes; this only keeps their
+// status accurate.
+func (r *WalletRepository) ExpireHolds(ctx context.Context) (int64, error) {
+	query := `UPDATE holds SET status = $1, updated_at = NOW()
+		WHERE status = $2 AND expires_at <= NOW()`
+	tag, err := r.db.Exec(ctx, query, models.HoldExpired, models.HoldActive)
+	if err != nil {
+		return 0, dbError("expire holds", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+func lockActiveHold(ctx context.Context, tx pgx.Tx, walletID, holdID string) (*models.Hold, error) {
+	query := `SELECT ` + holdColumns + `, expires_at <= NOW() FROM holds WHERE id = $1 AND wallet_id = $2 FOR UPDATE`
+
+	var hold models.Hold
+	var expired bool
+	err := tx.QueryRow(ctx, query, holdID, walletID).Scan(
+		&hold.ID,
+		&hold.WalletID,
+		&hold.Amount,
+		&hold.CapturedAmount,
+		&hold.Status,
+		&hold.ExpiresAt,
+		&hold.CreatedAt,
+		&hold.UpdatedAt,
+		&expired,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrHoldNotFound, holdID)
+		}
+		return nil, dbError("get hold", err)
+	}
+
+	if hold.Status == models.HoldActive && expired {
+		hold.Status = models.HoldExpired
+	}
+	if hold.Status != models.HoldActive {
+		return nil, fmt.Errorf("%w: hold is %s", ErrConflict, hold.Status)
+	}
+	return &hold, nil
+}
+
+func heldAmount(ctx context.Context, tx pgx.Tx, walletID string) (int64, error) {
+	var held int64
+	query := `SELECT COALESCE(SUM(amount), 0) FROM holds
+		WHERE wallet_id = $1 AND status = 'ACTIVE' AND expires_at > NOW()`
+	if err := tx.QueryRow(ctx, query, walletID).Scan(&held); err != nil {
+		return 0, dbError("get held amount", err)
+	}
+	return held, nil
+}
+
+func scanHold(row pgx.Row) (*models.Hold, error) {
+	var hold models.Hold
+	err := row.Scan(
+		&hold.ID,
+		&hold.WalletID,
+		&hold.Amount,
+		&hold.CapturedAmount,
+		&hold.Status,
+		&hold.ExpiresAt,
+		&hold.CreatedAt,
+		&hold.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/NKV510/wallet-service/internal/models"
+)
+
+// journalQuery posts the journal entries for every ledger entry returned by
+// the entry CTE, as journalPostings does in memory. currency is the SQL
+// expression for the wallet's currency.
+func journalQuery(currency string) string {
+	return `INSERT INTO journal_entries (journal_id, transaction_id, account, currency, debit, credit, created_at)
+		SELECT COALESCE(entry.transfer_id, entry.exchange_id, entry.id), entry.id, posting.account, ` + currency + `,
+			GREATEST(-posting.amount, 0), GREATEST(posting.amount, 0), entry.created_at
+		FROM entry CROSS JOIN LATERAL (VALUES
+			('` + models.WalletAccount("") + `' || entry.wallet_id, entry.balance_change),
+			(CASE entry.operation_type
+				WHEN '` + string(models.DEPOSIT) + `' THEN '` + models.AccountCashIn + `'
+				WHEN '` + string(models.WITHDRAW) + `' THEN '` + models.AccountCashOut + `'
+				WHEN '` + string(models.EXCHANGE) + `' THEN '` + models.AccountFX + `'
+				WHEN '` + string(models.ADJUSTMENT) + `' THEN '` + models.AccountAdjustments + `'
+				WHEN '` + string(models.OPENING) + `' THEN '` + models.AccountOpeningBalances + `'
+			END, -entry.balance_change)
+		) AS posting(account, amount)
+		WHERE posting.account IS NOT NULL AND posting.amount <> 0`
+}
+
+// TrialBalance sums the whole journal. Wallet accounts are summed together
+// as models.AccountWallets, which keeps the result small.
+func (r *WalletRepository) TrialBalance(ctx context.Context) (*models.TrialBalance, error) {
+	query := `SELECT CASE WHEN account LIKE $1 THEN $2 ELSE account END,
+			currency, SUM(debit)::bigint, SUM(credit)::bigint
+		FROM journal_entries
+		GROUP BY 1, 2`
+	rows, err := r.db.Query(ctx, query, models.WalletAccount("%"), models.AccountWallets)
+	if err != nil {
+		return nil, dbError("get trial balance", err)
+	}
+	defer rows.Close()
+
+	accounts := []models.AccountBalance{}
+	for rows.Next() {
+		var account models.AccountBalance
+		if err := rows.Scan(&account.Account, &account.Currency, &account.Debit, &account.Credit); err != nil {
+			return nil, dbError("scan account balance", err)
+		}
+		accounts = append(accounts, account)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError("get trial balance", err)
+	}
+	return newTrialBalance(accounts), nil
+}
+
+func (r *WalletRepository) GetJournal(ctx context.Context, journalID string) ([]models.JournalEntry, error) {
+	id, ok := models.NormalizeID(journalID)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrJournalNotFound, journalID)
+	}
+
+	query := `SELECT id, journal_id, transaction_id, account, currency, debit, credit, created_at
+		FROM journal_entries
+		WHERE journal_id = $1
+		ORDER BY created_at, transaction_id, credit`
+	rows, err := r.db.Query(ctx, query, id)
+	if err != nil {
+		return nil, dbError("get journal", err)
+	}
+	defer rows.Close()
+
+	var entries []models.JournalEntry
+	for rows.Next() {
+		var entry models.JournalEntry
+		err := rows.Scan(
+			&entry.ID,
+			&entry.JournalID,
+			&entry.TransactionID,
+			&entry.Account,
+			&entry.Currency,
+			&entry.Debit,
+			&entry.Credit,
+			&entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, dbError("scan journal entry", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError("get journal", err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrJournalNotFound, journalID)
+	}
+	return entries, nil
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/NKV510/wallet-service/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+type queryRower interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// GetWalletLimits returns the limits in effect for the wallet and how much it
+// withdrew in the current rolling windows.
+func (r *WalletRepository) GetWalletLimits(ctx context.Context, walletID string) (*models.WalletLimitsResponse, error) {
+	query := `SELECT l.per_operation, l.daily, l.monthly
+		FROM wallets w LEFT JOIN wallet_limits l ON l.wallet_id = w.id
+		WHERE w.id = $1`
+
+	var override models.WithdrawalLimitsOverride
+	err := r.db.QueryRow(ctx, query, walletID).Scan(&override.PerOperation, &override.Daily, &override.Monthly)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrWalletNotFound, walletID)
+		}
+		return nil, dbError("get wallet limits", err)
+	}
+
+	return r.walletLimits(ctx, r.db, walletID, override)
+}
+
+// SetWalletLimits replaces the wallet's limit override. Nil fields fall back
+// to the global defaults.
+func (r *WalletRepository) SetWalletLimits(ctx context.Context, walletID string, override models.WithdrawalLimitsOverride) (*models.WalletLimitsResponse, error) {
+	if err := validateLimitsOverride(override); err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, dbError("begin transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := lockWallet(ctx, tx, walletID); err != nil {
+		return nil, err
+	}
+
+	query := `INSERT INTO wallet_limits (wallet_id, per_operation, daily, monthly)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (wallet_id) DO UPDATE
+		SET per_operation = EXCLUDED.per_operation, daily = EXCLUDED.daily, monthly = EXCLUDED.monthly, updated_at = NOW()`
+	if _, err := tx.Exec(ctx, query, walletID, override.PerOperation, override.Daily, override.Monthly); err != nil {
+		return nil, dbError("set wallet limits", err)
+	}
+
+	hasLimits := override != (models.WithdrawalLimitsOverride{})
+	if _, err := tx.Exec(ctx, "UPDATE wallets SET has_limits = $1 WHERE id = $2", hasLimits, walletID); err != nil {
+		return nil, dbError("flag wallet limits", err)
+	}
+
+	limits, err := r.walletLimits(ctx, tx, walletID, override)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, dbError("commit transaction", err)
+	}
+	return limits, nil
+}
+
+// checkLimits rejects withdrawing amount from a wallet locked by tx when it
+// would break one of the wallet's limits.
+func (r *WalletRepository) checkLimits(ctx context.Context, tx pgx.Tx, walletID string, amount int64) error {
+	var override models.WithdrawalLimitsOverride
+	query := `SELECT per_operation, daily, monthly FROM wallet_limits WHERE wallet_id = $1`
+	err := tx.QueryRow(ctx, query, walletID).Scan(&override.PerOperation, &override.Daily, &override.Monthly)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return dbError("get wallet limits", err)
+	}
+
+	limits, err := r.walletLimits(ctx, tx, walletID, override)
+	if err != nil {
+		return err
+	}
+	return checkWithdrawalLimits(limits.Limits, amount, limits.DailyUsed, limits.MonthlyUsed)
+}
+
+func (r *WalletRepository) walletLimits(ctx context.Context, db queryRower, walletID string, override models.WithdrawalLimitsOverride) (*models.WalletLimitsResponse, error) {
+	limits := &models.WalletLimitsResponse{
+		WalletID: walletID,
+		Limits:   effectiveLimits(r.withdrawalLimits, override),
+		Override: override,
+	}
+
+	// Every entry that lowered the balance counts: withdrawals, hold captures
+	// and outgoing transfers. Adjustments and opening entries only record a
+	// balance the wallet already had and do not.
+	query := `SELECT
+			COALESCE(SUM(amount) FILTER (WHERE created_at > NOW() - $2::interval), 0),
+			COALESCE(SUM(amount), 0)
+		FROM wallet_transactions
+		WHERE wallet_id = $1 AND balance_change < 0 AND operation_type NOT IN ('` + string(models.ADJUSTMENT) + `', '` + string(models.OPENING) + `')
+			AND created_at > NOW() - $3::interval`
+	err := db.QueryRow(ctx, query, walletID, dailyLimitWindow, monthlyLimitWindow).Scan(&limits.DailyUsed, &limits.MonthlyUsed)
+	if err != nil {
+		return nil, dbError("sum withdrawals", err)
+	}
+	return limits, nil
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/NKV510/wallet-service/internal/models"
+)
+
+// outboxLockID is the pg_advisory_xact_lock key held while a batch is relayed,
+// so that with several instances running only one publishes at a time and
+// events leave in order.
+const outboxLockID int64 = 7_401_522_094
+
+func (r *WalletRepository) RelayOutbox(ctx context.Context, limit int, publish PublishFunc) (int64, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, dbError("begin transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var locked bool
+	if err := tx.QueryRow(ctx, "SELECT pg_try_advisory_xact_lock($1)", outboxLockID).Scan(&locked); err != nil {
+		return 0, dbError("lock outbox", err)
+	}
+	if !locked {
+		return 0, nil
+	}
+
+	// An id is taken at insert, so a transaction still in flight may yet
+	// commit an event with a smaller id than one already visible. The batch
+	// stops before the first event whose transaction is not older than every
+	// transaction in flight; the rest wait for the next run.
+	query := `WITH pending AS (
+			SELECT id, event_id, event_type, wallet_id, payload, created_at,
+				xid < pg_snapshot_xmin(pg_current_snapshot()) AS settled
+			FROM outbox_events
+			WHERE published_at IS NULL
+			ORDER BY id
+			LIMIT $1
+		)
+		SELECT id, event_id, event_type, wallet_id, payload, created_at
+		FROM pending
+		WHERE id < COALESCE((SELECT MIN(id) FROM pending WHERE NOT settled), 9223372036854775807)
+		ORDER BY id`
+	rows, err := tx.Query(ctx, query, limit)
+	if err != nil {
+		return 0, dbError("list outbox events", err)
+	}
+
+	var events []models.Event
+	for rows.Next() {
+		var event models.Event
+		err := rows.Scan(&event.Sequence, &event.ID, &event.Type, &event.WalletID, &event.Payload, &event.CreatedAt)
+		if err != nil {
+			rows.Close()
+			return 0, dbError("scan outbox event", err)
+		}
+		events = append(events, event)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, dbError("list outbox events", err)
+	}
+
+	published := make([]int64, 0, len(events))
+	var publishErr error
+	for _, event := range events {
+		if err := publish(ctx, event); err != nil {
+			publishErr = fmt.Errorf("failed to publish event %d: %w", event.Sequence, err)
+			break
+		}
+		published = append(published, event.Sequence)
+	}
+
+	if len(published) > 0 {
+		_, err := tx.Exec(ctx, "UPDATE outbox_events SET published_at = NOW() WHERE id = ANY($1)", published)
+		if err != nil {
+			return 0, dbError("mark outbox events published", err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return 0, dbError("commit transaction", err)
+		}
+	}
+	return int64(len(published)), publishErr
+}
+
+func (r *WalletRepository) DeletePublishedEvents(ctx context.Context, retention time.Duration) (int64, error) {
+	query := `DELETE FROM outbox_events WHERE published_at <= NOW() - $1::interval`
+	tag, err := r.db.Exec(ctx, query, retention)
+	if err != nil {
+		return 0, dbError("delete published events", err)
+	}
+	return tag.RowsAffected(), nil
+}
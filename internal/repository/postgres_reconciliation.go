@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/NKV510/wallet-service/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+const ledgerTotalsQuery = `SELECT wallet_id, SUM(balance_change)::bigint AS total
+	FROM wallet_transactions
+	GROUP BY wallet_id`
+
+func (r *WalletRepository) FindBalanceDrift(ctx context.Context) (int64, []models.BalanceDrift, error) {
+	tx, err := r.db.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return 0, nil, dbError("begin transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var checked int64
+	if err := tx.QueryRow(ctx, "SELECT COUNT(*) FROM wallets").Scan(&checked); err != nil {
+		return 0, nil, dbError("count wallets", err)
+	}
+
+	rows, err := tx.Query(ctx, `WITH totals AS (`+ledgerTotalsQuery+`)
+		SELECT w.id, COALESCE(t.total, 0), w.balance + `+shardBalanceSubquery+`
+		FROM wallets w
+		LEFT JOIN totals t ON t.wallet_id = w.id
+		WHERE w.balance + `+shardBalanceSubquery+` <> COALESCE(t.total, 0)
+		ORDER BY w.id`)
+	if err != nil {
+		return 0, nil, dbError("find balance drift", err)
+	}
+	defer rows.Close()
+
+	drifts := []models.BalanceDrift{}
+	for rows.Next() {
+		var walletID string
+		var expected, actual int64
+		if err := rows.Scan(&walletID, &expected, &actual); err != nil {
+			return 0, nil, dbError("scan balance drift", err)
+		}
+		drifts = append(drifts, newBalanceDrift(walletID, expected, actual))
+	}
+	if err := rows.Err(); err != nil {
+		return 0, nil, dbError("find balance drift", err)
+	}
+	return checked, drifts, nil
+}
+
+func (r *WalletRepository) CorrectBalanceDrift(ctx context.Context, walletID string) (*models.BalanceDrift, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, dbError("begin transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	wallet, err := lockWallet(ctx, tx, walletID)
+	if err != nil {
+		return nil, err
+	}
+
+	var expected int64
+	query := `SELECT COALESCE(SUM(balance_change), 0)::bigint FROM wallet_transactions WHERE wallet_id = $1`
+	if err := tx.QueryRow(ctx, query, wallet.ID).Scan(&expected); err != nil {
+		return nil, dbError("sum ledger entries", err)
+	}
+	if expected == wallet.Balance {
+		return nil, nil
+	}
+
+	drift := newBalanceDrift(wallet.ID, expected, wallet.Balance)
+	if err := recordTransactions(ctx, tx, adjustmentEntry(drift)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, dbError("commit adjustment", err)
+	}
+	drift.Corrected = true
+	return &drift, nil
+}
+
+func (r *WalletRepository) SaveReconciliationReport(ctx context.Context, report models.ReconciliationReport) (*models.ReconciliationReport, error) {
+	drifts, err := json.Marshal(report.Drifts)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `INSERT INTO reconciliation_runs (started_at, finished_at, auto_correct, wallets_checked, drifts, error)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`
+	err = r.db.QueryRow(ctx, query, report.StartedAt, report.FinishedAt, report.AutoCorrect, report.WalletsChecked, drifts, report.Error).Scan(&report.ID)
+	if err != nil {
+		return nil, dbError("save reconciliation report", err)
+	}
+	return &report, nil
+}
+
+func (r *WalletRepository) LastReconciliationReport(ctx context.Context) (*models.ReconciliationReport, error) {
+	var report models.ReconciliationReport
+	var drifts []byte
+	query := `SELECT id, started_at, finished_at, auto_correct, wallets_checked, drifts, error
+		FROM reconciliation_runs
+		ORDER BY started_at DESC
+		LIMIT 1`
+	err := r.db.QueryRow(ctx, query).Scan(
+		&report.ID,
+		&report.StartedAt,
+		&report.FinishedAt,
+		&report.AutoCorrect,
+		&report.WalletsChecked,
+		&drifts,
+		&report.Error,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNoReconciliation
+		}
+		return nil, dbError("get reconciliation report", err)
+	}
+	if err := json.Unmarshal(drifts, &report.Drifts); err != nil {
+		return nil, fmt.Errorf("failed to decode reconciliation drifts: %w", err)
+	}
+	return &report, nil
+}
@@ -0,0 +1,272 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/NKV510/wallet-service/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// A sharded wallet keeps part of its balance in wallet_balance_shards; the
+// whole balance is the wallet row plus its shards. Deposits add to one
+// random shard and withdrawals take from as many shards as they need, both
+// holding only a key share lock on the wallet row, the weakest lock, so they
+// run side by side. Everything that changes the row's status, flags or shard
+// count locks it through lockWallet, whose FOR UPDATE waits for them, and
+// empties the shards into the row; the compactor spreads it out again.
+const shardBalanceSubquery = `COALESCE((SELECT SUM(s.balance) FROM wallet_balance_shards s
+	WHERE s.wallet_id = w.id), 0)::bigint`
+
+// shardDB is a pool or a transaction. Begin on a transaction starts a
+// savepoint.
+type shardDB interface {
+	queryRower
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// shardedBalanceQuery returns, for the wallet changed by the changed CTE,
+// the columns the updated CTE of balanceChangeQuery needs. The balance is
+// read from the statement snapshot, so it leaves out operations on other
+// shards that have not committed yet; it is only returned to the caller, and
+// the ledger entry records the change alone.
+func shardedBalanceQuery(changed, sign string) string {
+	return `SELECT w.id, w.currency, w.balance + ` + shardBalanceSubquery + ` ` + sign + ` $2 AS balance,
+			NULL::bigint AS balance_before, NULL::bigint AS balance_after, ` + sign + `$2 AS balance_change
+		FROM wallets w WHERE w.id IN (SELECT wallet_id FROM ` + changed + `)`
+}
+
+// updateShards applies a deposit or withdrawal to the shards of a sharded
+// wallet. It reports ok=false, changing nothing, when the wallet is not
+// sharded, the row does not allow the operation like in
+// updateBalanceStatement, or the shards that are not locked hold too little.
+func (r *WalletRepository) updateShards(ctx context.Context, db shardDB, op models.WalletOperation) (int64, bool, error) {
+	switch op.OperationType {
+	case models.DEPOSIT:
+		return r.depositToShard(ctx, db, op)
+	case models.WITHDRAW:
+		return r.withdrawFromShards(ctx, db, op)
+	default:
+		return 0, false, nil
+	}
+}
+
+func (r *WalletRepository) depositToShard(ctx context.Context, db queryRower, op models.WalletOperation) (int64, bool, error) {
+	query := balanceChangeQuery(`wallet AS (
+			SELECT id, floor(random() * shard_count)::int AS shard FROM wallets
+			WHERE id = $1 AND shard_count > 0 AND (status = 'ACTIVE' OR (status = 'FROZEN' AND $5))
+				AND ($6 = '' OR currency = $6)
+			FOR KEY SHARE
+		),
+		deposited AS (
+			UPDATE wallet_balance_shards s SET balance = s.balance + $2, updated_at = NOW()
+			FROM wallet WHERE s.wallet_id = wallet.id AND s.shard = wallet.shard
+			RETURNING s.wallet_id
+		),
+		updated AS (` + shardedBalanceQuery("deposited", "+") + `)`)
+
+	var balance int64
+	err := db.QueryRow(ctx, query, op.WalletID, op.Amount, op.OperationType, models.EventBalanceChanged, r.frozenAllowsDeposits, op.Currency).Scan(&balance)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, false, nil
+		}
+		return 0, false, dbError("deposit to wallet shard", err)
+	}
+	return balance, true, nil
+}
+
+// withdrawFromShards locks shards one at a time, the richest first, until
+// they hold amount. Shards locked by other withdrawals and deposits are
+// skipped rather than waited for, so withdrawals never deadlock on them;
+// when the rest is not enough, applyOperation decides with the whole wallet
+// locked.
+func (r *WalletRepository) withdrawFromShards(ctx context.Context, db shardDB, op models.WalletOperation) (int64, bool, error) {
+	if r.withdrawalLimits != (models.WithdrawalLimits{}) {
+		return 0, false, nil
+	}
+
+	var sharded bool
+	err := db.QueryRow(ctx, "SELECT shard_count > 0 FROM wallets WHERE id = $1", op.WalletID).Scan(&sharded)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, dbError("get wallet shards", err)
+	}
+	if !sharded {
+		return 0, false, nil
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return 0, false, dbError("begin transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `SELECT id FROM wallets
+		WHERE id = $1 AND shard_count > 0 AND status = 'ACTIVE' AND NOT has_holds AND NOT has_limits
+			AND ($2 = '' OR currency = $2)
+		FOR KEY SHARE`
+	var walletID string
+	if err := tx.QueryRow(ctx, query, op.WalletID, op.Currency).Scan(&walletID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, false, nil
+		}
+		return 0, false, dbError("lock wallet", err)
+	}
+
+	shards, amounts := []int32{}, []int64{}
+	for remaining := op.Amount; remaining > 0; {
+		var shard int32
+		var balance int64
+		query := `SELECT shard, balance FROM wallet_balance_shards
+			WHERE wallet_id = $1 AND balance > 0 AND shard <> ALL($2::int[])
+			ORDER BY balance DESC
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED`
+		if err := tx.QueryRow(ctx, query, walletID, shards).Scan(&shard, &balance); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return 0, false, nil
+			}
+			return 0, false, dbError("lock wallet shard", err)
+		}
+
+		taken := min(balance, remaining)
+		shards = append(shards, shard)
+		amounts = append(amounts, taken)
+		remaining -= taken
+	}
+
+	query = balanceChangeQuery(`taken AS (
+			UPDATE wallet_balance_shards s SET balance = s.balance - t.amount, updated_at = NOW()
+			FROM unnest($5::int[], $6::bigint[]) AS t(shard, amount)
+			WHERE s.wallet_id = $1 AND s.shard = t.shard
+			RETURNING s.wallet_id
+		),
+		updated AS (` + shardedBalanceQuery("taken", "-") + `)`)
+
+	var balance int64
+	err = tx.QueryRow(ctx, query, walletID, op.Amount, op.OperationType, models.EventBalanceChanged, shards, amounts).Scan(&balance)
+	if err != nil {
+		return 0, false, dbError("withdraw from wallet shards", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, false, dbError("commit transaction", err)
+	}
+	return balance, true, nil
+}
+
+// collapseShards empties the shards of a wallet locked by tx into its row and
+// returns the row balance.
+func collapseShards(ctx context.Context, tx pgx.Tx, walletID string) (int64, error) {
+	query := `WITH emptied AS (
+			UPDATE wallet_balance_shards SET balance = 0, updated_at = NOW()
+			WHERE wallet_id = $1 AND balance <> 0
+		)
+		UPDATE wallets w SET balance = w.balance + ` + shardBalanceSubquery + `
+		WHERE w.id = $1
+		RETURNING w.balance`
+	var balance int64
+	if err := tx.QueryRow(ctx, query, walletID).Scan(&balance); err != nil {
+		return 0, dbError("collapse wallet shards", err)
+	}
+	return balance, nil
+}
+
+// spreadShards moves the balance of a wallet locked by tx, with its shards
+// collapsed, from its row into its shards evenly.
+func spreadShards(ctx context.Context, tx pgx.Tx, walletID string, balance int64, shards int) error {
+	if shards == 0 || balance <= 0 {
+		return nil
+	}
+
+	query := `WITH spread AS (
+			UPDATE wallet_balance_shards
+			SET balance = $2::bigint / $3::int + CASE WHEN shard < $2::bigint % $3::int THEN 1 ELSE 0 END, updated_at = NOW()
+			WHERE wallet_id = $1
+		)
+		UPDATE wallets SET balance = 0 WHERE id = $1`
+	if _, err := tx.Exec(ctx, query, walletID, balance, shards); err != nil {
+		return dbError("spread wallet shards", err)
+	}
+	return nil
+}
+
+// SetWalletShards replaces the wallet's shards with shards new ones and
+// spreads the balance over them.
+func (r *WalletRepository) SetWalletShards(ctx context.Context, walletID string, shards int) (*models.Wallet, error) {
+	if err := validateShardCount(shards); err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, dbError("begin transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	wallet, err := lockWallet(ctx, tx, walletID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(ctx, "DELETE FROM wallet_balance_shards WHERE wallet_id = $1", wallet.ID); err != nil {
+		return nil, dbError("delete wallet shards", err)
+	}
+	query := `WITH added AS (
+			INSERT INTO wallet_balance_shards (wallet_id, shard)
+			SELECT $1, generate_series(0, $2 - 1)
+		)
+		UPDATE wallets SET shard_count = $2, updated_at = NOW() WHERE id = $1`
+	if _, err := tx.Exec(ctx, query, wallet.ID, shards); err != nil {
+		return nil, dbError("set wallet shards", err)
+	}
+	if err := spreadShards(ctx, tx, wallet.ID, wallet.Balance, shards); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, dbError("commit transaction", err)
+	}
+	return r.GetWallet(ctx, walletID)
+}
+
+func (r *WalletRepository) CompactWalletShards(ctx context.Context) (int64, error) {
+	rows, err := r.db.Query(ctx, "SELECT id FROM wallets WHERE shard_count > 0 ORDER BY id")
+	if err != nil {
+		return 0, dbError("list sharded wallets", err)
+	}
+	walletIDs, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return 0, dbError("list sharded wallets", err)
+	}
+
+	var compacted int64
+	for _, walletID := range walletIDs {
+		if err := r.compactWalletShards(ctx, walletID); err != nil {
+			return compacted, err
+		}
+		compacted++
+	}
+	return compacted, nil
+}
+
+func (r *WalletRepository) compactWalletShards(ctx context.Context, walletID string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return dbError("begin transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	wallet, err := lockWallet(ctx, tx, walletID)
+	if err != nil {
+		return err
+	}
+	if err := spreadShards(ctx, tx, wallet.ID, wallet.Balance, wallet.Shards); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return dbError("commit transaction", err)
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/NKV510/wallet-service/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	webhookColumns  = `id, url, event_types, wallet_ids, active, created_at, updated_at`
+	deliveryColumns = `id, webhook_id, event_id, event_type, wallet_id, payload, status, attempts,
+		next_attempt_at, last_attempt_at, last_status_code, last_error, created_at, updated_at`
+)
+
+func (r *WalletRepository) CreateWebhook(ctx context.Context, webhook models.WebhookSubscription, secret string) (*models.WebhookSubscription, error) {
+	if webhook.WalletIDs == nil {
+		webhook.WalletIDs = []string{}
+	}
+
+	query := `INSERT INTO webhook_subscriptions (url, event_types, wallet_ids, secret, active)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING ` + webhookColumns
+	created, err := scanWebhook(r.db.QueryRow(ctx, query, webhook.URL, webhook.EventTypes, webhook.WalletIDs, secret, webhook.Active))
+	if err != nil {
+		return nil, dbError("create webhook", err)
+	}
+	return created, nil
+}
+
+func (r *WalletRepository) GetWebhook(ctx context.Context, id string) (*models.WebhookSubscription, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhook_subscriptions WHERE id::text = $1`
+	webhook, err := scanWebhook(r.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrWebhookNotFound, id)
+		}
+		return nil, dbError("get webhook", err)
+	}
+	return webhook, nil
+}
+
+func (r *WalletRepository) ListWebhooks(ctx context.Context) ([]models.WebhookSubscription, error) {
+	rows, err := r.db.Query(ctx, `SELECT `+webhookColumns+` FROM webhook_subscriptions ORDER BY created_at, id`)
+	if err != nil {
+		return nil, dbError("list webhooks", err)
+	}
+	defer rows.Close()
+
+	webhooks := []models.WebhookSubscription{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, dbError("scan webhook", err)
+		}
+		webhooks = append(webhooks, *webhook)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError("list webhooks", err)
+	}
+	return webhooks, nil
+}
+
+func (r *WalletRepository) UpdateWebhook(ctx context.Context, webhook models.WebhookSubscription, secret string) (*models.WebhookSubscription, error) {
+	if webhook.WalletIDs == nil {
+		webhook.WalletIDs = []string{}
+	}
+
+	query := `UPDATE webhook_subscriptions
+		SET url = $2, event_types = $3, wallet_ids = $4, secret = COALESCE(NULLIF($5, ''), secret), active = $6, updated_at = NOW()
+		WHERE id::text = $1
+		RETURNING ` + webhookColumns
+	updated, err := scanWebhook(r.db.QueryRow(ctx, query, webhook.ID, webhook.URL, webhook.EventTypes, webhook.WalletIDs, secret, webhook.Active))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrWebhookNotFound, webhook.ID)
+		}
+		return nil, dbError("update webhook", err)
+	}
+	return updated, nil
+}
+
+// DeleteWebhook removes the subscription together with its delivery log.
+func (r *WalletRepository) DeleteWebhook(ctx context.Context, id string) error {
+	tag, err := r.db.Exec(ctx, `DELETE FROM webhook_subscriptions WHERE id::text = $1`, id)
+	if err != nil {
+		return dbError("delete webhook", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: %s", ErrWebhookNotFound, id)
+	}
+	return nil
+}
+
+func (r *WalletRepository) EnqueueWebhookDeliveries(ctx context.Context, delivery models.WebhookDelivery) (int64, error) {
+	query := `INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, wallet_id, payload, next_attempt_at)
+		SELECT id, $1::uuid, $2::text, $3::text::uuid, $4::jsonb, NOW()
+		FROM webhook_subscriptions
+		WHERE active
+			AND $2::text = ANY(event_types)
+			AND (cardinality(wallet_ids) = 0 OR $3::text = ANY(wallet_ids))
+		ON CONFLICT (webhook_id, event_id) DO NOTHING`
+	tag, err := r.db.Exec(ctx, query, delivery.EventID, delivery.EventType, delivery.WalletID, delivery.Payload)
+	if err != nil {
+		return 0, dbError("enqueue webhook deliveries", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// ClaimWebhookDeliveries leases deliveries by moving next_attempt_at forward,
+// so a worker that dies mid-send lets them become due again. SKIP LOCKED lets
+// several instances claim disjoint batches.
+func (r *WalletRepository) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]WebhookDeliveryTask, error) {
+	query := `WITH claimed AS (
+			UPDATE webhook_deliveries
+			SET next_attempt_at = NOW() + $2::interval
+			WHERE id IN (
+				SELECT d.id FROM webhook_deliveries d
+				JOIN webhook_subscriptions s ON s.id = d.webhook_id
+				WHERE d.status = 'PENDING' AND d.next_attempt_at <= NOW() AND s.active
+				ORDER BY d.next_attempt_at, d.id
+				LIMIT $1
+				FOR UPDATE OF d SKIP LOCKED
+			)
+			RETURNING *
+		)
+		SELECT c.id, c.webhook_id, c.event_id, c.event_type, c.wallet_id, c.payload, c.status, c.attempts,
+			c.next_attempt_at, c.last_attempt_at, c.last_status_code, c.last_error, c.created_at, c.updated_at,
+			s.url, s.secret
+		FROM claimed c
+		JOIN webhook_subscriptions s ON s.id = c.webhook_id
+		ORDER BY c.created_at, c.id`
+	rows, err := r.db.Query(ctx, query, limit, lease)
+	if err != nil {
+		return nil, dbError("claim webhook deliveries", err)
+	}
+	defer rows.Close()
+
+	var tasks []WebhookDeliveryTask
+	for rows.Next() {
+		var task WebhookDeliveryTask
+		err := rows.Scan(append(deliveryFields(&task.WebhookDelivery), &task.URL, &task.Secret)...)
+		if err != nil {
+			return nil, dbError("scan webhook delivery", err)
+		}
+		tasks = append(tasks, task)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError("claim webhook deliveries", err)
+	}
+	return tasks, nil
+}
+
+func (r *WalletRepository) RecordWebhookAttempt(ctx context.Context, deliveryID string, attempt WebhookAttempt) error {
+	var nextAttemptAt *time.Time
+	if attempt.Status == models.DeliveryPending {
+		nextAttemptAt = &attempt.NextAttemptAt
+	}
+
+	query := `UPDATE webhook_deliveries
+		SET status = $2, attempts = attempts + 1, next_attempt_at = $3, last_attempt_at = NOW(),
+			last_status_code = $4, last_error = $5, updated_at = NOW()
+		WHERE id::text = $1`
+	tag, err := r.db.Exec(ctx, query, deliveryID, attempt.Status, nextAttemptAt, attempt.StatusCode, attempt.Error)
+	if err != nil {
+		return dbError("record webhook attempt", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: %s", ErrDeliveryNotFound, deliveryID)
+	}
+	return nil
+}
+
+func (r *WalletRepository) ListWebhookDeliveries(ctx context.Context, webhookID string, filter models.WebhookDeliveryFilter) ([]models.WebhookDelivery, error) {
+	if _, err := r.GetWebhook(ctx, webhookID); err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries
+		WHERE webhook_id::text = $1 AND ($2::text = '' OR status = $2::text)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3`
+	rows, err := r.db.Query(ctx, query, webhookID, string(filter.Status), filter.Limit)
+	if err != nil {
+		return nil, dbError("list webhook deliveries", err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		if err := rows.Scan(deliveryFields(&delivery)...); err != nil {
+			return nil, dbError("scan webhook delivery", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError("list webhook deliveries", err)
+	}
+	return deliveries, nil
+}
+
+func (r *WalletRepository) RedeliverWebhook(ctx context.Context, webhookID, deliveryID string) (*models.WebhookDelivery, error) {
+	query := `UPDATE webhook_deliveries
+		SET status = 'PENDING', attempts = 0, next_attempt_at = NOW(), updated_at = NOW()
+		WHERE id::text = $1 AND webhook_id::text = $2
+		RETURNING ` + deliveryColumns
+	var delivery models.WebhookDelivery
+	err := r.db.QueryRow(ctx, query, deliveryID, webhookID).Scan(deliveryFields(&delivery)...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrDeliveryNotFound, deliveryID)
+		}
+		return nil, dbError("redeliver webhook", err)
+	}
+	return &delivery, nil
+}
+
+func scanWebhook(row pgx.Row) (*models.WebhookSubscription, error) {
+	var webhook models.WebhookSubscription
+	err := row.Scan(
+		&webhook.ID,
+		&webhook.URL,
+		&webhook.EventTypes,
+		&webhook.WalletIDs,
+		&webhook.Active,
+		&webhook.CreatedAt,
+		&webhook.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// deliveryFields lists the scan targets for deliveryColumns.
+func deliveryFields(d *models.WebhookDelivery) []any {
+	return []any{
+		&d.ID,
+		&d.WebhookID,
+		&d.EventID,
+		&d.EventType,
+		&d.WalletID,
+		&d.Payload,
+		&d.Status,
+		&d.Attempts,
+		&d.NextAttemptAt,
+		&d.LastAttemptAt,
+		&d.LastStatusCode,
+		&d.LastError,
+		&d.CreatedAt,
+		&d.UpdatedAt,
+	}
+}
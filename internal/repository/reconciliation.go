@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/NKV510/wallet-service/internal/models"
+)
+
+// ReconciliationStore compares wallet balances with their ledger entries and
+// keeps the results of reconciliation runs.
+type ReconciliationStore interface {
+	// FindBalanceDrift checks every wallet against the sum of its ledger
+	// entries, both read from one snapshot, and returns the number of
+	// wallets checked and the ones that differ.
+	FindBalanceDrift(ctx context.Context) (int64, []models.BalanceDrift, error)
+	// CorrectBalanceDrift checks the wallet again under its lock and, if it
+	// still drifts, records an ADJUSTMENT entry for the difference: the
+	// balance is taken as the truth and the ledger is made to match it. It
+	// returns nil when there was nothing to correct.
+	CorrectBalanceDrift(ctx context.Context, walletID string) (*models.BalanceDrift, error)
+
+	SaveReconciliationReport(ctx context.Context, report models.ReconciliationReport) (*models.ReconciliationReport, error)
+	LastReconciliationReport(ctx context.Context) (*models.ReconciliationReport, error)
+}
+
+var (
+	_ ReconciliationStore = (*WalletRepository)(nil)
+	_ ReconciliationStore = (*MemoryWalletRepository)(nil)
+)
+
+func newBalanceDrift(walletID string, expected, actual int64) models.BalanceDrift {
+	return models.BalanceDrift{WalletID: walletID, Expected: expected, Actual: actual, Difference: actual - expected}
+}
+
+// adjustmentEntry is the ledger entry that makes the history of a drifted
+// wallet add up to its balance.
+func adjustmentEntry(drift models.BalanceDrift) models.Transaction {
+	amount := drift.Difference
+	if amount < 0 {
+		amount = -amount
+	}
+	entry := models.Transaction{
+		WalletID:      drift.WalletID,
+		OperationType: models.ADJUSTMENT,
+		Amount:        amount,
+	}
+	entry.SetBalances(drift.Expected, drift.Actual)
+	return entry
+}
@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/NKV510/wallet-service/internal/models"
+)
+
+// MaxWalletShards caps the shard rows of one wallet. Withdrawals may have to
+// visit several of them, so more shards only help deposits.
+const MaxWalletShards = 64
+
+// ShardStore spreads the balance of hot wallets over shard rows, so that
+// concurrent operations on one wallet do not all queue on its row lock.
+type ShardStore interface {
+	// SetWalletShards spreads the wallet's balance over shards rows, or
+	// moves it back into the wallet row when shards is zero.
+	SetWalletShards(ctx context.Context, walletID string, shards int) (*models.Wallet, error)
+	// CompactWalletShards spreads the balance of every sharded wallet
+	// evenly over its shards again and returns how many it compacted.
+	CompactWalletShards(ctx context.Context) (int64, error)
+}
+
+var (
+	_ ShardStore = (*WalletRepository)(nil)
+	_ ShardStore = (*MemoryWalletRepository)(nil)
+)
+
+func validateShardCount(shards int) error {
+	if shards < 0 || shards > MaxWalletShards {
+		return fmt.Errorf("%w: shards must be between 0 and %d", ErrInvalidOperation, MaxWalletShards)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/NKV510/wallet-service/internal/models"
+)
+
+// checkWalletStatus reports whether the wallet may be credited (DEPOSIT) or
+// debited (WITHDRAW) in its current status. Frozen wallets reject debits and,
+// unless the repository allows it, credits; closed wallets reject both.
+func (o options) checkWalletStatus(wallet *models.Wallet, direction models.OperationType) error {
+	switch wallet.Status {
+	case models.WalletFrozen:
+		if direction == models.DEPOSIT && o.frozenAllowsDeposits {
+			return nil
+		}
+		return fmt.Errorf("%w: wallet %s is frozen", ErrWalletNotActive, wallet.ID)
+	case models.WalletClosed:
+		return fmt.Errorf("%w: wallet %s is closed", ErrWalletNotActive, wallet.ID)
+	default:
+		return nil
+	}
+}
+
+// checkStatusTransition validates moving the wallet to status. Closing needs
+// an empty wallet so that no money is stranded.
+func checkStatusTransition(wallet *models.Wallet, status models.WalletStatus) error {
+	switch status {
+	case models.WalletFrozen:
+		if wallet.Status != models.WalletActive {
+			return fmt.Errorf("%w: cannot freeze a %s wallet", ErrConflict, wallet.Status)
+		}
+	case models.WalletActive:
+		if wallet.Status != models.WalletFrozen {
+			return fmt.Errorf("%w: cannot unfreeze a %s wallet", ErrConflict, wallet.Status)
+		}
+	case models.WalletClosed:
+		if wallet.Status == models.WalletClosed {
+			return fmt.Errorf("%w: wallet is already closed", ErrConflict)
+		}
+		if wallet.Balance != 0 || wallet.Held != 0 {
+			return fmt.Errorf("%w: wallet balance must be zero to close it", ErrInvalidOperation)
+		}
+	default:
+		return fmt.Errorf("%w: invalid wallet status %q", ErrInvalidOperation, status)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/NKV510/wallet-service/internal/models"
+)
+
+// WalletStore is the storage used by the HTTP handlers. WalletRepository
+// implements it on top of Postgres and MemoryWalletRepository in memory; both
+// must pass the same conformance suite. IDs are passed in the lower-case form
+// of models.NormalizeID, which the handlers put them in.
+type WalletStore interface {
+	CreateWallet(ctx context.Context, walletID string) error
+	GetWallet(ctx context.Context, walletID string) (*models.Wallet, error)
+	UpdateWalletBalance(ctx context.Context, walletID string, operationType models.OperationType, amount int64) error
+	ProcessOperation(ctx context.Context, operation models.WalletOperation) (int64, bool, error)
+	Transfer(ctx context.Context, fromWalletID, toWalletID string, amount int64) (*models.TransferResponse, error)
+	ApplyBatch(ctx context.Context, mode models.BatchMode, operations []models.WalletOperation) ([]models.BatchItemResult, error)
+	ListTransactions(ctx context.Context, walletID string, filter models.TransactionFilter) ([]models.Transaction, string, error)
+	DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error)
+	SetWalletStatus(ctx context.Context, walletID string, status models.WalletStatus, reason string) (*models.Wallet, error)
+	GetWalletLimits(ctx context.Context, walletID string) (*models.WalletLimitsResponse, error)
+	SetWalletLimits(ctx context.Context, walletID string, override models.WithdrawalLimitsOverride) (*models.WalletLimitsResponse, error)
+
+	CreateHold(ctx context.Context, walletID string, amount int64, ttl time.Duration) (*models.Hold, error)
+	CaptureHold(ctx context.Context, walletID, holdID string, amount int64) (*models.Hold, error)
+	VoidHold(ctx context.Context, walletID, holdID string) (*models.Hold, error)
+	ExpireHolds(ctx context.Context) (int64, error)
+}
+
+var (
+	_ WalletStore = (*WalletRepository)(nil)
+	_ WalletStore = (*MemoryWalletRepository)(nil)
+)
@@ -0,0 +1,2295 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NKV510/wallet-service/internal/metrics"
+	"github.com/NKV510/wallet-service/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newStoreFunc returns an empty store. Every subtest of the conformance suite
+// gets its own store.
+type newStoreFunc func(t *testing.T, opts ...Option) WalletStore
+
+// newAPIKeyStoreFunc returns an empty API key store.
+type newAPIKeyStoreFunc func(t *testing.T) APIKeyStore
+
+// newWebhookStoreFunc returns an empty webhook store.
+type newWebhookStoreFunc func(t *testing.T) WebhookStore
+
+// exchangeTestStore is a wallet store that also applies exchanges, which
+// the exchange suite needs to fund and inspect wallets.
+type exchangeTestStore interface {
+	WalletStore
+	ExchangeStore
+}
+
+// newExchangeStoreFunc returns an empty store with no exchange rates.
+type newExchangeStoreFunc func(t *testing.T) exchangeTestStore
+
+// ledgerTestStore can apply every kind of operation that posts to the
+// journal.
+type ledgerTestStore interface {
+	exchangeTestStore
+	LedgerStore
+}
+
+// newLedgerStoreFunc returns an empty store with an empty journal.
+type newLedgerStoreFunc func(t *testing.T) ledgerTestStore
+
+type reconciliationTestStore interface {
+	ledgerTestStore
+	ReconciliationStore
+}
+
+// newReconciliationStoreFunc returns an empty store and a function that
+// overwrites a wallet's balance without a ledger entry, as a bug or a manual
+// fix in the database would.
+type newReconciliationStoreFunc func(t *testing.T) (reconciliationTestStore, func(walletID string, balance int64))
+
+// shardTestStore can shard wallets and check them against their ledger.
+type shardTestStore interface {
+	reconciliationTestStore
+	ShardStore
+}
+
+// newShardStoreFunc returns an empty store.
+type newShardStoreFunc func(t *testing.T) shardTestStore
+
+func int64Ptr(v int64) *int64 { return &v }
+
+// runWalletStoreSuite checks the behaviour every WalletStore implementation
+// must share.
+func runWalletStoreSuite(t *testing.T, newStore newStoreFunc) {
+	t.Run("CreateWallet", func(t *testing.T) { testCreateWallet(t, newStore) })
+	t.Run("GetWallet", func(t *testing.T) { testGetWallet(t, newStore) })
+	t.Run("UpdateWalletBalance", func(t *testing.T) { testUpdateWalletBalance(t, newStore) })
+	t.Run("UpdateWalletBalanceConcurrent", func(t *testing.T) { testUpdateWalletBalanceConcurrent(t, newStore) })
+	t.Run("ListTransactions", func(t *testing.T) { testListTransactions(t, newStore) })
+	t.Run("ProcessOperation", func(t *testing.T) { testProcessOperation(t, newStore) })
+	t.Run("ProcessOperationIdempotent", func(t *testing.T) { testProcessOperationIdempotent(t, newStore) })
+	t.Run("Currency", func(t *testing.T) { testCurrency(t, newStore) })
+	t.Run("Transfer", func(t *testing.T) { testTransfer(t, newStore) })
+	t.Run("ApplyBatch", func(t *testing.T) { testApplyBatch(t, newStore) })
+	t.Run("Holds", func(t *testing.T) { testHolds(t, newStore) })
+	t.Run("WalletStatus", func(t *testing.T) { testWalletStatus(t, newStore) })
+	t.Run("WithdrawalLimits", func(t *testing.T) { testWithdrawalLimits(t, newStore) })
+	t.Run("Metrics", func(t *testing.T) { testMetrics(t, newStore) })
+	t.Run("Outbox", func(t *testing.T) { testOutbox(t, newStore) })
+}
+
+// benchmarkOperation sends op to a single wallet from parallel goroutines,
+// the worst case for row lock contention, and reports the throughput as
+// ops/s. setup prepares the wallet, which must afford every withdrawal.
+func benchmarkOperation(b *testing.B, repo WalletStore, op models.WalletOperation, setup func(ctx context.Context, repo WalletStore) error) {
+	ctx := context.Background()
+	require.NoError(b, setup(ctx, repo))
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, _, err := repo.ProcessOperation(ctx, op); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "ops/s")
+}
+
+// benchmarkProcessOperation compares deposits and withdrawals on one wallet.
+// Withdrawals from a wallet with a hold cannot use the single statement and
+// show the cost of the locking transaction; the sharded variants show what
+// spreading the balance over shard rows gains over the plain ones, which only
+// PostgreSQL can show: the in-memory store takes the same path for both.
+// TestWalletRepository_ShardedThroughput asserts the gain.
+func benchmarkProcessOperation(b *testing.B, newStore func(b *testing.B) shardTestStore) {
+	walletID := "123e4567-e89b-12d3-a456-426614174000"
+	funded := func(ctx context.Context, repo WalletStore) error {
+		_, _, err := repo.ProcessOperation(ctx, models.WalletOperation{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 1 << 40})
+		return err
+	}
+
+	b.Run("deposit", func(b *testing.B) {
+		benchmarkOperation(b, newStore(b), models.WalletOperation{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 1}, funded)
+	})
+	b.Run("withdraw", func(b *testing.B) {
+		benchmarkOperation(b, newStore(b), models.WalletOperation{WalletID: walletID, OperationType: models.WITHDRAW, Amount: 1}, funded)
+	})
+	sharded := func(ctx context.Context, repo shardTestStore) error {
+		if err := funded(ctx, repo); err != nil {
+			return err
+		}
+		_, err := repo.SetWalletShards(ctx, walletID, 16)
+		return err
+	}
+	b.Run("deposit sharded", func(b *testing.B) {
+		repo := newStore(b)
+		benchmarkOperation(b, repo, models.WalletOperation{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 1}, func(ctx context.Context, _ WalletStore) error {
+			return sharded(ctx, repo)
+		})
+	})
+	b.Run("withdraw sharded", func(b *testing.B) {
+		repo := newStore(b)
+		benchmarkOperation(b, repo, models.WalletOperation{WalletID: walletID, OperationType: models.WITHDRAW, Amount: 1}, func(ctx context.Context, _ WalletStore) error {
+			return sharded(ctx, repo)
+		})
+	})
+	b.Run("withdraw with hold", func(b *testing.B) {
+		benchmarkOperation(b, newStore(b), models.WalletOperation{WalletID: walletID, OperationType: models.WITHDRAW, Amount: 1}, func(ctx context.Context, repo WalletStore) error {
+			if err := funded(ctx, repo); err != nil {
+				return err
+			}
+			_, err := repo.CreateHold(ctx, walletID, 1, time.Hour)
+			return err
+		})
+	})
+}
+
+func testCreateWallet(t *testing.T, newStore newStoreFunc) {
+	repo := newStore(t)
+
+	tests := []struct {
+		name      string
+		walletID  string
+		wantError error
+	}{
+		{
+			name:     "successful wallet creation",
+			walletID: "123e4567-e89b-12d3-a456-426614174000",
+		},
+		{
+			name:      "duplicate wallet creation",
+			walletID:  "123e4567-e89b-12d3-a456-426614174000",
+			wantError: ErrConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := repo.CreateWallet(context.Background(), tt.walletID)
+
+			if tt.wantError != nil {
+				assert.ErrorIs(t, err, tt.wantError)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func testGetWallet(t *testing.T, newStore newStoreFunc) {
+	repo := newStore(t)
+
+	walletID := "123e4567-e89b-12d3-a456-426614174000"
+	err := repo.CreateWallet(context.Background(), walletID)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		walletID  string
+		wantError bool
+	}{
+		{
+			name:      "get existing wallet",
+			walletID:  walletID,
+			wantError: false,
+		},
+		{
+			name:      "get non-existing wallet",
+			walletID:  "00000000-0000-0000-0000-000000000000",
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wallet, err := repo.GetWallet(context.Background(), tt.walletID)
+
+			if tt.wantError {
+				assert.ErrorIs(t, err, ErrWalletNotFound)
+				assert.Nil(t, wallet)
+			} else {
+				assert.NoError(t, err)
+				assert.NotNil(t, wallet)
+				assert.Equal(t, walletID, wallet.ID)
+				assert.Equal(t, int64(0), wallet.Balance)
+			}
+		})
+	}
+}
+
+func testUpdateWalletBalance(t *testing.T, newStore newStoreFunc) {
+	repo := newStore(t)
+
+	walletID := "123e4567-e89b-12d3-a456-426614174000"
+	err := repo.CreateWallet(context.Background(), walletID)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		walletID      string
+		operationType models.OperationType
+		amount        int64
+		wantError     error
+		errorContains string
+	}{
+		{
+			name:          "successful deposit",
+			walletID:      walletID,
+			operationType: models.DEPOSIT,
+			amount:        1000,
+		},
+		{
+			name:          "successful withdraw",
+			walletID:      walletID,
+			operationType: models.WITHDRAW,
+			amount:        500,
+		},
+		{
+			name:          "insufficient funds",
+			walletID:      walletID,
+			operationType: models.WITHDRAW,
+			amount:        1000,
+			wantError:     ErrInsufficientFunds,
+			errorContains: "insufficient funds",
+		},
+		{
+			name:          "invalid operation type",
+			walletID:      walletID,
+			operationType: "INVALID",
+			amount:        100,
+			wantError:     ErrInvalidOperation,
+			errorContains: "invalid operation type",
+		},
+		{
+			name:          "non-existing wallet",
+			walletID:      "00000000-0000-0000-0000-000000000000",
+			operationType: models.DEPOSIT,
+			amount:        100,
+			wantError:     ErrWalletNotFound,
+			errorContains: "wallet not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := repo.UpdateWalletBalance(context.Background(), tt.walletID, tt.operationType, tt.amount)
+
+			if tt.wantError != nil {
+				assert.ErrorIs(t, err, tt.wantError)
+				assert.ErrorContains(t, err, tt.errorContains)
+			} else {
+				assert.NoError(t, err)
+
+				wallet, err := repo.GetWallet(context.Background(), walletID)
+				assert.NoError(t, err)
+				assert.NotNil(t, wallet)
+
+				if tt.operationType == models.DEPOSIT {
+					assert.True(t, wallet.Balance >= tt.amount)
+				} else if tt.operationType == models.WITHDRAW {
+					assert.True(t, wallet.Balance >= 0)
+				}
+			}
+		})
+	}
+
+	wallet, err := repo.GetWallet(context.Background(), walletID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(500), wallet.Balance)
+}
+
+func testUpdateWalletBalanceConcurrent(t *testing.T, newStore newStoreFunc) {
+	repo := newStore(t)
+	ctx := context.Background()
+
+	walletID := "123e4567-e89b-12d3-a456-426614174000"
+	require.NoError(t, repo.CreateWallet(ctx, walletID))
+	require.NoError(t, repo.UpdateWalletBalance(ctx, walletID, models.DEPOSIT, 100))
+
+	var wg sync.WaitGroup
+	results := make(chan error, 200)
+	for i := 0; i < 100; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			results <- repo.UpdateWalletBalance(ctx, walletID, models.DEPOSIT, 1)
+		}()
+		go func() {
+			defer wg.Done()
+			results <- repo.UpdateWalletBalance(ctx, walletID, models.WITHDRAW, 2)
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	var failed int64
+	for err := range results {
+		if err != nil {
+			require.ErrorIs(t, err, ErrInsufficientFunds)
+			failed++
+		}
+	}
+
+	wallet, err := repo.GetWallet(ctx, walletID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100+100-2*(100-failed)), wallet.Balance)
+	assert.True(t, wallet.Balance >= 0)
+}
+
+func testListTransactions(t *testing.T, newStore newStoreFunc) {
+	repo := newStore(t)
+	ctx := context.Background()
+
+	walletID := "123e4567-e89b-12d3-a456-426614174000"
+	require.NoError(t, repo.CreateWallet(ctx, walletID))
+	require.NoError(t, repo.UpdateWalletBalance(ctx, walletID, models.DEPOSIT, 1000))
+	require.NoError(t, repo.UpdateWalletBalance(ctx, walletID, models.WITHDRAW, 300))
+	require.NoError(t, repo.UpdateWalletBalance(ctx, walletID, models.DEPOSIT, 200))
+
+	t.Run("records balance before and after", func(t *testing.T) {
+		transactions, next, err := repo.ListTransactions(ctx, walletID, models.TransactionFilter{Limit: 10})
+		require.NoError(t, err)
+		assert.Empty(t, next)
+		require.Len(t, transactions, 3)
+
+		assert.Equal(t, models.DEPOSIT, transactions[0].OperationType)
+		assert.Equal(t, int64Ptr(700), transactions[0].BalanceBefore)
+		assert.Equal(t, int64Ptr(900), transactions[0].BalanceAfter)
+
+		assert.Equal(t, models.WITHDRAW, transactions[1].OperationType)
+		assert.Equal(t, int64Ptr(1000), transactions[1].BalanceBefore)
+		assert.Equal(t, int64Ptr(700), transactions[1].BalanceAfter)
+
+		assert.Equal(t, int64Ptr(0), transactions[2].BalanceBefore)
+		assert.Equal(t, int64Ptr(1000), transactions[2].BalanceAfter)
+	})
+
+	t.Run("paginates with cursor", func(t *testing.T) {
+		first, next, err := repo.ListTransactions(ctx, walletID, models.TransactionFilter{Limit: 2})
+		require.NoError(t, err)
+		require.Len(t, first, 2)
+		require.NotEmpty(t, next)
+
+		after, err := DecodeTransactionCursor(next)
+		require.NoError(t, err)
+
+		second, next, err := repo.ListTransactions(ctx, walletID, models.TransactionFilter{Limit: 2, After: after})
+		require.NoError(t, err)
+		assert.Empty(t, next)
+		require.Len(t, second, 1)
+		assert.Equal(t, int64Ptr(1000), second[0].BalanceAfter)
+	})
+
+	t.Run("filters by type", func(t *testing.T) {
+		transactions, _, err := repo.ListTransactions(ctx, walletID, models.TransactionFilter{Limit: 10, OperationType: models.WITHDRAW})
+		require.NoError(t, err)
+		require.Len(t, transactions, 1)
+		assert.Equal(t, int64(300), transactions[0].Amount)
+	})
+
+	t.Run("filters by date range", func(t *testing.T) {
+		transactions, _, err := repo.ListTransactions(ctx, walletID, models.TransactionFilter{Limit: 10, To: time.Now().Add(-time.Hour)})
+		require.NoError(t, err)
+		assert.Empty(t, transactions)
+
+		transactions, _, err = repo.ListTransactions(ctx, walletID, models.TransactionFilter{Limit: 10, From: time.Now().Add(-time.Hour)})
+		require.NoError(t, err)
+		assert.Len(t, transactions, 3)
+	})
+
+	t.Run("orders entries of one transaction by write order", func(t *testing.T) {
+		batchWalletID := "223e4567-e89b-12d3-a456-426614174000"
+		_, err := repo.ApplyBatch(ctx, models.BatchAtomic, []models.WalletOperation{
+			{WalletID: batchWalletID, OperationType: models.DEPOSIT, Amount: 1},
+			{WalletID: batchWalletID, OperationType: models.DEPOSIT, Amount: 2},
+			{WalletID: batchWalletID, OperationType: models.DEPOSIT, Amount: 3},
+			{WalletID: batchWalletID, OperationType: models.DEPOSIT, Amount: 4},
+		})
+		require.NoError(t, err)
+
+		var amounts []int64
+		filter := models.TransactionFilter{Limit: 1}
+		for {
+			transactions, next, err := repo.ListTransactions(ctx, batchWalletID, filter)
+			require.NoError(t, err)
+			for _, transaction := range transactions {
+				amounts = append(amounts, transaction.Amount)
+			}
+			if next == "" {
+				break
+			}
+			filter.After, err = DecodeTransactionCursor(next)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, []int64{4, 3, 2, 1}, amounts)
+	})
+}
+
+func testProcessOperationIdempotent(t *testing.T, newStore newStoreFunc) {
+	repo := newStore(t)
+	ctx := context.Background()
+
+	walletID := "123e4567-e89b-12d3-a456-426614174000"
+	require.NoError(t, repo.CreateWallet(ctx, walletID))
+	operation := func(key string, operationType models.OperationType, amount int64) models.WalletOperation {
+		return models.WalletOperation{WalletID: walletID, OperationType: operationType, Amount: amount, IdempotencyKey: key}
+	}
+
+	_, replayed, err := repo.ProcessOperation(ctx, operation("key-1", models.DEPOSIT, 1000))
+	require.NoError(t, err)
+	assert.False(t, replayed)
+
+	_, replayed, err = repo.ProcessOperation(ctx, operation("key-1", models.DEPOSIT, 1000))
+	require.NoError(t, err)
+	assert.True(t, replayed)
+
+	_, _, err = repo.ProcessOperation(ctx, operation("key-1", models.DEPOSIT, 2000))
+	assert.ErrorIs(t, err, ErrIdempotencyKeyReused)
+
+	_, _, err = repo.ProcessOperation(ctx, operation("key-2", models.WITHDRAW, 5000))
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
+
+	_, replayed, err = repo.ProcessOperation(ctx, operation("key-2", models.WITHDRAW, 500))
+	require.NoError(t, err)
+	assert.False(t, replayed, "a failed operation must not consume its key")
+
+	wallet, err := repo.GetWallet(ctx, walletID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(500), wallet.Balance)
+
+	t.Run("expired keys are cleaned up and reusable", func(t *testing.T) {
+		repo := newStore(t, WithIdempotencyTTL(100*time.Millisecond))
+		require.NoError(t, repo.CreateWallet(ctx, walletID))
+
+		_, _, err := repo.ProcessOperation(ctx, operation("key-1", models.DEPOSIT, 1000))
+		require.NoError(t, err)
+
+		time.Sleep(200 * time.Millisecond)
+
+		deleted, err := repo.DeleteExpiredIdempotencyKeys(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), deleted)
+
+		balance, replayed, err := repo.ProcessOperation(ctx, operation("key-1", models.DEPOSIT, 2000))
+		require.NoError(t, err)
+		assert.False(t, replayed)
+		assert.Equal(t, int64(3000), balance)
+	})
+}
+
+func testProcessOperation(t *testing.T, newStore newStoreFunc) {
+	ctx := context.Background()
+	walletID := "123e4567-e89b-12d3-a456-426614174000"
+
+	deposit := func(amount int64) models.WalletOperation {
+		return models.WalletOperation{WalletID: walletID, OperationType: models.DEPOSIT, Amount: amount}
+	}
+	withdraw := func(amount int64) models.WalletOperation {
+		return models.WalletOperation{WalletID: walletID, OperationType: models.WITHDRAW, Amount: amount}
+	}
+
+	t.Run("returns the new balance", func(t *testing.T) {
+		repo := newStore(t)
+
+		balance, replayed, err := repo.ProcessOperation(ctx, deposit(100))
+		require.NoError(t, err)
+		assert.False(t, replayed)
+		assert.Equal(t, int64(100), balance, "the first deposit creates the wallet")
+
+		balance, _, err = repo.ProcessOperation(ctx, withdraw(30))
+		require.NoError(t, err)
+		assert.Equal(t, int64(70), balance)
+
+		_, _, err = repo.ProcessOperation(ctx, withdraw(71))
+		assert.ErrorIs(t, err, ErrInsufficientFunds)
+
+		transactions, _, err := repo.ListTransactions(ctx, walletID, models.TransactionFilter{Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, transactions, 2)
+		assert.Equal(t, int64Ptr(100), transactions[0].BalanceBefore)
+		assert.Equal(t, int64Ptr(70), transactions[0].BalanceAfter)
+	})
+
+	t.Run("withdrawal from a new wallet", func(t *testing.T) {
+		repo := newStore(t)
+
+		_, _, err := repo.ProcessOperation(ctx, withdraw(1))
+		assert.ErrorIs(t, err, ErrInsufficientFunds)
+		_, err = repo.GetWallet(ctx, walletID)
+		assert.ErrorIs(t, err, ErrWalletNotFound)
+	})
+
+	t.Run("concurrent first deposits", func(t *testing.T) {
+		repo := newStore(t)
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 20)
+		for range 20 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, _, err := repo.ProcessOperation(ctx, deposit(5))
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			assert.NoError(t, err)
+		}
+		wallet, err := repo.GetWallet(ctx, walletID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(100), wallet.Balance)
+	})
+
+	t.Run("idempotency key", func(t *testing.T) {
+		repo := newStore(t)
+		op := deposit(100)
+		op.IdempotencyKey = "op-1"
+
+		balance, replayed, err := repo.ProcessOperation(ctx, op)
+		require.NoError(t, err)
+		assert.False(t, replayed)
+		assert.Equal(t, int64(100), balance)
+
+		_, _, err = repo.ProcessOperation(ctx, deposit(50))
+		require.NoError(t, err)
+
+		balance, replayed, err = repo.ProcessOperation(ctx, op)
+		require.NoError(t, err)
+		assert.True(t, replayed)
+		assert.Equal(t, int64(100), balance, "a replay returns the balance of the original operation")
+
+		op.Amount = 1
+		_, _, err = repo.ProcessOperation(ctx, op)
+		assert.ErrorIs(t, err, ErrIdempotencyKeyReused)
+	})
+
+	t.Run("wallet status", func(t *testing.T) {
+		repo := newStore(t, WithFrozenDeposits(false))
+		_, _, err := repo.ProcessOperation(ctx, deposit(100))
+		require.NoError(t, err)
+		_, err = repo.SetWalletStatus(ctx, walletID, models.WalletFrozen, "review")
+		require.NoError(t, err)
+
+		_, _, err = repo.ProcessOperation(ctx, deposit(1))
+		assert.ErrorIs(t, err, ErrWalletNotActive)
+		_, _, err = repo.ProcessOperation(ctx, withdraw(1))
+		assert.ErrorIs(t, err, ErrWalletNotActive)
+	})
+
+	t.Run("holds are respected", func(t *testing.T) {
+		repo := newStore(t)
+		_, _, err := repo.ProcessOperation(ctx, deposit(100))
+		require.NoError(t, err)
+		hold, err := repo.CreateHold(ctx, walletID, 80, time.Hour)
+		require.NoError(t, err)
+
+		_, _, err = repo.ProcessOperation(ctx, withdraw(30))
+		assert.ErrorIs(t, err, ErrInsufficientFunds)
+
+		_, err = repo.VoidHold(ctx, walletID, hold.ID)
+		require.NoError(t, err)
+		for _, amount := range []int64{30, 20} {
+			_, _, err = repo.ProcessOperation(ctx, withdraw(amount))
+			require.NoError(t, err)
+		}
+
+		_, err = repo.CreateHold(ctx, walletID, 40, time.Hour)
+		require.NoError(t, err)
+		_, _, err = repo.ProcessOperation(ctx, withdraw(11))
+		assert.ErrorIs(t, err, ErrInsufficientFunds, "a new hold counts again after the wallet had none")
+	})
+
+	t.Run("limits are respected", func(t *testing.T) {
+		repo := newStore(t)
+		_, _, err := repo.ProcessOperation(ctx, deposit(1000))
+		require.NoError(t, err)
+
+		perOperation := int64(50)
+		_, err = repo.SetWalletLimits(ctx, walletID, models.WithdrawalLimitsOverride{PerOperation: &perOperation})
+		require.NoError(t, err)
+		_, _, err = repo.ProcessOperation(ctx, withdraw(60))
+		assert.ErrorIs(t, err, ErrLimitExceeded)
+
+		_, err = repo.SetWalletLimits(ctx, walletID, models.WithdrawalLimitsOverride{})
+		require.NoError(t, err)
+		_, _, err = repo.ProcessOperation(ctx, withdraw(60))
+		assert.NoError(t, err)
+
+		limited := newStore(t, WithWithdrawalLimits(models.WithdrawalLimits{PerOperation: 50}))
+		_, _, err = limited.ProcessOperation(ctx, deposit(1000))
+		require.NoError(t, err)
+		_, _, err = limited.ProcessOperation(ctx, withdraw(60))
+		assert.ErrorIs(t, err, ErrLimitExceeded)
+	})
+}
+
+func testCurrency(t *testing.T, newStore newStoreFunc) {
+	ctx := context.Background()
+	walletID := "123e4567-e89b-12d3-a456-426614174000"
+	otherID := "223e4567-e89b-12d3-a456-426614174000"
+
+	operation := func(operationType models.OperationType, amount int64, currency string) models.WalletOperation {
+		return models.WalletOperation{WalletID: walletID, OperationType: operationType, Amount: amount, Currency: currency}
+	}
+
+	t.Run("default currency", func(t *testing.T) {
+		repo := newStore(t, WithDefaultCurrency("EUR"))
+		require.NoError(t, repo.CreateWallet(ctx, walletID))
+		_, _, err := repo.ProcessOperation(ctx, models.WalletOperation{WalletID: otherID, OperationType: models.DEPOSIT, Amount: 1})
+		require.NoError(t, err)
+
+		for _, id := range []string{walletID, otherID} {
+			wallet, err := repo.GetWallet(ctx, id)
+			require.NoError(t, err)
+			assert.Equal(t, "EUR", wallet.Currency)
+		}
+	})
+
+	t.Run("the first deposit sets the currency", func(t *testing.T) {
+		repo := newStore(t)
+		balance, _, err := repo.ProcessOperation(ctx, operation(models.DEPOSIT, 500, "JPY"))
+		require.NoError(t, err)
+		assert.Equal(t, int64(500), balance)
+
+		wallet, err := repo.GetWallet(ctx, walletID)
+		require.NoError(t, err)
+		assert.Equal(t, "JPY", wallet.Currency)
+
+		_, _, err = repo.ProcessOperation(ctx, operation(models.DEPOSIT, 100, "USD"))
+		assert.ErrorIs(t, err, ErrCurrencyMismatch)
+		_, _, err = repo.ProcessOperation(ctx, operation(models.WITHDRAW, 100, "USD"))
+		assert.ErrorIs(t, err, ErrCurrencyMismatch)
+
+		balance, _, err = repo.ProcessOperation(ctx, operation(models.WITHDRAW, 100, "JPY"))
+		require.NoError(t, err)
+		assert.Equal(t, int64(400), balance, "rejected operations change nothing")
+	})
+
+	t.Run("replay in another currency", func(t *testing.T) {
+		repo := newStore(t)
+		op := operation(models.DEPOSIT, 100, "EUR")
+		op.IdempotencyKey = "op-1"
+		_, _, err := repo.ProcessOperation(ctx, op)
+		require.NoError(t, err)
+
+		op.Currency = "GBP"
+		_, _, err = repo.ProcessOperation(ctx, op)
+		assert.ErrorIs(t, err, ErrIdempotencyKeyReused, "the currency is part of the request the key was used for")
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		repo := newStore(t)
+		_, err := repo.ApplyBatch(ctx, models.BatchAtomic, []models.WalletOperation{
+			operation(models.DEPOSIT, 100, "GBP"),
+			operation(models.DEPOSIT, 100, "EUR"),
+		})
+		assert.ErrorIs(t, err, ErrCurrencyMismatch)
+		_, err = repo.GetWallet(ctx, walletID)
+		assert.ErrorIs(t, err, ErrWalletNotFound, "the failed batch created nothing")
+
+		_, err = repo.ApplyBatch(ctx, models.BatchAtomic, []models.WalletOperation{
+			operation(models.DEPOSIT, 100, "GBP"),
+			operation(models.WITHDRAW, 40, "GBP"),
+		})
+		require.NoError(t, err)
+		wallet, err := repo.GetWallet(ctx, walletID)
+		require.NoError(t, err)
+		assert.Equal(t, "GBP", wallet.Currency)
+		assert.Equal(t, int64(60), wallet.Balance)
+	})
+
+	t.Run("transfer between currencies", func(t *testing.T) {
+		repo := newStore(t)
+		_, _, err := repo.ProcessOperation(ctx, operation(models.DEPOSIT, 100, "EUR"))
+		require.NoError(t, err)
+		_, _, err = repo.ProcessOperation(ctx, models.WalletOperation{WalletID: otherID, OperationType: models.DEPOSIT, Amount: 1, Currency: "USD"})
+		require.NoError(t, err)
+
+		_, err = repo.Transfer(ctx, walletID, otherID, 50)
+		assert.ErrorIs(t, err, ErrCurrencyMismatch)
+	})
+}
+
+func testTransfer(t *testing.T, newStore newStoreFunc) {
+	repo := newStore(t)
+	ctx := context.Background()
+
+	walletA := "123e4567-e89b-12d3-a456-426614174000"
+	walletB := "223e4567-e89b-12d3-a456-426614174000"
+	require.NoError(t, repo.CreateWallet(ctx, walletA))
+	require.NoError(t, repo.CreateWallet(ctx, walletB))
+	require.NoError(t, repo.UpdateWalletBalance(ctx, walletA, models.DEPOSIT, 1000))
+
+	tests := []struct {
+		name      string
+		from      string
+		to        string
+		amount    int64
+		wantError error
+	}{
+		{
+			name:   "successful transfer",
+			from:   walletA,
+			to:     walletB,
+			amount: 400,
+		},
+		{
+			name:      "insufficient funds",
+			from:      walletA,
+			to:        walletB,
+			amount:    1000,
+			wantError: ErrInsufficientFunds,
+		},
+		{
+			name:      "same wallet",
+			from:      walletA,
+			to:        walletA,
+			amount:    100,
+			wantError: ErrInvalidOperation,
+		},
+		{
+			name:      "non-existing destination",
+			from:      walletA,
+			to:        "00000000-0000-0000-0000-000000000000",
+			amount:    100,
+			wantError: ErrWalletNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := repo.Transfer(ctx, tt.from, tt.to, tt.amount)
+
+			if tt.wantError != nil {
+				assert.ErrorIs(t, err, tt.wantError)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotEmpty(t, result.TransferID)
+			assert.Equal(t, int64(600), result.FromBalance)
+			assert.Equal(t, int64(400), result.ToBalance)
+
+			transactions, _, err := repo.ListTransactions(ctx, walletB, models.TransactionFilter{Limit: 10})
+			require.NoError(t, err)
+			require.Len(t, transactions, 1)
+			assert.Equal(t, models.TRANSFER, transactions[0].OperationType)
+			assert.Equal(t, result.TransferID, transactions[0].TransferID)
+		})
+	}
+
+	t.Run("concurrent opposite transfers do not deadlock", func(t *testing.T) {
+		var wg sync.WaitGroup
+		errs := make(chan error, 100)
+		for i := 0; i < 50; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				_, err := repo.Transfer(ctx, walletA, walletB, 1)
+				errs <- err
+			}()
+			go func() {
+				defer wg.Done()
+				_, err := repo.Transfer(ctx, walletB, walletA, 1)
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			assert.NoError(t, err)
+		}
+
+		a, err := repo.GetWallet(ctx, walletA)
+		require.NoError(t, err)
+		b, err := repo.GetWallet(ctx, walletB)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1000), a.Balance+b.Balance)
+	})
+}
+
+func testApplyBatch(t *testing.T, newStore newStoreFunc) {
+	ctx := context.Background()
+	walletA := "123e4567-e89b-12d3-a456-426614174000"
+	walletB := "223e4567-e89b-12d3-a456-426614174000"
+	walletC := "323e4567-e89b-12d3-a456-426614174000"
+
+	setup := func(t *testing.T) WalletStore {
+		repo := newStore(t)
+		require.NoError(t, repo.CreateWallet(ctx, walletA))
+		require.NoError(t, repo.CreateWallet(ctx, walletB))
+		require.NoError(t, repo.UpdateWalletBalance(ctx, walletA, models.DEPOSIT, 100))
+		return repo
+	}
+	balance := func(t *testing.T, repo WalletStore, walletID string) int64 {
+		wallet, err := repo.GetWallet(ctx, walletID)
+		require.NoError(t, err)
+		return wallet.Balance
+	}
+	statuses := func(results []models.BatchItemResult) []models.BatchItemStatus {
+		var out []models.BatchItemStatus
+		for _, result := range results {
+			out = append(out, result.Status)
+		}
+		return out
+	}
+
+	t.Run("atomic batch applies every operation", func(t *testing.T) {
+		repo := setup(t)
+
+		results, err := repo.ApplyBatch(ctx, models.BatchAtomic, []models.WalletOperation{
+			{WalletID: walletA, OperationType: models.WITHDRAW, Amount: 60},
+			{WalletID: walletB, OperationType: models.DEPOSIT, Amount: 60},
+			{WalletID: walletC, OperationType: models.DEPOSIT, Amount: 10},
+			{WalletID: walletA, OperationType: models.WITHDRAW, Amount: 40},
+		})
+		require.NoError(t, err)
+		require.Len(t, results, 4)
+
+		wantBalances := []int64{40, 60, 10, 0}
+		for i, result := range results {
+			assert.Equal(t, i, result.Index)
+			assert.Equal(t, models.BatchItemApplied, result.Status)
+			require.NotNil(t, result.Balance)
+			assert.Equal(t, wantBalances[i], *result.Balance)
+			assert.NoError(t, result.Err)
+		}
+		assert.Equal(t, int64(0), balance(t, repo, walletA))
+		assert.Equal(t, int64(60), balance(t, repo, walletB))
+		assert.Equal(t, int64(10), balance(t, repo, walletC), "wallets are created on their first operation")
+
+		transactions, _, err := repo.ListTransactions(ctx, walletA, models.TransactionFilter{Limit: 10})
+		require.NoError(t, err)
+		assert.Len(t, transactions, 3)
+	})
+
+	t.Run("atomic batch rolls back on failure", func(t *testing.T) {
+		repo := setup(t)
+
+		results, err := repo.ApplyBatch(ctx, models.BatchAtomic, []models.WalletOperation{
+			{WalletID: walletA, OperationType: models.DEPOSIT, Amount: 50, IdempotencyKey: "batch-1"},
+			{WalletID: walletC, OperationType: models.DEPOSIT, Amount: 10},
+			{WalletID: walletA, OperationType: models.WITHDRAW, Amount: 500},
+			{WalletID: walletB, OperationType: models.DEPOSIT, Amount: 5},
+		})
+		require.ErrorIs(t, err, ErrInsufficientFunds)
+		assert.Contains(t, err.Error(), "operation 2")
+		require.Len(t, results, 4)
+		assert.Equal(t, []models.BatchItemStatus{
+			models.BatchItemRolledBack,
+			models.BatchItemRolledBack,
+			models.BatchItemFailed,
+			models.BatchItemSkipped,
+		}, statuses(results))
+		assert.ErrorIs(t, results[2].Err, ErrInsufficientFunds)
+		for _, result := range results {
+			assert.Nil(t, result.Balance)
+		}
+
+		assert.Equal(t, int64(100), balance(t, repo, walletA))
+		assert.Equal(t, int64(0), balance(t, repo, walletB))
+		_, err = repo.GetWallet(ctx, walletC)
+		assert.ErrorIs(t, err, ErrWalletNotFound, "wallets created by the batch are rolled back")
+
+		transactions, _, err := repo.ListTransactions(ctx, walletA, models.TransactionFilter{Limit: 10})
+		require.NoError(t, err)
+		assert.Len(t, transactions, 1)
+
+		_, replayed, err := repo.ProcessOperation(ctx, models.WalletOperation{WalletID: walletB, OperationType: models.DEPOSIT, Amount: 1, IdempotencyKey: "batch-1"})
+		require.NoError(t, err)
+		assert.False(t, replayed, "idempotency keys of a rolled back batch are released")
+	})
+
+	t.Run("independent batch reports each operation", func(t *testing.T) {
+		repo := setup(t)
+		_, err := repo.SetWalletStatus(ctx, walletB, models.WalletFrozen, "review")
+		require.NoError(t, err)
+
+		results, err := repo.ApplyBatch(ctx, models.BatchIndependent, []models.WalletOperation{
+			{WalletID: walletA, OperationType: models.WITHDRAW, Amount: 30},
+			{WalletID: walletA, OperationType: models.WITHDRAW, Amount: 500},
+			{WalletID: walletB, OperationType: models.WITHDRAW, Amount: 1},
+			{WalletID: walletC, OperationType: models.WITHDRAW, Amount: 1},
+			{WalletID: walletA, OperationType: models.DEPOSIT, Amount: 5},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []models.BatchItemStatus{
+			models.BatchItemApplied,
+			models.BatchItemFailed,
+			models.BatchItemFailed,
+			models.BatchItemFailed,
+			models.BatchItemApplied,
+		}, statuses(results))
+		assert.ErrorIs(t, results[1].Err, ErrInsufficientFunds)
+		assert.ErrorIs(t, results[2].Err, ErrWalletNotActive)
+		assert.ErrorIs(t, results[3].Err, ErrInsufficientFunds)
+		assert.Equal(t, int64(70), *results[0].Balance)
+		assert.Equal(t, int64(75), *results[4].Balance)
+		assert.Equal(t, int64(75), balance(t, repo, walletA))
+
+		_, err = repo.GetWallet(ctx, walletC)
+		assert.ErrorIs(t, err, ErrWalletNotFound, "a failed operation does not leave its wallet behind")
+	})
+
+	t.Run("idempotency keys", func(t *testing.T) {
+		repo := setup(t)
+		_, _, err := repo.ProcessOperation(ctx, models.WalletOperation{WalletID: walletB, OperationType: models.DEPOSIT, Amount: 20, IdempotencyKey: "payroll-1"})
+		require.NoError(t, err)
+
+		results, err := repo.ApplyBatch(ctx, models.BatchAtomic, []models.WalletOperation{
+			{WalletID: walletB, OperationType: models.DEPOSIT, Amount: 20, IdempotencyKey: "payroll-1"},
+			{WalletID: walletB, OperationType: models.DEPOSIT, Amount: 30, IdempotencyKey: "payroll-2"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []models.BatchItemStatus{models.BatchItemReplayed, models.BatchItemApplied}, statuses(results))
+		assert.Equal(t, int64(20), *results[0].Balance)
+		assert.Equal(t, int64(50), *results[1].Balance)
+		require.NoError(t, repo.UpdateWalletBalance(ctx, walletB, models.DEPOSIT, 5))
+
+		results, err = repo.ApplyBatch(ctx, models.BatchIndependent, []models.WalletOperation{
+			{WalletID: walletB, OperationType: models.DEPOSIT, Amount: 30, IdempotencyKey: "payroll-2"},
+			{WalletID: walletB, OperationType: models.DEPOSIT, Amount: 31, IdempotencyKey: "payroll-1"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []models.BatchItemStatus{models.BatchItemReplayed, models.BatchItemFailed}, statuses(results))
+		assert.Equal(t, int64(50), *results[0].Balance, "a replay returns the balance of the original operation")
+		assert.ErrorIs(t, results[1].Err, ErrIdempotencyKeyReused)
+		assert.Equal(t, int64(55), balance(t, repo, walletB))
+	})
+
+	t.Run("invalid mode", func(t *testing.T) {
+		repo := setup(t)
+		_, err := repo.ApplyBatch(ctx, "sometimes", []models.WalletOperation{
+			{WalletID: walletA, OperationType: models.DEPOSIT, Amount: 1},
+		})
+		assert.ErrorIs(t, err, ErrInvalidOperation)
+	})
+
+	t.Run("concurrent opposite batches do not deadlock", func(t *testing.T) {
+		repo := setup(t)
+		require.NoError(t, repo.UpdateWalletBalance(ctx, walletB, models.DEPOSIT, 100))
+
+		move := func(from, to string) []models.WalletOperation {
+			return []models.WalletOperation{
+				{WalletID: from, OperationType: models.WITHDRAW, Amount: 1},
+				{WalletID: to, OperationType: models.DEPOSIT, Amount: 1},
+			}
+		}
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 100)
+		for i := 0; i < 50; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				_, err := repo.ApplyBatch(ctx, models.BatchAtomic, move(walletA, walletB))
+				errs <- err
+			}()
+			go func() {
+				defer wg.Done()
+				_, err := repo.ApplyBatch(ctx, models.BatchAtomic, move(walletB, walletA))
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, int64(200), balance(t, repo, walletA)+balance(t, repo, walletB))
+	})
+}
+
+func testHolds(t *testing.T, newStore newStoreFunc) {
+	ctx := context.Background()
+	walletID := "123e4567-e89b-12d3-a456-426614174000"
+	otherWalletID := "223e4567-e89b-12d3-a456-426614174000"
+
+	setup := func(t *testing.T, opts ...Option) WalletStore {
+		repo := newStore(t, opts...)
+		require.NoError(t, repo.CreateWallet(ctx, walletID))
+		require.NoError(t, repo.CreateWallet(ctx, otherWalletID))
+		require.NoError(t, repo.UpdateWalletBalance(ctx, walletID, models.DEPOSIT, 1000))
+		return repo
+	}
+
+	assertBalance := func(t *testing.T, repo WalletStore, balance, available int64) {
+		t.Helper()
+		wallet, err := repo.GetWallet(ctx, walletID)
+		require.NoError(t, err)
+		assert.Equal(t, balance, wallet.Balance)
+		assert.Equal(t, available, wallet.Available())
+	}
+
+	t.Run("hold reserves available balance", func(t *testing.T) {
+		repo := setup(t)
+
+		hold, err := repo.CreateHold(ctx, walletID, 600, 0)
+		require.NoError(t, err)
+		assert.Equal(t, models.HoldActive, hold.Status)
+		assertBalance(t, repo, 1000, 400)
+
+		_, err = repo.CreateHold(ctx, walletID, 500, 0)
+		assert.ErrorIs(t, err, ErrInsufficientFunds)
+
+		err = repo.UpdateWalletBalance(ctx, walletID, models.WITHDRAW, 500)
+		assert.ErrorIs(t, err, ErrInsufficientFunds)
+
+		_, err = repo.Transfer(ctx, walletID, otherWalletID, 500)
+		assert.ErrorIs(t, err, ErrInsufficientFunds)
+
+		require.NoError(t, repo.UpdateWalletBalance(ctx, walletID, models.WITHDRAW, 400))
+		assertBalance(t, repo, 600, 0)
+	})
+
+	t.Run("partial capture withdraws and releases the rest", func(t *testing.T) {
+		repo := setup(t)
+
+		hold, err := repo.CreateHold(ctx, walletID, 600, 0)
+		require.NoError(t, err)
+
+		_, err = repo.CaptureHold(ctx, walletID, hold.ID, 700)
+		assert.ErrorIs(t, err, ErrInvalidOperation)
+
+		captured, err := repo.CaptureHold(ctx, walletID, hold.ID, 250)
+		require.NoError(t, err)
+		assert.Equal(t, models.HoldCaptured, captured.Status)
+		assert.Equal(t, int64(250), captured.CapturedAmount)
+		assertBalance(t, repo, 750, 750)
+
+		transactions, _, err := repo.ListTransactions(ctx, walletID, models.TransactionFilter{Limit: 1})
+		require.NoError(t, err)
+		require.Len(t, transactions, 1)
+		assert.Equal(t, models.WITHDRAW, transactions[0].OperationType)
+		assert.Equal(t, hold.ID, transactions[0].HoldID)
+
+		_, err = repo.CaptureHold(ctx, walletID, hold.ID, 0)
+		assert.ErrorIs(t, err, ErrConflict)
+	})
+
+	t.Run("full capture", func(t *testing.T) {
+		repo := setup(t)
+
+		hold, err := repo.CreateHold(ctx, walletID, 600, 0)
+		require.NoError(t, err)
+
+		captured, err := repo.CaptureHold(ctx, walletID, hold.ID, 0)
+		require.NoError(t, err)
+		assert.Equal(t, int64(600), captured.CapturedAmount)
+		assertBalance(t, repo, 400, 400)
+	})
+
+	t.Run("void releases funds", func(t *testing.T) {
+		repo := setup(t)
+
+		hold, err := repo.CreateHold(ctx, walletID, 600, 0)
+		require.NoError(t, err)
+
+		voided, err := repo.VoidHold(ctx, walletID, hold.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.HoldVoided, voided.Status)
+		assertBalance(t, repo, 1000, 1000)
+
+		_, err = repo.VoidHold(ctx, walletID, hold.ID)
+		assert.ErrorIs(t, err, ErrConflict)
+	})
+
+	t.Run("hold belongs to its wallet", func(t *testing.T) {
+		repo := setup(t)
+
+		hold, err := repo.CreateHold(ctx, walletID, 600, 0)
+		require.NoError(t, err)
+
+		_, err = repo.VoidHold(ctx, otherWalletID, hold.ID)
+		assert.ErrorIs(t, err, ErrHoldNotFound)
+
+		_, err = repo.CaptureHold(ctx, walletID, "00000000-0000-0000-0000-000000000000", 0)
+		assert.ErrorIs(t, err, ErrHoldNotFound)
+	})
+
+	t.Run("expired holds release funds", func(t *testing.T) {
+		repo := setup(t, WithHoldTTL(100*time.Millisecond))
+
+		hold, err := repo.CreateHold(ctx, walletID, 600, 0)
+		require.NoError(t, err)
+		assertBalance(t, repo, 1000, 400)
+
+		time.Sleep(200 * time.Millisecond)
+		assertBalance(t, repo, 1000, 1000)
+
+		_, err = repo.CaptureHold(ctx, walletID, hold.ID, 0)
+		assert.ErrorIs(t, err, ErrConflict)
+
+		expired, err := repo.ExpireHolds(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), expired)
+	})
+}
+
+func testWalletStatus(t *testing.T, newStore newStoreFunc) {
+	ctx := context.Background()
+	walletID := "123e4567-e89b-12d3-a456-426614174000"
+	otherWalletID := "223e4567-e89b-12d3-a456-426614174000"
+
+	setup := func(t *testing.T, opts ...Option) WalletStore {
+		repo := newStore(t, opts...)
+		require.NoError(t, repo.CreateWallet(ctx, walletID))
+		require.NoError(t, repo.CreateWallet(ctx, otherWalletID))
+		require.NoError(t, repo.UpdateWalletBalance(ctx, walletID, models.DEPOSIT, 1000))
+		require.NoError(t, repo.UpdateWalletBalance(ctx, otherWalletID, models.DEPOSIT, 1000))
+		return repo
+	}
+
+	t.Run("new wallets are active", func(t *testing.T) {
+		repo := setup(t)
+
+		wallet, err := repo.GetWallet(ctx, walletID)
+		require.NoError(t, err)
+		assert.Equal(t, models.WalletActive, wallet.Status)
+	})
+
+	t.Run("frozen wallet accepts deposits only", func(t *testing.T) {
+		repo := setup(t)
+
+		wallet, err := repo.SetWalletStatus(ctx, walletID, models.WalletFrozen, "suspicious activity")
+		require.NoError(t, err)
+		assert.Equal(t, models.WalletFrozen, wallet.Status)
+		assert.Equal(t, "suspicious activity", wallet.StatusReason)
+
+		wallet, err = repo.GetWallet(ctx, walletID)
+		require.NoError(t, err)
+		assert.Equal(t, models.WalletFrozen, wallet.Status)
+		assert.Equal(t, "suspicious activity", wallet.StatusReason)
+
+		require.NoError(t, repo.UpdateWalletBalance(ctx, walletID, models.DEPOSIT, 100))
+
+		err = repo.UpdateWalletBalance(ctx, walletID, models.WITHDRAW, 100)
+		assert.ErrorIs(t, err, ErrWalletNotActive)
+
+		_, err = repo.Transfer(ctx, walletID, otherWalletID, 100)
+		assert.ErrorIs(t, err, ErrWalletNotActive)
+
+		_, err = repo.Transfer(ctx, otherWalletID, walletID, 100)
+		require.NoError(t, err)
+
+		_, err = repo.CreateHold(ctx, walletID, 100, 0)
+		assert.ErrorIs(t, err, ErrWalletNotActive)
+
+		wallet, err = repo.GetWallet(ctx, walletID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1200), wallet.Balance)
+	})
+
+	t.Run("frozen deposits can be disabled", func(t *testing.T) {
+		repo := setup(t, WithFrozenDeposits(false))
+
+		_, err := repo.SetWalletStatus(ctx, walletID, models.WalletFrozen, "")
+		require.NoError(t, err)
+
+		err = repo.UpdateWalletBalance(ctx, walletID, models.DEPOSIT, 100)
+		assert.ErrorIs(t, err, ErrWalletNotActive)
+	})
+
+	t.Run("frozen wallet cannot capture holds", func(t *testing.T) {
+		repo := setup(t)
+
+		hold, err := repo.CreateHold(ctx, walletID, 100, 0)
+		require.NoError(t, err)
+		_, err = repo.SetWalletStatus(ctx, walletID, models.WalletFrozen, "")
+		require.NoError(t, err)
+
+		_, err = repo.CaptureHold(ctx, walletID, hold.ID, 0)
+		assert.ErrorIs(t, err, ErrWalletNotActive)
+
+		_, err = repo.VoidHold(ctx, walletID, hold.ID)
+		require.NoError(t, err)
+	})
+
+	t.Run("unfreeze restores operations", func(t *testing.T) {
+		repo := setup(t)
+
+		_, err := repo.SetWalletStatus(ctx, walletID, models.WalletFrozen, "")
+		require.NoError(t, err)
+		_, err = repo.SetWalletStatus(ctx, walletID, models.WalletActive, "")
+		require.NoError(t, err)
+
+		require.NoError(t, repo.UpdateWalletBalance(ctx, walletID, models.WITHDRAW, 100))
+	})
+
+	t.Run("closed wallet rejects everything", func(t *testing.T) {
+		repo := setup(t)
+
+		_, err := repo.SetWalletStatus(ctx, walletID, models.WalletClosed, "")
+		assert.ErrorIs(t, err, ErrInvalidOperation)
+
+		require.NoError(t, repo.UpdateWalletBalance(ctx, walletID, models.WITHDRAW, 1000))
+		wallet, err := repo.SetWalletStatus(ctx, walletID, models.WalletClosed, "customer request")
+		require.NoError(t, err)
+		assert.Equal(t, models.WalletClosed, wallet.Status)
+
+		err = repo.UpdateWalletBalance(ctx, walletID, models.DEPOSIT, 100)
+		assert.ErrorIs(t, err, ErrWalletNotActive)
+
+		_, err = repo.Transfer(ctx, otherWalletID, walletID, 100)
+		assert.ErrorIs(t, err, ErrWalletNotActive)
+
+		_, err = repo.SetWalletStatus(ctx, walletID, models.WalletActive, "")
+		assert.ErrorIs(t, err, ErrConflict)
+	})
+
+	t.Run("invalid transitions", func(t *testing.T) {
+		repo := setup(t)
+
+		_, err := repo.SetWalletStatus(ctx, walletID, models.WalletActive, "")
+		assert.ErrorIs(t, err, ErrConflict)
+
+		_, err = repo.SetWalletStatus(ctx, walletID, models.WalletFrozen, "")
+		require.NoError(t, err)
+		_, err = repo.SetWalletStatus(ctx, walletID, models.WalletFrozen, "")
+		assert.ErrorIs(t, err, ErrConflict)
+
+		_, err = repo.SetWalletStatus(ctx, "00000000-0000-0000-0000-000000000000", models.WalletFrozen, "")
+		assert.ErrorIs(t, err, ErrWalletNotFound)
+	})
+}
+
+func testWithdrawalLimits(t *testing.T, newStore newStoreFunc) {
+	ctx := context.Background()
+	walletID := "123e4567-e89b-12d3-a456-426614174000"
+	otherWalletID := "223e4567-e89b-12d3-a456-426614174000"
+
+	setup := func(t *testing.T, opts ...Option) WalletStore {
+		repo := newStore(t, opts...)
+		require.NoError(t, repo.CreateWallet(ctx, walletID))
+		require.NoError(t, repo.CreateWallet(ctx, otherWalletID))
+		require.NoError(t, repo.UpdateWalletBalance(ctx, walletID, models.DEPOSIT, 10000))
+		return repo
+	}
+
+	t.Run("no limits by default", func(t *testing.T) {
+		repo := setup(t)
+
+		require.NoError(t, repo.UpdateWalletBalance(ctx, walletID, models.WITHDRAW, 10000))
+	})
+
+	t.Run("per operation limit", func(t *testing.T) {
+		repo := setup(t, WithWithdrawalLimits(models.WithdrawalLimits{PerOperation: 500}))
+
+		err := repo.UpdateWalletBalance(ctx, walletID, models.WITHDRAW, 501)
+		var limitErr *LimitError
+		require.ErrorAs(t, err, &limitErr)
+		assert.ErrorIs(t, err, ErrLimitExceeded)
+		assert.Equal(t, LimitPerOperation, limitErr.Limit)
+		assert.Equal(t, int64(500), limitErr.Remaining)
+
+		require.NoError(t, repo.UpdateWalletBalance(ctx, walletID, models.WITHDRAW, 500))
+		require.NoError(t, repo.UpdateWalletBalance(ctx, walletID, models.DEPOSIT, 5000))
+	})
+
+	t.Run("daily limit counts every debit", func(t *testing.T) {
+		repo := setup(t, WithWithdrawalLimits(models.WithdrawalLimits{Daily: 1000}))
+
+		require.NoError(t, repo.UpdateWalletBalance(ctx, walletID, models.WITHDRAW, 400))
+		_, err := repo.Transfer(ctx, walletID, otherWalletID, 300)
+		require.NoError(t, err)
+		hold, err := repo.CreateHold(ctx, walletID, 200, 0)
+		require.NoError(t, err)
+		_, err = repo.CaptureHold(ctx, walletID, hold.ID, 0)
+		require.NoError(t, err)
+
+		err = repo.UpdateWalletBalance(ctx, walletID, models.WITHDRAW, 101)
+		var limitErr *LimitError
+		require.ErrorAs(t, err, &limitErr)
+		assert.Equal(t, LimitDaily, limitErr.Limit)
+		assert.Equal(t, int64(100), limitErr.Remaining)
+
+		_, err = repo.Transfer(ctx, walletID, otherWalletID, 101)
+		assert.ErrorIs(t, err, ErrLimitExceeded)
+
+		limits, err := repo.GetWalletLimits(ctx, walletID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(900), limits.DailyUsed)
+		assert.Equal(t, int64(900), limits.MonthlyUsed)
+
+		require.NoError(t, repo.UpdateWalletBalance(ctx, walletID, models.WITHDRAW, 100))
+	})
+
+	t.Run("monthly limit", func(t *testing.T) {
+		repo := setup(t, WithWithdrawalLimits(models.WithdrawalLimits{Daily: 5000, Monthly: 700}))
+
+		require.NoError(t, repo.UpdateWalletBalance(ctx, walletID, models.WITHDRAW, 600))
+
+		err := repo.UpdateWalletBalance(ctx, walletID, models.WITHDRAW, 200)
+		var limitErr *LimitError
+		require.ErrorAs(t, err, &limitErr)
+		assert.Equal(t, LimitMonthly, limitErr.Limit)
+		assert.Equal(t, int64(100), limitErr.Remaining)
+	})
+
+	t.Run("wallet override replaces defaults", func(t *testing.T) {
+		repo := setup(t, WithWithdrawalLimits(models.WithdrawalLimits{PerOperation: 100, Daily: 1000}))
+
+		limits, err := repo.SetWalletLimits(ctx, walletID, models.WithdrawalLimitsOverride{PerOperation: int64Ptr(0)})
+		require.NoError(t, err)
+		assert.Equal(t, models.WithdrawalLimits{PerOperation: 0, Daily: 1000}, limits.Limits)
+
+		require.NoError(t, repo.UpdateWalletBalance(ctx, walletID, models.WITHDRAW, 1000))
+		err = repo.UpdateWalletBalance(ctx, walletID, models.WITHDRAW, 1)
+		assert.ErrorIs(t, err, ErrLimitExceeded)
+
+		limits, err = repo.SetWalletLimits(ctx, walletID, models.WithdrawalLimitsOverride{Daily: int64Ptr(2000)})
+		require.NoError(t, err)
+		assert.Equal(t, models.WithdrawalLimits{PerOperation: 100, Daily: 2000}, limits.Limits)
+		assert.Nil(t, limits.Override.PerOperation)
+
+		require.NoError(t, repo.UpdateWalletBalance(ctx, walletID, models.WITHDRAW, 100))
+
+		limits, err = repo.GetWalletLimits(ctx, walletID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2000), *limits.Override.Daily)
+		assert.Equal(t, int64(1100), limits.DailyUsed)
+
+		require.NoError(t, repo.UpdateWalletBalance(ctx, otherWalletID, models.DEPOSIT, 1000))
+		err = repo.UpdateWalletBalance(ctx, otherWalletID, models.WITHDRAW, 200)
+		assert.ErrorIs(t, err, ErrLimitExceeded)
+	})
+
+	t.Run("invalid overrides", func(t *testing.T) {
+		repo := setup(t)
+
+		_, err := repo.SetWalletLimits(ctx, walletID, models.WithdrawalLimitsOverride{Daily: int64Ptr(-1)})
+		assert.ErrorIs(t, err, ErrInvalidOperation)
+
+		_, err = repo.SetWalletLimits(ctx, "00000000-0000-0000-0000-000000000000", models.WithdrawalLimitsOverride{})
+		assert.ErrorIs(t, err, ErrWalletNotFound)
+
+		_, err = repo.GetWalletLimits(ctx, "00000000-0000-0000-0000-000000000000")
+		assert.ErrorIs(t, err, ErrWalletNotFound)
+	})
+}
+
+func testMetrics(t *testing.T, newStore newStoreFunc) {
+	ctx := context.Background()
+	walletID := "123e4567-e89b-12d3-a456-426614174000"
+
+	m := metrics.New()
+	repo := newStore(t, WithMetrics(m))
+	require.NoError(t, repo.CreateWallet(ctx, walletID))
+
+	require.NoError(t, repo.UpdateWalletBalance(ctx, walletID, models.DEPOSIT, 1000))
+	require.NoError(t, repo.UpdateWalletBalance(ctx, walletID, models.WITHDRAW, 300))
+	assert.ErrorIs(t, repo.UpdateWalletBalance(ctx, walletID, models.WITHDRAW, 5000), ErrInsufficientFunds)
+	assert.ErrorIs(t, repo.UpdateWalletBalance(ctx, "00000000-0000-0000-0000-000000000000", models.DEPOSIT, 10), ErrWalletNotFound)
+
+	keyed := models.WalletOperation{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 50, IdempotencyKey: "key-1"}
+	_, _, err := repo.ProcessOperation(ctx, keyed)
+	require.NoError(t, err)
+	_, replayed, err := repo.ProcessOperation(ctx, keyed)
+	require.NoError(t, err)
+	require.True(t, replayed)
+
+	var out bytes.Buffer
+	require.NoError(t, m.Registry.Write(&out))
+	text := out.String()
+
+	assert.Contains(t, text, `wallet_operations_total{operation="DEPOSIT",outcome="success"} 2`)
+	assert.Contains(t, text, `wallet_operations_total{operation="WITHDRAW",outcome="success"} 1`)
+	assert.Contains(t, text, `wallet_operations_total{operation="WITHDRAW",outcome="insufficient_funds"} 1`)
+	assert.Contains(t, text, `wallet_operations_total{operation="DEPOSIT",outcome="not_found"} 1`)
+	assert.Contains(t, text, `wallet_operation_amount_total{operation="DEPOSIT"} 1050`)
+	assert.Contains(t, text, `wallet_operation_amount_total{operation="WITHDRAW"} 300`)
+}
+
+func testOutbox(t *testing.T, newStore newStoreFunc) {
+	ctx := context.Background()
+	repo := newStore(t)
+	outbox, ok := repo.(OutboxStore)
+	if !ok {
+		t.Skip("store has no outbox")
+	}
+
+	walletA := "123e4567-e89b-12d3-a456-426614174000"
+	walletB := "223e4567-e89b-12d3-a456-426614174000"
+	require.NoError(t, repo.CreateWallet(ctx, walletA))
+	require.NoError(t, repo.CreateWallet(ctx, walletB))
+
+	require.NoError(t, repo.UpdateWalletBalance(ctx, walletA, models.DEPOSIT, 100))
+	require.NoError(t, repo.UpdateWalletBalance(ctx, walletA, models.WITHDRAW, 30))
+	assert.ErrorIs(t, repo.UpdateWalletBalance(ctx, walletA, models.WITHDRAW, 1000), ErrInsufficientFunds)
+	transfer, err := repo.Transfer(ctx, walletA, walletB, 20)
+	require.NoError(t, err)
+	for range 2 {
+		_, _, err := repo.ProcessOperation(ctx, models.WalletOperation{WalletID: walletB, OperationType: models.DEPOSIT, Amount: 5, IdempotencyKey: "outbox-key"})
+		require.NoError(t, err)
+	}
+
+	var published []models.Event
+	collect := func(ctx context.Context, event models.Event) error {
+		published = append(published, event)
+		return nil
+	}
+
+	n, err := outbox.RelayOutbox(ctx, 2, collect)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	failing := errors.New("broker unavailable")
+	calls := 0
+	n, err = outbox.RelayOutbox(ctx, 10, func(ctx context.Context, event models.Event) error {
+		calls++
+		if calls == 2 {
+			return failing
+		}
+		return collect(ctx, event)
+	})
+	assert.ErrorIs(t, err, failing)
+	assert.Equal(t, int64(1), n)
+
+	n, err = outbox.RelayOutbox(ctx, 10, collect)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	n, err = outbox.RelayOutbox(ctx, 10, collect)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	expected := []struct {
+		walletID      string
+		operationType models.OperationType
+		amount        int64
+		before, after int64
+	}{
+		{walletA, models.DEPOSIT, 100, 0, 100},
+		{walletA, models.WITHDRAW, 30, 100, 70},
+		{walletA, models.TRANSFER, 20, 70, 50},
+		{walletB, models.TRANSFER, 20, 0, 20},
+		{walletB, models.DEPOSIT, 5, 20, 25},
+	}
+	require.Len(t, published, len(expected))
+	ids := make(map[string]bool)
+	for i, want := range expected {
+		event := published[i]
+		assert.Equal(t, models.EventBalanceChanged, event.Type)
+		assert.Equal(t, want.walletID, event.WalletID)
+		assert.NotEmpty(t, event.ID)
+		assert.False(t, ids[event.ID], "event ids are unique")
+		ids[event.ID] = true
+		if i > 0 {
+			assert.Greater(t, event.Sequence, published[i-1].Sequence)
+		}
+
+		var entry models.Transaction
+		require.NoError(t, json.Unmarshal(event.Payload, &entry))
+		assert.NotEmpty(t, entry.ID)
+		assert.Equal(t, want.walletID, entry.WalletID)
+		assert.Equal(t, want.operationType, entry.OperationType)
+		assert.Equal(t, want.amount, entry.Amount)
+		assert.Equal(t, int64Ptr(want.before), entry.BalanceBefore)
+		assert.Equal(t, int64Ptr(want.after), entry.BalanceAfter)
+		assert.Equal(t, want.after-want.before, entry.BalanceChange)
+		assert.False(t, entry.CreatedAt.IsZero())
+		if want.operationType == models.TRANSFER {
+			assert.Equal(t, transfer.TransferID, entry.TransferID)
+		} else {
+			assert.Empty(t, entry.TransferID)
+		}
+	}
+
+	deleted, err := outbox.DeletePublishedEvents(ctx, time.Hour)
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+
+	require.NoError(t, repo.UpdateWalletBalance(ctx, walletA, models.DEPOSIT, 1))
+	deleted, err = outbox.DeletePublishedEvents(ctx, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(expected)), deleted)
+
+	published = nil
+	n, err = outbox.RelayOutbox(ctx, 10, collect)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n, "unpublished events survive cleanup")
+}
+
+// runAPIKeyStoreSuite checks the behaviour every APIKeyStore implementation
+// must share.
+func runAPIKeyStoreSuite(t *testing.T, newStore newAPIKeyStoreFunc) {
+	ctx := context.Background()
+	store := newStore(t)
+
+	created, err := store.CreateAPIKey(ctx, models.APIKey{
+		Name:      "payments",
+		Prefix:    "wsk_0123abcd",
+		Scopes:    []string{"wallet:read", "wallet:withdraw"},
+		WalletIDs: []string{"123e4567-e89b-12d3-a456-426614174000"},
+	}, "hash-1")
+	require.NoError(t, err)
+	assert.NotEmpty(t, created.ID)
+	assert.False(t, created.CreatedAt.IsZero())
+	assert.False(t, created.Revoked())
+
+	_, err = store.CreateAPIKey(ctx, models.APIKey{Name: "dup", Prefix: "wsk_0123abcd", Scopes: []string{"admin"}}, "hash-1")
+	assert.ErrorIs(t, err, ErrConflict)
+
+	second, err := store.CreateAPIKey(ctx, models.APIKey{Name: "admin", Prefix: "wsk_4567efgh", Scopes: []string{"admin"}}, "hash-2")
+	require.NoError(t, err)
+	assert.Empty(t, second.WalletIDs)
+
+	found, err := store.GetAPIKeyByHash(ctx, "hash-1")
+	require.NoError(t, err)
+	assert.Equal(t, created.ID, found.ID)
+	assert.Equal(t, []string{"wallet:read", "wallet:withdraw"}, found.Scopes)
+	assert.Equal(t, []string{"123e4567-e89b-12d3-a456-426614174000"}, found.WalletIDs)
+
+	_, err = store.GetAPIKeyByHash(ctx, "missing")
+	assert.ErrorIs(t, err, ErrAPIKeyNotFound)
+
+	keys, err := store.ListAPIKeys(ctx)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.Equal(t, created.ID, keys[0].ID)
+	assert.Equal(t, second.ID, keys[1].ID)
+
+	revoked, err := store.RevokeAPIKey(ctx, created.ID)
+	require.NoError(t, err)
+	require.True(t, revoked.Revoked())
+
+	again, err := store.RevokeAPIKey(ctx, created.ID)
+	require.NoError(t, err)
+	assert.True(t, again.RevokedAt.Equal(*revoked.RevokedAt))
+
+	found, err = store.GetAPIKeyByHash(ctx, "hash-1")
+	require.NoError(t, err)
+	assert.True(t, found.Revoked())
+
+	_, err = store.RevokeAPIKey(ctx, "00000000-0000-0000-0000-000000000000")
+	assert.ErrorIs(t, err, ErrAPIKeyNotFound)
+	_, err = store.RevokeAPIKey(ctx, "not-a-uuid")
+	assert.ErrorIs(t, err, ErrAPIKeyNotFound)
+}
+
+// runWebhookStoreSuite checks the behaviour every WebhookStore implementation
+// must share.
+func runWebhookStoreSuite(t *testing.T, newStore newWebhookStoreFunc) {
+	ctx := context.Background()
+	store := newStore(t)
+
+	const (
+		walletA = "123e4567-e89b-12d3-a456-426614174000"
+		walletB = "223e4567-e89b-12d3-a456-426614174000"
+	)
+
+	all, err := store.CreateWebhook(ctx, models.WebhookSubscription{
+		URL:        "https://example.com/all",
+		EventTypes: []string{"wallet.credited"},
+		Active:     true,
+	}, "secret-all")
+	require.NoError(t, err)
+	assert.NotEmpty(t, all.ID)
+	assert.Empty(t, all.WalletIDs)
+
+	scoped, err := store.CreateWebhook(ctx, models.WebhookSubscription{
+		URL:        "https://example.com/b",
+		EventTypes: []string{"wallet.credited", "wallet.debited"},
+		WalletIDs:  []string{walletB},
+		Active:     true,
+	}, "secret-b")
+	require.NoError(t, err)
+
+	inactive, err := store.CreateWebhook(ctx, models.WebhookSubscription{
+		URL:        "https://example.com/off",
+		EventTypes: []string{"wallet.credited"},
+	}, "secret-off")
+	require.NoError(t, err)
+
+	found, err := store.GetWebhook(ctx, scoped.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{walletB}, found.WalletIDs)
+	assert.Equal(t, []string{"wallet.credited", "wallet.debited"}, found.EventTypes)
+
+	_, err = store.GetWebhook(ctx, "00000000-0000-0000-0000-000000000000")
+	assert.ErrorIs(t, err, ErrWebhookNotFound)
+	_, err = store.GetWebhook(ctx, "not-a-uuid")
+	assert.ErrorIs(t, err, ErrWebhookNotFound)
+
+	list, err := store.ListWebhooks(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 3)
+	assert.Equal(t, all.ID, list[0].ID)
+
+	enqueue := func(eventID, eventType, walletID string) int64 {
+		t.Helper()
+		n, err := store.EnqueueWebhookDeliveries(ctx, models.WebhookDelivery{
+			EventID:   eventID,
+			EventType: eventType,
+			WalletID:  walletID,
+			Payload:   json.RawMessage(`{"id":"` + eventID + `"}`),
+		})
+		require.NoError(t, err)
+		return n
+	}
+
+	assert.Equal(t, int64(1), enqueue("00000000-0000-0000-0000-000000000001", "wallet.credited", walletA))
+	assert.Equal(t, int64(0), enqueue("00000000-0000-0000-0000-000000000001", "wallet.credited", walletA), "duplicate events are ignored")
+	assert.Equal(t, int64(2), enqueue("00000000-0000-0000-0000-000000000002", "wallet.credited", walletB))
+	assert.Equal(t, int64(1), enqueue("00000000-0000-0000-0000-000000000003", "wallet.debited", walletB))
+	assert.Equal(t, int64(0), enqueue("00000000-0000-0000-0000-000000000004", "wallet.debited", walletA))
+
+	tasks, err := store.ClaimWebhookDeliveries(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, tasks, 4)
+	for _, task := range tasks {
+		assert.Equal(t, models.DeliveryPending, task.Status)
+		assert.Zero(t, task.Attempts)
+		switch task.WebhookID {
+		case all.ID:
+			assert.Equal(t, "https://example.com/all", task.URL)
+			assert.Equal(t, "secret-all", task.Secret)
+		case scoped.ID:
+			assert.Equal(t, "https://example.com/b", task.URL)
+			assert.Equal(t, "secret-b", task.Secret)
+		default:
+			t.Fatalf("delivery for unexpected webhook %s", task.WebhookID)
+		}
+		assert.JSONEq(t, `{"id":"`+task.EventID+`"}`, string(task.Payload))
+	}
+
+	leased, err := store.ClaimWebhookDeliveries(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, leased, "claimed deliveries are leased")
+
+	failed, succeeded, dead := tasks[0], tasks[1], tasks[2]
+	require.NoError(t, store.RecordWebhookAttempt(ctx, failed.ID, WebhookAttempt{
+		Status:        models.DeliveryPending,
+		StatusCode:    500,
+		Error:         "unexpected status 500",
+		NextAttemptAt: time.Now().Add(-time.Minute),
+	}))
+	require.NoError(t, store.RecordWebhookAttempt(ctx, succeeded.ID, WebhookAttempt{Status: models.DeliverySucceeded, StatusCode: 204}))
+	require.NoError(t, store.RecordWebhookAttempt(ctx, dead.ID, WebhookAttempt{Status: models.DeliveryDead, Error: "connection refused"}))
+	err = store.RecordWebhookAttempt(ctx, "00000000-0000-0000-0000-000000000000", WebhookAttempt{Status: models.DeliverySucceeded})
+	assert.ErrorIs(t, err, ErrDeliveryNotFound)
+
+	retried, err := store.ClaimWebhookDeliveries(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, retried, 1, "only the failed delivery is due again")
+	assert.Equal(t, failed.ID, retried[0].ID)
+	assert.Equal(t, 1, retried[0].Attempts)
+	assert.Equal(t, 500, retried[0].LastStatusCode)
+	assert.Equal(t, "unexpected status 500", retried[0].LastError)
+
+	deliveries, err := store.ListWebhookDeliveries(ctx, dead.WebhookID, models.WebhookDeliveryFilter{Status: models.DeliveryDead, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, dead.ID, deliveries[0].ID)
+	assert.Nil(t, deliveries[0].NextAttemptAt)
+	assert.NotNil(t, deliveries[0].LastAttemptAt)
+	assert.Equal(t, "connection refused", deliveries[0].LastError)
+
+	deliveries, err = store.ListWebhookDeliveries(ctx, scoped.ID, models.WebhookDeliveryFilter{Limit: 1})
+	require.NoError(t, err)
+	assert.Len(t, deliveries, 1)
+
+	deliveries, err = store.ListWebhookDeliveries(ctx, inactive.ID, models.WebhookDeliveryFilter{Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, deliveries)
+
+	_, err = store.ListWebhookDeliveries(ctx, "00000000-0000-0000-0000-000000000000", models.WebhookDeliveryFilter{Limit: 10})
+	assert.ErrorIs(t, err, ErrWebhookNotFound)
+
+	other := all.ID
+	if dead.WebhookID == all.ID {
+		other = scoped.ID
+	}
+	_, err = store.RedeliverWebhook(ctx, other, dead.ID)
+	assert.ErrorIs(t, err, ErrDeliveryNotFound, "delivery must belong to the webhook")
+
+	redelivered, err := store.RedeliverWebhook(ctx, dead.WebhookID, dead.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.DeliveryPending, redelivered.Status)
+	assert.Zero(t, redelivered.Attempts)
+
+	due, err := store.ClaimWebhookDeliveries(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	assert.Equal(t, dead.ID, due[0].ID)
+
+	updated, err := store.UpdateWebhook(ctx, models.WebhookSubscription{
+		ID:         scoped.ID,
+		URL:        "https://example.com/b2",
+		EventTypes: []string{"wallet.debited"},
+		WalletIDs:  []string{walletB},
+	}, "")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/b2", updated.URL)
+	assert.False(t, updated.Active)
+	assert.Equal(t, scoped.CreatedAt.Unix(), updated.CreatedAt.Unix())
+
+	assert.Equal(t, int64(0), enqueue("00000000-0000-0000-0000-000000000005", "wallet.debited", walletB), "inactive webhooks get no deliveries")
+
+	updated.Active = true
+	_, err = store.UpdateWebhook(ctx, *updated, "")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), enqueue("00000000-0000-0000-0000-000000000006", "wallet.debited", walletB))
+	due, err = store.ClaimWebhookDeliveries(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	assert.Equal(t, "secret-b", due[0].Secret, "an empty secret keeps the current one")
+
+	_, err = store.UpdateWebhook(ctx, models.WebhookSubscription{ID: "00000000-0000-0000-0000-000000000000", URL: "https://example.com", EventTypes: []string{"wallet.debited"}}, "")
+	assert.ErrorIs(t, err, ErrWebhookNotFound)
+
+	require.NoError(t, store.DeleteWebhook(ctx, scoped.ID))
+	_, err = store.GetWebhook(ctx, scoped.ID)
+	assert.ErrorIs(t, err, ErrWebhookNotFound)
+	_, err = store.ListWebhookDeliveries(ctx, scoped.ID, models.WebhookDeliveryFilter{Limit: 10})
+	assert.ErrorIs(t, err, ErrWebhookNotFound)
+	assert.ErrorIs(t, store.DeleteWebhook(ctx, scoped.ID), ErrWebhookNotFound)
+}
+
+// runExchangeStoreSuite checks the behaviour every ExchangeStore
+// implementation must share.
+func runExchangeStoreSuite(t *testing.T, newStore newExchangeStoreFunc) {
+	ctx := context.Background()
+	const (
+		eurWallet = "123e4567-e89b-12d3-a456-426614174000"
+		usdWallet = "223e4567-e89b-12d3-a456-426614174000"
+	)
+
+	fund := func(t *testing.T, repo exchangeTestStore) {
+		_, _, err := repo.ProcessOperation(ctx, models.WalletOperation{WalletID: eurWallet, OperationType: models.DEPOSIT, Amount: 1000, Currency: "EUR"})
+		require.NoError(t, err)
+		_, _, err = repo.ProcessOperation(ctx, models.WalletOperation{WalletID: usdWallet, OperationType: models.DEPOSIT, Amount: 100, Currency: "USD"})
+		require.NoError(t, err)
+	}
+	balance := func(t *testing.T, repo exchangeTestStore, walletID string) int64 {
+		wallet, err := repo.GetWallet(ctx, walletID)
+		require.NoError(t, err)
+		return wallet.Balance
+	}
+	exchange := models.Exchange{
+		FromWalletID: eurWallet,
+		ToWalletID:   usdWallet,
+		FromCurrency: "EUR",
+		ToCurrency:   "USD",
+		Debit:        500,
+		Credit:       542,
+		Rate:         "1.0845",
+	}
+
+	t.Run("rates", func(t *testing.T) {
+		repo := newStore(t)
+		_, err := repo.GetExchangeRate(ctx, "EUR", "USD")
+		assert.ErrorIs(t, err, ErrRateNotFound)
+
+		_, err = repo.SetExchangeRate(ctx, models.ExchangeRate{From: "USD", To: "JPY", Rate: "151.2"})
+		require.NoError(t, err)
+		_, err = repo.SetExchangeRate(ctx, models.ExchangeRate{From: "EUR", To: "USD", Rate: "1.08"})
+		require.NoError(t, err)
+		updated, err := repo.SetExchangeRate(ctx, models.ExchangeRate{From: "EUR", To: "USD", Rate: "1.0845"})
+		require.NoError(t, err)
+		assert.Equal(t, "1.0845", updated.Rate)
+		assert.False(t, updated.UpdatedAt.IsZero())
+
+		rate, err := repo.GetExchangeRate(ctx, "EUR", "USD")
+		require.NoError(t, err)
+		assert.Equal(t, "1.0845", rate.Rate)
+		_, err = repo.GetExchangeRate(ctx, "USD", "EUR")
+		assert.ErrorIs(t, err, ErrRateNotFound, "inverse rates are not derived")
+
+		rates, err := repo.ListExchangeRates(ctx)
+		require.NoError(t, err)
+		require.Len(t, rates, 2)
+		assert.Equal(t, "EUR", rates[0].From)
+		assert.Equal(t, "USD", rates[1].From)
+	})
+
+	t.Run("quotes", func(t *testing.T) {
+		repo := newStore(t)
+		quote, err := repo.CreateExchangeQuote(ctx, models.ExchangeQuote{FromCurrency: "EUR", ToCurrency: "USD", Rate: "1.0845"}, time.Minute)
+		require.NoError(t, err)
+		assert.NotEmpty(t, quote.ID)
+		assert.WithinDuration(t, time.Now().Add(time.Minute), quote.ExpiresAt, 10*time.Second)
+
+		found, err := repo.GetExchangeQuote(ctx, quote.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "1.0845", found.Rate)
+		assert.Equal(t, "EUR", found.FromCurrency)
+
+		_, err = repo.GetExchangeQuote(ctx, "00000000-0000-0000-0000-000000000000")
+		assert.ErrorIs(t, err, ErrQuoteNotFound)
+		_, err = repo.GetExchangeQuote(ctx, "not-a-uuid")
+		assert.ErrorIs(t, err, ErrQuoteNotFound)
+
+		expired, err := repo.CreateExchangeQuote(ctx, models.ExchangeQuote{FromCurrency: "EUR", ToCurrency: "USD", Rate: "1.0845"}, -time.Second)
+		require.NoError(t, err)
+		deleted, err := repo.DeleteExpiredExchangeQuotes(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), deleted)
+		_, err = repo.GetExchangeQuote(ctx, expired.ID)
+		assert.ErrorIs(t, err, ErrQuoteNotFound)
+		_, err = repo.GetExchangeQuote(ctx, quote.ID)
+		assert.NoError(t, err)
+	})
+
+	t.Run("exchange", func(t *testing.T) {
+		repo := newStore(t)
+		fund(t, repo)
+
+		result, err := repo.Exchange(ctx, exchange)
+		require.NoError(t, err)
+		assert.NotEmpty(t, result.ExchangeID)
+		assert.Equal(t, int64(500), result.FromBalance)
+		assert.Equal(t, int64(642), result.ToBalance)
+		assert.Equal(t, int64(500), result.DebitedAmount)
+		assert.Equal(t, int64(542), result.CreditedAmount)
+		assert.Equal(t, int64(500), balance(t, repo, eurWallet))
+		assert.Equal(t, int64(642), balance(t, repo, usdWallet))
+
+		for walletID, amount := range map[string]int64{eurWallet: 500, usdWallet: 542} {
+			transactions, _, err := repo.ListTransactions(ctx, walletID, models.TransactionFilter{OperationType: models.EXCHANGE, Limit: 10})
+			require.NoError(t, err)
+			require.Len(t, transactions, 1)
+			assert.Equal(t, amount, transactions[0].Amount)
+			assert.Equal(t, result.ExchangeID, transactions[0].ExchangeID)
+		}
+	})
+
+	t.Run("quote is used once", func(t *testing.T) {
+		repo := newStore(t)
+		fund(t, repo)
+		quote, err := repo.CreateExchangeQuote(ctx, models.ExchangeQuote{FromCurrency: "EUR", ToCurrency: "USD", Rate: "1.0845"}, time.Minute)
+		require.NoError(t, err)
+
+		withQuote := exchange
+		withQuote.QuoteID = quote.ID
+		withQuote.Debit = 5000
+		_, err = repo.Exchange(ctx, withQuote)
+		assert.ErrorIs(t, err, ErrInsufficientFunds)
+
+		withQuote.Debit = 500
+		result, err := repo.Exchange(ctx, withQuote)
+		require.NoError(t, err, "a failed exchange leaves the quote unused")
+		assert.Equal(t, quote.ID, result.QuoteID)
+
+		_, err = repo.Exchange(ctx, withQuote)
+		assert.ErrorIs(t, err, ErrQuoteExpired)
+		assert.Equal(t, int64(500), balance(t, repo, eurWallet))
+	})
+
+	t.Run("expired quote", func(t *testing.T) {
+		repo := newStore(t)
+		fund(t, repo)
+		quote, err := repo.CreateExchangeQuote(ctx, models.ExchangeQuote{FromCurrency: "EUR", ToCurrency: "USD", Rate: "1.0845"}, -time.Second)
+		require.NoError(t, err)
+
+		withQuote := exchange
+		withQuote.QuoteID = quote.ID
+		_, err = repo.Exchange(ctx, withQuote)
+		assert.ErrorIs(t, err, ErrQuoteExpired)
+
+		withQuote.QuoteID = "00000000-0000-0000-0000-000000000000"
+		_, err = repo.Exchange(ctx, withQuote)
+		assert.ErrorIs(t, err, ErrQuoteNotFound)
+		assert.Equal(t, int64(1000), balance(t, repo, eurWallet))
+	})
+
+	t.Run("rejected exchanges", func(t *testing.T) {
+		repo := newStore(t)
+		fund(t, repo)
+
+		tests := []struct {
+			name    string
+			modify  func(e *models.Exchange)
+			wantErr error
+		}{
+			{"same wallet", func(e *models.Exchange) { e.ToWalletID = e.FromWalletID }, ErrInvalidOperation},
+			{"zero credit", func(e *models.Exchange) { e.Credit = 0 }, ErrInvalidOperation},
+			{"missing wallet", func(e *models.Exchange) { e.ToWalletID = "323e4567-e89b-12d3-a456-426614174000" }, ErrWalletNotFound},
+			{"source currency changed", func(e *models.Exchange) { e.FromCurrency = "GBP" }, ErrCurrencyMismatch},
+			{"destination currency changed", func(e *models.Exchange) { e.ToCurrency = "JPY" }, ErrCurrencyMismatch},
+			{"insufficient funds", func(e *models.Exchange) { e.Debit = 1001 }, ErrInsufficientFunds},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				rejected := exchange
+				tt.modify(&rejected)
+				_, err := repo.Exchange(ctx, rejected)
+				assert.ErrorIs(t, err, tt.wantErr)
+			})
+		}
+
+		assert.Equal(t, int64(1000), balance(t, repo, eurWallet))
+		assert.Equal(t, int64(100), balance(t, repo, usdWallet))
+	})
+}
+
+// runLedgerStoreSuite checks that every operation posts a balanced journal
+// and that the wallet accounts add up to the wallet balances.
+func runLedgerStoreSuite(t *testing.T, newStore newLedgerStoreFunc) {
+	ctx := context.Background()
+	const (
+		walletA   = "123e4567-e89b-12d3-a456-426614174000"
+		walletB   = "223e4567-e89b-12d3-a456-426614174000"
+		usdWallet = "323e4567-e89b-12d3-a456-426614174000"
+	)
+
+	repo := newStore(t)
+
+	balance, err := repo.TrialBalance(ctx)
+	require.NoError(t, err)
+	assert.True(t, balance.Balanced)
+	assert.Empty(t, balance.Accounts)
+
+	_, _, err = repo.ProcessOperation(ctx, models.WalletOperation{WalletID: walletA, OperationType: models.DEPOSIT, Amount: 1000, Currency: "EUR"})
+	require.NoError(t, err)
+	_, err = repo.ApplyBatch(ctx, models.BatchAtomic, []models.WalletOperation{
+		{WalletID: walletB, OperationType: models.DEPOSIT, Amount: 100, Currency: "EUR"},
+		{WalletID: usdWallet, OperationType: models.DEPOSIT, Amount: 50, Currency: "USD"},
+	})
+	require.NoError(t, err)
+	_, _, err = repo.ProcessOperation(ctx, models.WalletOperation{WalletID: walletA, OperationType: models.WITHDRAW, Amount: 300, Currency: "EUR"})
+	require.NoError(t, err)
+	transfer, err := repo.Transfer(ctx, walletA, walletB, 200)
+	require.NoError(t, err)
+	exchange, err := repo.Exchange(ctx, models.Exchange{
+		FromWalletID: walletA,
+		ToWalletID:   usdWallet,
+		FromCurrency: "EUR",
+		ToCurrency:   "USD",
+		Debit:        100,
+		Credit:       108,
+		Rate:         "1.08",
+	})
+	require.NoError(t, err)
+	hold, err := repo.CreateHold(ctx, walletB, 100, time.Minute)
+	require.NoError(t, err)
+	_, err = repo.CaptureHold(ctx, walletB, hold.ID, 50)
+	require.NoError(t, err)
+
+	_, err = repo.ApplyBatch(ctx, models.BatchAtomic, []models.WalletOperation{
+		{WalletID: walletA, OperationType: models.DEPOSIT, Amount: 1, Currency: "EUR"},
+		{WalletID: walletB, OperationType: models.WITHDRAW, Amount: 1 << 40, Currency: "EUR"},
+	})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+
+	t.Run("trial balance", func(t *testing.T) {
+		balance, err := repo.TrialBalance(ctx)
+		require.NoError(t, err)
+		assert.True(t, balance.Balanced)
+		assert.Equal(t, []models.AccountBalance{
+			{Account: models.AccountCashIn, Currency: "EUR", Debit: 1100, Balance: -1100},
+			{Account: models.AccountCashOut, Currency: "EUR", Credit: 350, Balance: 350},
+			{Account: models.AccountFX, Currency: "EUR", Credit: 100, Balance: 100},
+			{Account: models.AccountWallets, Currency: "EUR", Debit: 650, Credit: 1300, Balance: 650},
+			{Account: models.AccountCashIn, Currency: "USD", Debit: 50, Balance: -50},
+			{Account: models.AccountFX, Currency: "USD", Debit: 108, Balance: -108},
+			{Account: models.AccountWallets, Currency: "USD", Credit: 158, Balance: 158},
+		}, balance.Accounts)
+		assert.Equal(t, []models.CurrencyTotal{
+			{Currency: "EUR", Debit: 1750, Credit: 1750},
+			{Currency: "USD", Debit: 158, Credit: 158},
+		}, balance.Totals)
+
+		var eurWallets int64
+		for _, walletID := range []string{walletA, walletB} {
+			wallet, err := repo.GetWallet(ctx, walletID)
+			require.NoError(t, err)
+			eurWallets += wallet.Balance
+		}
+		assert.Equal(t, int64(650), eurWallets, "wallet accounts add up to the balances")
+	})
+
+	t.Run("journals", func(t *testing.T) {
+		type posting struct {
+			account       string
+			currency      string
+			debit, credit int64
+		}
+		journal := func(t *testing.T, journalID string) []posting {
+			entries, err := repo.GetJournal(ctx, journalID)
+			require.NoError(t, err)
+			postings := make([]posting, len(entries))
+			for i, entry := range entries {
+				assert.Equal(t, journalID, entry.JournalID)
+				postings[i] = posting{entry.Account, entry.Currency, entry.Debit, entry.Credit}
+			}
+			return postings
+		}
+
+		assert.ElementsMatch(t, []posting{
+			{models.WalletAccount(walletA), "EUR", 200, 0},
+			{models.WalletAccount(walletB), "EUR", 0, 200},
+		}, journal(t, transfer.TransferID))
+
+		assert.ElementsMatch(t, []posting{
+			{models.WalletAccount(walletA), "EUR", 100, 0},
+			{models.AccountFX, "EUR", 0, 100},
+			{models.AccountFX, "USD", 108, 0},
+			{models.WalletAccount(usdWallet), "USD", 0, 108},
+		}, journal(t, exchange.ExchangeID))
+
+		transactions, _, err := repo.ListTransactions(ctx, walletB, models.TransactionFilter{OperationType: models.WITHDRAW, Limit: 1})
+		require.NoError(t, err)
+		require.Len(t, transactions, 1)
+		assert.ElementsMatch(t, []posting{
+			{models.WalletAccount(walletB), "EUR", 50, 0},
+			{models.AccountCashOut, "EUR", 0, 50},
+		}, journal(t, transactions[0].ID))
+
+		_, err = repo.GetJournal(ctx, "00000000-0000-0000-0000-000000000000")
+		assert.ErrorIs(t, err, ErrJournalNotFound)
+		_, err = repo.GetJournal(ctx, "not-a-uuid")
+		assert.ErrorIs(t, err, ErrJournalNotFound)
+	})
+}
+
+// runReconciliationStoreSuite checks that drift between balances and the
+// ledger is found and that corrections make the ledger add up again.
+func runReconciliationStoreSuite(t *testing.T, newStore newReconciliationStoreFunc) {
+	ctx := context.Background()
+	const (
+		walletA = "123e4567-e89b-12d3-a456-426614174000"
+		walletB = "223e4567-e89b-12d3-a456-426614174000"
+		walletC = "323e4567-e89b-12d3-a456-426614174000"
+	)
+
+	repo, setBalance := newStore(t)
+
+	_, err := repo.LastReconciliationReport(ctx)
+	assert.ErrorIs(t, err, ErrNoReconciliation)
+
+	for _, walletID := range []string{walletA, walletB} {
+		_, _, err := repo.ProcessOperation(ctx, models.WalletOperation{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 1000, Currency: "USD"})
+		require.NoError(t, err)
+	}
+	_, err = repo.Transfer(ctx, walletA, walletB, 300)
+	require.NoError(t, err)
+	require.NoError(t, repo.CreateWallet(ctx, walletC))
+
+	checked, drifts, err := repo.FindBalanceDrift(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), checked)
+	assert.Empty(t, drifts)
+
+	setBalance(walletA, 750)
+	setBalance(walletC, -20)
+
+	checked, drifts, err = repo.FindBalanceDrift(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), checked)
+	assert.Equal(t, []models.BalanceDrift{
+		{WalletID: walletA, Expected: 700, Actual: 750, Difference: 50},
+		{WalletID: walletC, Expected: 0, Actual: -20, Difference: -20},
+	}, drifts)
+
+	for _, drift := range drifts {
+		corrected, err := repo.CorrectBalanceDrift(ctx, drift.WalletID)
+		require.NoError(t, err)
+		require.NotNil(t, corrected)
+		assert.True(t, corrected.Corrected)
+		assert.Equal(t, drift.Difference, corrected.Difference)
+
+		wallet, err := repo.GetWallet(ctx, drift.WalletID)
+		require.NoError(t, err)
+		assert.Equal(t, drift.Actual, wallet.Balance, "the adjustment leaves the balance as it is")
+	}
+
+	corrected, err := repo.CorrectBalanceDrift(ctx, walletA)
+	require.NoError(t, err)
+	assert.Nil(t, corrected, "nothing left to correct")
+	_, err = repo.CorrectBalanceDrift(ctx, "423e4567-e89b-12d3-a456-426614174000")
+	assert.ErrorIs(t, err, ErrWalletNotFound)
+
+	_, drifts, err = repo.FindBalanceDrift(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, drifts)
+
+	transactions, _, err := repo.ListTransactions(ctx, walletA, models.TransactionFilter{OperationType: models.ADJUSTMENT, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, transactions, 1)
+	assert.Equal(t, int64(50), transactions[0].Amount)
+	assert.Equal(t, int64Ptr(700), transactions[0].BalanceBefore)
+	assert.Equal(t, int64Ptr(750), transactions[0].BalanceAfter)
+
+	limits, err := repo.GetWalletLimits(ctx, walletC)
+	require.NoError(t, err)
+	assert.Zero(t, limits.DailyUsed, "an adjustment that lowers the balance is not a withdrawal")
+	assert.Zero(t, limits.MonthlyUsed)
+
+	balance, err := repo.TrialBalance(ctx)
+	require.NoError(t, err)
+	assert.True(t, balance.Balanced)
+	assert.Contains(t, balance.Accounts, models.AccountBalance{Account: models.AccountAdjustments, Currency: "USD", Debit: 50, Credit: 20, Balance: -30})
+
+	started := time.Now().UTC().Truncate(time.Millisecond)
+	for i, walletsChecked := range []int64{3, 4} {
+		_, err := repo.SaveReconciliationReport(ctx, models.ReconciliationReport{
+			StartedAt:      started.Add(time.Duration(i) * time.Second),
+			FinishedAt:     started.Add(time.Duration(i)*time.Second + time.Millisecond),
+			AutoCorrect:    true,
+			WalletsChecked: walletsChecked,
+			Drifts:         []models.BalanceDrift{{WalletID: walletA, Expected: 700, Actual: 750, Difference: 50, Corrected: true}},
+		})
+		require.NoError(t, err)
+	}
+
+	last, err := repo.LastReconciliationReport(ctx)
+	require.NoError(t, err)
+	assert.NotEmpty(t, last.ID)
+	assert.Equal(t, int64(4), last.WalletsChecked)
+	assert.True(t, last.AutoCorrect)
+	assert.Equal(t, []models.BalanceDrift{{WalletID: walletA, Expected: 700, Actual: 750, Difference: 50, Corrected: true}}, last.Drifts)
+	assert.True(t, last.StartedAt.Equal(started.Add(time.Second)))
+
+	t.Run("capture after an adjustment below the hold", func(t *testing.T) {
+		repo, setBalance := newStore(t)
+		_, _, err := repo.ProcessOperation(ctx, models.WalletOperation{WalletID: walletA, OperationType: models.DEPOSIT, Amount: 1000, Currency: "USD"})
+		require.NoError(t, err)
+		hold, err := repo.CreateHold(ctx, walletA, 600, time.Hour)
+		require.NoError(t, err)
+
+		setBalance(walletA, 400)
+		_, err = repo.CorrectBalanceDrift(ctx, walletA)
+		require.NoError(t, err)
+
+		_, err = repo.CaptureHold(ctx, walletA, hold.ID, 0)
+		assert.ErrorIs(t, err, ErrInsufficientFunds, "a capture does not take the balance below zero")
+		_, err = repo.CaptureHold(ctx, walletA, hold.ID, 400)
+		require.NoError(t, err)
+
+		wallet, err := repo.GetWallet(ctx, walletA)
+		require.NoError(t, err)
+		assert.Zero(t, wallet.Balance)
+	})
+}
+
+// runShardStoreSuite checks that a sharded wallet keeps its balance exact
+// through concurrent operations, operations that lock the whole wallet and
+// compaction, and that its ledger still adds up to it.
+func runShardStoreSuite(t *testing.T, newStore newShardStoreFunc) {
+	ctx := context.Background()
+	const (
+		walletID = "123e4567-e89b-12d3-a456-426614174000"
+		otherID  = "223e4567-e89b-12d3-a456-426614174000"
+	)
+
+	repo := newStore(t)
+
+	_, err := repo.SetWalletShards(ctx, walletID, 4)
+	assert.ErrorIs(t, err, ErrWalletNotFound)
+
+	_, _, err = repo.ProcessOperation(ctx, models.WalletOperation{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 1000})
+	require.NoError(t, err)
+
+	for _, shards := range []int{-1, MaxWalletShards + 1} {
+		_, err := repo.SetWalletShards(ctx, walletID, shards)
+		assert.ErrorIs(t, err, ErrInvalidOperation)
+	}
+
+	wallet, err := repo.SetWalletShards(ctx, walletID, 4)
+	require.NoError(t, err)
+	assert.Equal(t, 4, wallet.Shards)
+	assert.Equal(t, int64(1000), wallet.Balance)
+
+	balance, _, err := repo.ProcessOperation(ctx, models.WalletOperation{WalletID: walletID, OperationType: models.WITHDRAW, Amount: 600})
+	require.NoError(t, err, "a withdrawal can take from several shards")
+	assert.Equal(t, int64(400), balance)
+
+	_, _, err = repo.ProcessOperation(ctx, models.WalletOperation{WalletID: walletID, OperationType: models.WITHDRAW, Amount: 401})
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
+
+	var wg sync.WaitGroup
+	results := make(chan error, 200)
+	for i := 0; i < 100; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, _, err := repo.ProcessOperation(ctx, models.WalletOperation{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 3})
+			results <- err
+		}()
+		go func() {
+			defer wg.Done()
+			_, _, err := repo.ProcessOperation(ctx, models.WalletOperation{WalletID: walletID, OperationType: models.WITHDRAW, Amount: 5})
+			results <- err
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	var failed int64
+	for err := range results {
+		if err != nil {
+			require.ErrorIs(t, err, ErrInsufficientFunds)
+			failed++
+		}
+	}
+	wallet, err = repo.GetWallet(ctx, walletID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(400+300-5*(100-failed)), wallet.Balance)
+	assert.Equal(t, 4, wallet.Shards)
+	expected := wallet.Balance
+
+	hold, err := repo.CreateHold(ctx, walletID, 100, time.Hour)
+	require.NoError(t, err, "operations that lock the wallet see the whole balance")
+	_, _, err = repo.ProcessOperation(ctx, models.WalletOperation{WalletID: walletID, OperationType: models.WITHDRAW, Amount: expected - 99})
+	assert.ErrorIs(t, err, ErrInsufficientFunds, "the hold applies to a sharded wallet")
+	_, err = repo.VoidHold(ctx, walletID, hold.ID)
+	require.NoError(t, err)
+
+	_, err = repo.Transfer(ctx, walletID, otherID, 50)
+	assert.ErrorIs(t, err, ErrWalletNotFound)
+	require.NoError(t, repo.CreateWallet(ctx, otherID))
+	_, err = repo.Transfer(ctx, walletID, otherID, 50)
+	require.NoError(t, err)
+	expected -= 50
+
+	_, _, err = repo.ProcessOperation(ctx, models.WalletOperation{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 25})
+	require.NoError(t, err)
+	expected += 25
+
+	compacted, err := repo.CompactWalletShards(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), compacted)
+
+	wallet, err = repo.GetWallet(ctx, walletID)
+	require.NoError(t, err)
+	assert.Equal(t, expected, wallet.Balance, "compaction keeps the balance")
+
+	balance, _, err = repo.ProcessOperation(ctx, models.WalletOperation{WalletID: walletID, OperationType: models.WITHDRAW, Amount: expected})
+	require.NoError(t, err, "the whole balance can be withdrawn after compaction")
+	assert.Zero(t, balance)
+	_, _, err = repo.ProcessOperation(ctx, models.WalletOperation{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 70})
+	require.NoError(t, err)
+
+	wallet, err = repo.SetWalletShards(ctx, walletID, 0)
+	require.NoError(t, err)
+	assert.Zero(t, wallet.Shards)
+	assert.Equal(t, int64(70), wallet.Balance)
+
+	compacted, err = repo.CompactWalletShards(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, compacted)
+
+	_, drifts, err := repo.FindBalanceDrift(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, drifts, "the ledger adds up to the sharded balance")
+
+	trial, err := repo.TrialBalance(ctx)
+	require.NoError(t, err)
+	assert.True(t, trial.Balanced)
+}
@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/NKV510/wallet-service/internal/models"
+	"github.com/NKV510/wallet-service/internal/tracing"
+)
+
+// TracedStore wraps a WalletStore and records a span around every call.
+// Together with tracing.QueryTracer on the pool, each span's children show
+// the SQL statements it ran, including the row lock waits and the commit.
+type TracedStore struct {
+	store  WalletStore
+	tracer *tracing.Tracer
+}
+
+func NewTracedStore(store WalletStore, tracer *tracing.Tracer) *TracedStore {
+	return &TracedStore{store: store, tracer: tracer}
+}
+
+var _ WalletStore = (*TracedStore)(nil)
+
+func (s *TracedStore) start(ctx context.Context, method string, attrs ...tracing.Attribute) (context.Context, *tracing.Span) {
+	return s.tracer.Start(ctx, "WalletStore."+method, attrs...)
+}
+
+func (s *TracedStore) CreateWallet(ctx context.Context, walletID string) error {
+	ctx, span := s.start(ctx, "CreateWallet", tracing.String("wallet.id", walletID))
+	defer span.End()
+
+	err := s.store.CreateWallet(ctx, walletID)
+	span.RecordError(err)
+	return err
+}
+
+func (s *TracedStore) GetWallet(ctx context.Context, walletID string) (*models.Wallet, error) {
+	ctx, span := s.start(ctx, "GetWallet", tracing.String("wallet.id", walletID))
+	defer span.End()
+
+	wallet, err := s.store.GetWallet(ctx, walletID)
+	span.RecordError(err)
+	return wallet, err
+}
+
+func (s *TracedStore) UpdateWalletBalance(ctx context.Context, walletID string, operationType models.OperationType, amount int64) error {
+	ctx, span := s.start(ctx, "UpdateWalletBalance",
+		tracing.String("wallet.id", walletID),
+		tracing.String("wallet.operation", string(operationType)),
+		tracing.Int64("wallet.amount", amount),
+	)
+	defer span.End()
+
+	err := s.store.UpdateWalletBalance(ctx, walletID, operationType, amount)
+	span.RecordError(err)
+	return err
+}
+
+func (s *TracedStore) ProcessOperation(ctx context.Context, operation models.WalletOperation) (int64, bool, error) {
+	ctx, span := s.start(ctx, "ProcessOperation",
+		tracing.String("wallet.id", operation.WalletID),
+		tracing.String("wallet.operation", string(operation.OperationType)),
+		tracing.Int64("wallet.amount", operation.Amount),
+		tracing.String("wallet.currency", operation.Currency),
+	)
+	defer span.End()
+
+	balance, replayed, err := s.store.ProcessOperation(ctx, operation)
+	if replayed {
+		span.SetAttributes(tracing.String("idempotency.replayed", "true"))
+	}
+	span.RecordError(err)
+	return balance, replayed, err
+}
+
+func (s *TracedStore) Transfer(ctx context.Context, fromWalletID, toWalletID string, amount int64) (*models.TransferResponse, error) {
+	ctx, span := s.start(ctx, "Transfer",
+		tracing.String("transfer.from", fromWalletID),
+		tracing.String("transfer.to", toWalletID),
+		tracing.Int64("wallet.amount", amount),
+	)
+	defer span.End()
+
+	result, err := s.store.Transfer(ctx, fromWalletID, toWalletID, amount)
+	span.RecordError(err)
+	return result, err
+}
+
+func (s *TracedStore) ApplyBatch(ctx context.Context, mode models.BatchMode, operations []models.WalletOperation) ([]models.BatchItemResult, error) {
+	ctx, span := s.start(ctx, "ApplyBatch",
+		tracing.String("batch.mode", string(mode)),
+		tracing.Int64("batch.size", int64(len(operations))),
+	)
+	defer span.End()
+
+	results, err := s.store.ApplyBatch(ctx, mode, operations)
+	span.RecordError(err)
+	return results, err
+}
+
+func (s *TracedStore) ListTransactions(ctx context.Context, walletID string, filter models.TransactionFilter) ([]models.Transaction, string, error) {
+	ctx, span := s.start(ctx, "ListTransactions", tracing.String("wallet.id", walletID))
+	defer span.End()
+
+	transactions, nextCursor, err := s.store.ListTransactions(ctx, walletID, filter)
+	span.RecordError(err)
+	return transactions, nextCursor, err
+}
+
+func (s *TracedStore) DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	ctx, span := s.start(ctx, "DeleteExpiredIdempotencyKeys")
+	defer span.End()
+
+	n, err := s.store.DeleteExpiredIdempotencyKeys(ctx)
+	span.RecordError(err)
+	return n, err
+}
+
+func (s *TracedStore) SetWalletStatus(ctx context.Context, walletID string, status models.WalletStatus, reason string) (*models.Wallet, error) {
+	ctx, span := s.start(ctx, "SetWalletStatus",
+		tracing.String("wallet.id", walletID),
+		tracing.String("wallet.status", string(status)),
+	)
+	defer span.End()
+
+	wallet, err := s.store.SetWalletStatus(ctx, walletID, status, reason)
+	span.RecordError(err)
+	return wallet, err
+}
+
+func (s *TracedStore) GetWalletLimits(ctx context.Context, walletID string) (*models.WalletLimitsResponse, error) {
+	ctx, span := s.start(ctx, "GetWalletLimits", tracing.String("wallet.id", walletID))
+	defer span.End()
+
+	limits, err := s.store.GetWalletLimits(ctx, walletID)
+	span.RecordError(err)
+	return limits, err
+}
+
+func (s *TracedStore) SetWalletLimits(ctx context.Context, walletID string, override models.WithdrawalLimitsOverride) (*models.WalletLimitsResponse, error) {
+	ctx, span := s.start(ctx, "SetWalletLimits", tracing.String("wallet.id", walletID))
+	defer span.End()
+
+	limits, err := s.store.SetWalletLimits(ctx, walletID, override)
+	span.RecordError(err)
+	return limits, err
+}
+
+func (s *TracedStore) CreateHold(ctx context.Context, walletID string, amount int64, ttl time.Duration) (*models.Hold, error) {
+	ctx, span := s.start(ctx, "CreateHold",
+		tracing.String("wallet.id", walletID),
+		tracing.Int64("wallet.amount", amount),
+	)
+	defer span.End()
+
+	hold, err := s.store.CreateHold(ctx, walletID, amount, ttl)
+	span.RecordError(err)
+	return hold, err
+}
+
+func (s *TracedStore) CaptureHold(ctx context.Context, walletID, holdID string, amount int64) (*models.Hold, error) {
+	ctx, span := s.start(ctx, "CaptureHold",
+		tracing.String("wallet.id", walletID),
+		tracing.String("hold.id", holdID),
+		tracing.Int64("wallet.amount", amount),
+	)
+	defer span.End()
+
+	hold, err := s.store.CaptureHold(ctx, walletID, holdID, amount)
+	span.RecordError(err)
+	return hold, err
+}
+
+func (s *TracedStore) VoidHold(ctx context.Context, walletID, holdID string) (*models.Hold, error) {
+	ctx, span := s.start(ctx, "VoidHold",
+		tracing.String("wallet.id", walletID),
+		tracing.String("hold.id", holdID),
+	)
+	defer span.End()
+
+	hold, err := s.store.VoidHold(ctx, walletID, holdID)
+	span.RecordError(err)
+	return hold, err
+}
+
+func (s *TracedStore) ExpireHolds(ctx context.Context) (int64, error) {
+	ctx, span := s.start(ctx, "ExpireHolds")
+	defer span.End()
+
+	n, err := s.store.ExpireHolds(ctx)
+	span.RecordError(err)
+	return n, err
+}
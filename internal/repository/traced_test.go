@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/NKV510/wallet-service/internal/models"
+	"github.com/NKV510/wallet-service/internal/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracedStore(t *testing.T) {
+	runWalletStoreSuite(t, func(t *testing.T, opts ...Option) WalletStore {
+		return NewTracedStore(NewMemoryWalletRepository(opts...), tracing.NewTracer(tracing.NewMemoryExporter()))
+	})
+}
+
+func TestTracedStore_Spans(t *testing.T) {
+	ctx := context.Background()
+	walletID := "123e4567-e89b-12d3-a456-426614174000"
+
+	exporter := tracing.NewMemoryExporter()
+	tracer := tracing.NewTracer(exporter)
+	store := NewTracedStore(NewMemoryWalletRepository(), tracer)
+
+	ctx, request := tracer.Start(ctx, "request")
+	require.NoError(t, store.CreateWallet(ctx, walletID))
+	err := store.UpdateWalletBalance(ctx, walletID, models.WITHDRAW, 100)
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+	request.End()
+
+	spans := exporter.Spans()
+	require.Len(t, spans, 3)
+
+	assert.Equal(t, "WalletStore.CreateWallet", spans[0].Name)
+	assert.Equal(t, tracing.StatusOK, spans[0].Status)
+
+	assert.Equal(t, "WalletStore.UpdateWalletBalance", spans[1].Name)
+	assert.Equal(t, tracing.StatusError, spans[1].Status)
+	assert.Equal(t, ErrInsufficientFunds.Error(), spans[1].Error)
+	assert.Contains(t, spans[1].Attributes, tracing.String("wallet.operation", "WITHDRAW"))
+
+	for _, span := range spans[:2] {
+		assert.Equal(t, spans[2].SpanID, span.ParentSpanID)
+	}
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/NKV510/wallet-service/internal/models"
+)
+
+// WebhookDeliveryTask is a claimed delivery together with where to send it and
+// the secret to sign it with.
+type WebhookDeliveryTask struct {
+	models.WebhookDelivery
+	URL    string
+	Secret string
+}
+
+// WebhookAttempt is the outcome of sending a delivery once. Status is the
+// delivery's new status; NextAttemptAt is only used when it stays PENDING.
+type WebhookAttempt struct {
+	Status        models.WebhookDeliveryStatus
+	StatusCode    int
+	Error         string
+	NextAttemptAt time.Time
+}
+
+// WebhookStore keeps webhook subscriptions and the log of their deliveries.
+type WebhookStore interface {
+	CreateWebhook(ctx context.Context, webhook models.WebhookSubscription, secret string) (*models.WebhookSubscription, error)
+	GetWebhook(ctx context.Context, id string) (*models.WebhookSubscription, error)
+	ListWebhooks(ctx context.Context) ([]models.WebhookSubscription, error)
+	// UpdateWebhook replaces the subscription's settings. An empty secret
+	// keeps the current one.
+	UpdateWebhook(ctx context.Context, webhook models.WebhookSubscription, secret string) (*models.WebhookSubscription, error)
+	DeleteWebhook(ctx context.Context, id string) error
+
+	// EnqueueWebhookDeliveries creates a pending copy of delivery for every
+	// active subscription matching its event type and wallet. Enqueueing the
+	// same event twice creates nothing new.
+	EnqueueWebhookDeliveries(ctx context.Context, delivery models.WebhookDelivery) (int64, error)
+	// ClaimWebhookDeliveries returns up to limit due deliveries and hides them
+	// from other callers for lease, long enough to send them.
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]WebhookDeliveryTask, error)
+	RecordWebhookAttempt(ctx context.Context, deliveryID string, attempt WebhookAttempt) error
+	// ListWebhookDeliveries returns the subscription's deliveries, newest
+	// first.
+	ListWebhookDeliveries(ctx context.Context, webhookID string, filter models.WebhookDeliveryFilter) ([]models.WebhookDelivery, error)
+	// RedeliverWebhook schedules the delivery to be sent again now, with a
+	// fresh set of attempts, whatever its status.
+	RedeliverWebhook(ctx context.Context, webhookID, deliveryID string) (*models.WebhookDelivery, error)
+}
+
+var (
+	_ WebhookStore = (*WalletRepository)(nil)
+	_ WebhookStore = (*MemoryWalletRepository)(nil)
+)
@@ -0,0 +1,73 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Middleware starts a span for every request, continuing the caller's trace
+// when the request carries a valid traceparent header, and returns the span's
+// traceparent in the response.
+func Middleware(tracer *Tracer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		if sc, ok := ParseTraceparent(c.GetHeader(TraceparentHeader)); ok {
+			ctx = ContextWithRemoteSpanContext(ctx, sc)
+		}
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		ctx, span := tracer.Start(ctx, c.Request.Method+" "+route,
+			String("http.method", c.Request.Method),
+			String("http.route", route),
+		)
+		defer span.End()
+
+		if sc := span.SpanContext(); sc.IsValid() {
+			c.Header(TraceparentHeader, sc.Traceparent())
+		}
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		span.SetAttributes(Int64("http.status_code", int64(c.Writer.Status())))
+		if len(c.Errors) > 0 {
+			span.RecordError(c.Errors.Last())
+		}
+	}
+}
+
+// QueryTracer is a pgx.QueryTracer that records a span for each SQL
+// statement, including BEGIN and COMMIT.
+type QueryTracer struct {
+	tracer *Tracer
+}
+
+func NewQueryTracer(tracer *Tracer) *QueryTracer {
+	return &QueryTracer{tracer: tracer}
+}
+
+type querySpanKey struct{}
+
+func (t *QueryTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	ctx, span := t.tracer.Start(ctx, "db.query", String("db.statement", strings.Join(strings.Fields(data.SQL), " ")))
+	if span == nil {
+		return ctx
+	}
+	// The query span is kept under its own key so that TraceQueryEnd never
+	// ends the caller's span when tracing is disabled.
+	return context.WithValue(ctx, querySpanKey{}, span)
+}
+
+func (t *QueryTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	span, _ := ctx.Value(querySpanKey{}).(*Span)
+	span.SetAttributes(Int64("db.rows_affected", data.CommandTag.RowsAffected()))
+	span.RecordError(data.Err)
+	span.End()
+}
@@ -0,0 +1,174 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracer_Start(t *testing.T) {
+	exporter := NewMemoryExporter()
+	tracer := NewTracer(exporter)
+
+	ctx, parent := tracer.Start(context.Background(), "parent", String("key", "value"))
+	_, child := tracer.Start(ctx, "child")
+	child.RecordError(errors.New("boom"))
+	child.End()
+	parent.End()
+	parent.End()
+
+	spans := exporter.Spans()
+	require.Len(t, spans, 2)
+
+	assert.Equal(t, "child", spans[0].Name)
+	assert.Equal(t, StatusError, spans[0].Status)
+	assert.Equal(t, "boom", spans[0].Error)
+	assert.Equal(t, spans[1].SpanID, spans[0].ParentSpanID)
+	assert.Equal(t, spans[1].TraceID, spans[0].TraceID)
+
+	assert.Equal(t, "parent", spans[1].Name)
+	assert.Equal(t, StatusOK, spans[1].Status)
+	assert.Empty(t, spans[1].ParentSpanID)
+	assert.Equal(t, []Attribute{String("key", "value")}, spans[1].Attributes)
+}
+
+func TestTracer_Nil(t *testing.T) {
+	var tracer *Tracer
+
+	ctx, span := tracer.Start(context.Background(), "noop")
+	assert.Nil(t, span)
+	assert.NotPanics(t, func() {
+		span.SetAttributes(String("key", "value"))
+		span.RecordError(errors.New("boom"))
+		span.End()
+	})
+	assert.False(t, SpanContextFromContext(ctx).IsValid())
+}
+
+func TestTraceparent(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		valid   bool
+		sampled bool
+	}{
+		{name: "sampled", header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", valid: true, sampled: true},
+		{name: "not sampled", header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", valid: true},
+		{name: "future version with extra field", header: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", valid: true, sampled: true},
+		{name: "empty", header: ""},
+		{name: "invalid version", header: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{name: "version 00 with extra field", header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"},
+		{name: "zero trace id", header: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		{name: "zero span id", header: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"},
+		{name: "not hex", header: "00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01"},
+		{name: "short trace id", header: "00-4bf92f3577b34da6-00f067aa0ba902b7-01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, ok := ParseTraceparent(tt.header)
+			assert.Equal(t, tt.valid, ok)
+			if !tt.valid {
+				return
+			}
+			assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+			assert.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+			assert.Equal(t, tt.sampled, sc.Sampled)
+		})
+	}
+
+	sc, ok := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.True(t, ok)
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", sc.Traceparent())
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	exporter := NewMemoryExporter()
+	tracer := NewTracer(exporter)
+
+	router := gin.New()
+	router.Use(Middleware(tracer))
+	router.GET("/wallets/:walletId", func(c *gin.Context) {
+		_, span := tracer.Start(c.Request.Context(), "handler work")
+		span.End()
+		c.Status(http.StatusOK)
+	})
+
+	t.Run("continues incoming trace", func(t *testing.T) {
+		exporter.Reset()
+		req := httptest.NewRequest(http.MethodGet, "/wallets/abc", nil)
+		req.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		spans := exporter.Spans()
+		require.Len(t, spans, 2)
+		request := spans[1]
+		assert.Equal(t, "GET /wallets/:walletId", request.Name)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", request.TraceID)
+		assert.Equal(t, "00f067aa0ba902b7", request.ParentSpanID)
+		assert.Contains(t, request.Attributes, Int64("http.status_code", 200))
+		assert.Equal(t, request.SpanID, spans[0].ParentSpanID)
+
+		assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-"+request.SpanID+"-01", w.Header().Get(TraceparentHeader))
+	})
+
+	t.Run("unsampled parent is not exported", func(t *testing.T) {
+		exporter.Reset()
+		req := httptest.NewRequest(http.MethodGet, "/wallets/abc", nil)
+		req.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.Empty(t, exporter.Spans())
+	})
+
+	t.Run("starts new trace", func(t *testing.T) {
+		exporter.Reset()
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/wallets/abc", nil))
+
+		spans := exporter.Spans()
+		require.Len(t, spans, 2)
+		assert.Empty(t, spans[1].ParentSpanID)
+	})
+}
+
+func TestQueryTracer(t *testing.T) {
+	exporter := NewMemoryExporter()
+	tracer := NewTracer(exporter)
+	queryTracer := NewQueryTracer(tracer)
+
+	ctx, parent := tracer.Start(context.Background(), "parent")
+	queryCtx := queryTracer.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: "SELECT balance\n\t\tFROM wallets WHERE id = $1 FOR UPDATE"})
+	queryTracer.TraceQueryEnd(queryCtx, nil, pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("SELECT 1")})
+	parent.End()
+
+	spans := exporter.Spans()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "db.query", spans[0].Name)
+	assert.Equal(t, parent.SpanContext().SpanID.String(), spans[0].ParentSpanID)
+	assert.Contains(t, spans[0].Attributes, String("db.statement", "SELECT balance FROM wallets WHERE id = $1 FOR UPDATE"))
+	assert.Contains(t, spans[0].Attributes, Int64("db.rows_affected", 1))
+}
+
+func TestQueryTracer_Disabled(t *testing.T) {
+	exporter := NewMemoryExporter()
+	tracer := NewTracer(exporter)
+	disabled := NewQueryTracer(nil)
+
+	ctx, parent := tracer.Start(context.Background(), "parent")
+	queryCtx := disabled.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: "SELECT 1"})
+	disabled.TraceQueryEnd(queryCtx, nil, pgx.TraceQueryEndData{})
+
+	assert.Empty(t, exporter.Spans(), "disabled query tracer must not end the caller's span")
+	parent.End()
+	assert.Len(t, exporter.Spans(), 1)
+}
@@ -0,0 +1,67 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/NKV510/wallet-service/internal/models"
+	"github.com/NKV510/wallet-service/internal/repository"
+)
+
+// Payload is the JSON body of every webhook request.
+type Payload struct {
+	// ID is the outbox event id; receivers should use it to drop duplicates.
+	ID        string             `json:"id"`
+	Type      string             `json:"type"`
+	WalletID  string             `json:"walletId"`
+	CreatedAt time.Time          `json:"createdAt"`
+	Data      models.Transaction `json:"data"`
+}
+
+// Dispatcher is an events.Publisher that turns balance changes into pending
+// deliveries for the matching subscriptions. Publishing the same event twice
+// enqueues nothing new, which makes the outbox's redeliveries harmless.
+type Dispatcher struct {
+	store repository.WebhookStore
+}
+
+func NewDispatcher(store repository.WebhookStore) *Dispatcher {
+	return &Dispatcher{store: store}
+}
+
+func (d *Dispatcher) Publish(ctx context.Context, event models.Event) error {
+	if event.Type != models.EventBalanceChanged {
+		return nil
+	}
+
+	var entry models.Transaction
+	if err := json.Unmarshal(event.Payload, &entry); err != nil {
+		return fmt.Errorf("failed to decode event %s: %w", event.ID, err)
+	}
+
+	eventType := EventCredited
+	if entry.BalanceChange < 0 {
+		eventType = EventDebited
+	}
+
+	body, err := json.Marshal(Payload{
+		ID:        event.ID,
+		Type:      eventType,
+		WalletID:  event.WalletID,
+		CreatedAt: event.CreatedAt,
+		Data:      entry,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = d.store.EnqueueWebhookDeliveries(ctx, models.WebhookDelivery{
+		EventID:   event.ID,
+		EventType: eventType,
+		WalletID:  event.WalletID,
+		Payload:   body,
+	})
+	return err
+}
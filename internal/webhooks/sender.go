@@ -0,0 +1,156 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/NKV510/wallet-service/internal/logging"
+	"github.com/NKV510/wallet-service/internal/models"
+	"github.com/NKV510/wallet-service/internal/repository"
+)
+
+// RetryPolicy spaces out the attempts of a failing delivery. After the n-th
+// failed attempt the next one waits BaseDelay*2^(n-1), capped at MaxDelay; a
+// delivery still failing after MaxAttempts goes to the dead-letter state.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxDelay)
+}
+
+// Sender posts due deliveries to their subscribers and records the outcome.
+type Sender struct {
+	store     repository.WebhookStore
+	client    *http.Client
+	policy    RetryPolicy
+	batchSize int
+	now       func() time.Time
+}
+
+func NewSender(store repository.WebhookStore, client *http.Client, policy RetryPolicy, batchSize int) *Sender {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	return &Sender{store: store, client: client, policy: policy, batchSize: batchSize, now: time.Now}
+}
+
+// DeliverDue sends one batch of due deliveries concurrently and returns how
+// many were attempted.
+func (s *Sender) DeliverDue(ctx context.Context) (int64, error) {
+	// The lease must outlast the request, or another instance could claim
+	// the delivery while it is still being sent.
+	lease := 2*s.client.Timeout + time.Minute
+	tasks, err := s.store.ClaimWebhookDeliveries(ctx, s.batchSize, lease)
+	if err != nil {
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(tasks))
+	for i, task := range tasks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = s.store.RecordWebhookAttempt(ctx, task.ID, s.attempt(ctx, task))
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return int64(len(tasks)), err
+		}
+	}
+	return int64(len(tasks)), nil
+}
+
+func (s *Sender) attempt(ctx context.Context, task repository.WebhookDeliveryTask) repository.WebhookAttempt {
+	statusCode, err := s.send(ctx, task)
+	if err == nil {
+		return repository.WebhookAttempt{Status: models.DeliverySucceeded, StatusCode: statusCode}
+	}
+
+	result := repository.WebhookAttempt{StatusCode: statusCode, Error: err.Error()}
+	attempts := task.Attempts + 1
+	if attempts >= s.policy.MaxAttempts {
+		result.Status = models.DeliveryDead
+	} else {
+		result.Status = models.DeliveryPending
+		result.NextAttemptAt = s.now().Add(s.policy.Backoff(attempts))
+	}
+	return result
+}
+
+func (s *Sender) send(ctx context.Context, task repository.WebhookDeliveryTask) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, task.URL, bytes.NewReader(task.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "wallet-service-webhooks")
+	req.Header.Set(HeaderEventID, task.EventID)
+	req.Header.Set(HeaderEventType, task.EventType)
+	req.Header.Set(HeaderDelivery, task.ID)
+
+	now := s.now()
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(task.Secret, now, task.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Run delivers due webhooks every interval until ctx is cancelled.
+func (s *Sender) Run(ctx context.Context, interval time.Duration) {
+	logger := logging.FromContext(ctx).With("job", "webhook delivery")
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				n, err := s.DeliverDue(ctx)
+				if err != nil {
+					logger.Error("background job failed", "error", err)
+					break
+				}
+				if n > 0 {
+					logger.Debug("background job finished", "result", "webhooks attempted", "count", n)
+				}
+				if n < int64(s.batchSize) || ctx.Err() != nil {
+					break
+				}
+			}
+		}
+	}
+}
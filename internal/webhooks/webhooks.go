@@ -0,0 +1,105 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/NKV510/wallet-service/internal/models"
+	"github.com/NKV510/wallet-service/internal/repository"
+)
+
+const (
+	EventCredited = "wallet.credited"
+	EventDebited  = "wallet.debited"
+
+	secretPrefix = "whsec_"
+)
+
+var EventTypes = []string{EventCredited, EventDebited}
+
+// CreateSubscription validates request and stores a new subscription,
+// generating a signing secret unless one is given.
+func CreateSubscription(ctx context.Context, store repository.WebhookStore, request models.WebhookRequest) (*models.CreateWebhookResponse, error) {
+	webhook := models.WebhookSubscription{
+		URL:        request.URL,
+		EventTypes: request.EventTypes,
+		WalletIDs:  request.WalletIDs,
+		Active:     request.Active == nil || *request.Active,
+	}
+	if err := normalize(&webhook); err != nil {
+		return nil, err
+	}
+
+	secret := request.Secret
+	if secret == "" {
+		secret = NewSecret()
+	}
+
+	created, err := store.CreateWebhook(ctx, webhook, secret)
+	if err != nil {
+		return nil, err
+	}
+	return &models.CreateWebhookResponse{WebhookSubscription: *created, Secret: secret}, nil
+}
+
+// UpdateSubscription applies the non-empty fields of request to the
+// subscription.
+func UpdateSubscription(ctx context.Context, store repository.WebhookStore, id string, request models.WebhookRequest) (*models.WebhookSubscription, error) {
+	webhook, err := store.GetWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.URL != "" {
+		webhook.URL = request.URL
+	}
+	if request.EventTypes != nil {
+		webhook.EventTypes = request.EventTypes
+	}
+	if request.WalletIDs != nil {
+		webhook.WalletIDs = request.WalletIDs
+	}
+	if request.Active != nil {
+		webhook.Active = *request.Active
+	}
+	if err := normalize(webhook); err != nil {
+		return nil, err
+	}
+
+	return store.UpdateWebhook(ctx, *webhook, request.Secret)
+}
+
+func normalize(webhook *models.WebhookSubscription) error {
+	parsed, err := url.Parse(webhook.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", repository.ErrInvalidOperation)
+	}
+
+	if len(webhook.EventTypes) == 0 {
+		return fmt.Errorf("%w: at least one event type is required", repository.ErrInvalidOperation)
+	}
+	for _, eventType := range webhook.EventTypes {
+		if !slices.Contains(EventTypes, eventType) {
+			return fmt.Errorf("%w: unknown event type %q", repository.ErrInvalidOperation, eventType)
+		}
+	}
+	webhook.EventTypes = slices.Compact(slices.Sorted(slices.Values(webhook.EventTypes)))
+
+	walletIDs := make([]string, 0, len(webhook.WalletIDs))
+	for _, id := range webhook.WalletIDs {
+		walletIDs = append(walletIDs, strings.ToLower(strings.TrimSpace(id)))
+	}
+	webhook.WalletIDs = walletIDs
+	return nil
+}
+
+func NewSecret() string {
+	var secret [24]byte
+	rand.Read(secret[:])
+	return secretPrefix + hex.EncodeToString(secret[:])
+}
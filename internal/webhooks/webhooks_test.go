@@ -0,0 +1,357 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NKV510/wallet-service/internal/events"
+	"github.com/NKV510/wallet-service/internal/models"
+	"github.com/NKV510/wallet-service/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const walletID = "123e4567-e89b-12d3-a456-426614174000"
+
+// receiver is a webhook endpoint that answers with the queued status codes,
+// then 200, and verifies every request it gets. The tolerance allows for the
+// test sender's clock.
+type receiver struct {
+	t      *testing.T
+	secret string
+
+	mu       sync.Mutex
+	statuses []int
+	bodies   []Payload
+	headers  []http.Header
+}
+
+func newReceiver(t *testing.T, secret string, statuses ...int) (*receiver, *httptest.Server) {
+	r := &receiver{t: t, secret: secret, statuses: statuses}
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return r, server
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	require.NoError(r.t, err)
+	assert.NoError(r.t, Verify(r.secret, req.Header.Get(HeaderTimestamp), req.Header.Get(HeaderSignature), body, time.Now(), 2*time.Hour))
+	assert.Equal(r.t, "application/json", req.Header.Get("Content-Type"))
+
+	var payload Payload
+	require.NoError(r.t, json.Unmarshal(body, &payload))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bodies = append(r.bodies, payload)
+	r.headers = append(r.headers, req.Header.Clone())
+
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (r *receiver) received() []Payload {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Payload(nil), r.bodies...)
+}
+
+// setup subscribes url to all events and routes balance changes through the
+// outbox to the webhook dispatcher, as main does.
+func setup(t *testing.T, url string) (*repository.MemoryWalletRepository, *events.Relay, *models.CreateWebhookResponse) {
+	t.Helper()
+	ctx := context.Background()
+
+	store := repository.NewMemoryWalletRepository()
+	require.NoError(t, store.CreateWallet(ctx, walletID))
+
+	webhook, err := CreateSubscription(ctx, store, models.WebhookRequest{URL: url, EventTypes: EventTypes})
+	require.NoError(t, err)
+
+	return store, events.NewRelay(store, NewDispatcher(store), 10), webhook
+}
+
+// newTestSender returns a sender whose clock runs an hour behind, so that
+// failed deliveries are due for a retry right away.
+func newTestSender(store repository.WebhookStore, maxAttempts int) (*Sender, time.Time) {
+	sender := NewSender(store, &http.Client{Timeout: time.Second}, RetryPolicy{
+		MaxAttempts: maxAttempts,
+		BaseDelay:   time.Minute,
+		MaxDelay:    10 * time.Minute,
+	}, 10)
+	now := time.Now().Add(-time.Hour)
+	sender.now = func() time.Time { return now }
+	return sender, now
+}
+
+func TestSignature(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	body := []byte(`{"id":"1"}`)
+	signature := Sign("secret", now, body)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	assert.NoError(t, Verify("secret", timestamp, signature, body, now.Add(30*time.Second), time.Minute))
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		signature string
+		body      string
+		now       time.Time
+	}{
+		{name: "wrong secret", secret: "other", timestamp: timestamp, signature: signature, body: string(body), now: now},
+		{name: "tampered body", secret: "secret", timestamp: timestamp, signature: signature, body: `{"id":"2"}`, now: now},
+		{name: "shifted timestamp", secret: "secret", timestamp: strconv.FormatInt(now.Unix()+1, 10), signature: signature, body: string(body), now: now},
+		{name: "stale", secret: "secret", timestamp: timestamp, signature: signature, body: string(body), now: now.Add(2 * time.Minute)},
+		{name: "bad timestamp", secret: "secret", timestamp: "yesterday", signature: signature, body: string(body), now: now},
+		{name: "missing prefix", secret: "secret", timestamp: timestamp, signature: signature[len("sha256="):], body: string(body), now: now},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.timestamp, tt.signature, []byte(tt.body), tt.now, time.Minute)
+			assert.ErrorIs(t, err, ErrInvalidSignature)
+		})
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 30 * time.Second, MaxDelay: 5 * time.Minute}
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{4, 4 * time.Minute},
+		{5, 5 * time.Minute},
+		{50, 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, policy.Backoff(tt.attempt), "attempt %d", tt.attempt)
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	ctx := context.Background()
+	rcv, server := newReceiver(t, "")
+	store, relay, webhook := setup(t, server.URL)
+	rcv.secret = webhook.Secret
+
+	require.NoError(t, store.UpdateWalletBalance(ctx, walletID, models.DEPOSIT, 100))
+	require.NoError(t, store.UpdateWalletBalance(ctx, walletID, models.WITHDRAW, 40))
+
+	n, err := relay.Drain(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n)
+
+	sender, _ := newTestSender(store, 3)
+	n, err = sender.DeliverDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	received := rcv.received()
+	require.Len(t, received, 2)
+	byType := map[string]Payload{}
+	for _, payload := range received {
+		byType[payload.Type] = payload
+	}
+	assert.Equal(t, int64(100), byType[EventCredited].Data.Amount)
+	assert.Equal(t, int64(40), byType[EventDebited].Data.Amount)
+	assert.Equal(t, int64(-40), byType[EventDebited].Data.BalanceChange)
+	require.NotNil(t, byType[EventDebited].Data.BalanceAfter)
+	assert.Equal(t, int64(60), *byType[EventDebited].Data.BalanceAfter)
+	assert.Equal(t, walletID, byType[EventDebited].WalletID)
+
+	for _, header := range rcv.headers {
+		assert.NotEmpty(t, header.Get(HeaderDelivery))
+		assert.Contains(t, EventTypes, header.Get(HeaderEventType))
+	}
+
+	_, err = relay.Drain(ctx)
+	require.NoError(t, err)
+	n, err = sender.DeliverDue(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n, "delivered events are not sent again")
+}
+
+func TestDispatcher_Filters(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryWalletRepository()
+	require.NoError(t, store.CreateWallet(ctx, walletID))
+
+	debits, err := CreateSubscription(ctx, store, models.WebhookRequest{URL: "https://example.com/debits", EventTypes: []string{EventDebited}})
+	require.NoError(t, err)
+	other, err := CreateSubscription(ctx, store, models.WebhookRequest{
+		URL:        "https://example.com/other",
+		EventTypes: EventTypes,
+		WalletIDs:  []string{"223E4567-E89B-12D3-A456-426614174000"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"223e4567-e89b-12d3-a456-426614174000"}, other.WalletIDs)
+
+	require.NoError(t, store.UpdateWalletBalance(ctx, walletID, models.DEPOSIT, 100))
+	_, err = events.NewRelay(store, NewDispatcher(store), 10).Drain(ctx)
+	require.NoError(t, err)
+
+	for _, id := range []string{debits.ID, other.ID} {
+		deliveries, err := store.ListWebhookDeliveries(ctx, id, models.WebhookDeliveryFilter{Limit: 10})
+		require.NoError(t, err)
+		assert.Empty(t, deliveries)
+	}
+}
+
+func TestSender_RetriesUntilSuccess(t *testing.T) {
+	ctx := context.Background()
+	rcv, server := newReceiver(t, "", http.StatusInternalServerError, http.StatusBadGateway)
+	store, relay, webhook := setup(t, server.URL)
+	rcv.secret = webhook.Secret
+
+	require.NoError(t, store.UpdateWalletBalance(ctx, walletID, models.DEPOSIT, 100))
+	_, err := relay.Drain(ctx)
+	require.NoError(t, err)
+
+	sender, now := newTestSender(store, 5)
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		n, err := sender.DeliverDue(ctx)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), n)
+
+		deliveries, err := store.ListWebhookDeliveries(ctx, webhook.ID, models.WebhookDeliveryFilter{Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		d := deliveries[0]
+		assert.Equal(t, models.DeliveryPending, d.Status)
+		assert.Equal(t, attempt, d.Attempts)
+		require.NotNil(t, d.NextAttemptAt)
+		assert.WithinDuration(t, now.Add(sender.policy.Backoff(attempt)), *d.NextAttemptAt, time.Second)
+	}
+
+	n, err := sender.DeliverDue(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+
+	deliveries, err := store.ListWebhookDeliveries(ctx, webhook.ID, models.WebhookDeliveryFilter{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, models.DeliverySucceeded, deliveries[0].Status)
+	assert.Equal(t, http.StatusOK, deliveries[0].LastStatusCode)
+	assert.Empty(t, deliveries[0].LastError)
+	assert.Nil(t, deliveries[0].NextAttemptAt)
+
+	received := rcv.received()
+	require.Len(t, received, 3)
+	for _, payload := range received {
+		assert.Equal(t, received[0].ID, payload.ID, "retries resend the same event")
+	}
+}
+
+func TestSender_DeadLetter(t *testing.T) {
+	ctx := context.Background()
+	rcv, server := newReceiver(t, "", http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError)
+	store, relay, webhook := setup(t, server.URL)
+	rcv.secret = webhook.Secret
+
+	require.NoError(t, store.UpdateWalletBalance(ctx, walletID, models.DEPOSIT, 100))
+	_, err := relay.Drain(ctx)
+	require.NoError(t, err)
+
+	sender, _ := newTestSender(store, 3)
+	for i := 0; i < 3; i++ {
+		n, err := sender.DeliverDue(ctx)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), n)
+	}
+
+	deliveries, err := store.ListWebhookDeliveries(ctx, webhook.ID, models.WebhookDeliveryFilter{Status: models.DeliveryDead, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	dead := deliveries[0]
+	assert.Equal(t, 3, dead.Attempts)
+	assert.Equal(t, http.StatusInternalServerError, dead.LastStatusCode)
+	assert.Equal(t, "unexpected status 500", dead.LastError)
+
+	n, err := sender.DeliverDue(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n, "dead deliveries are not retried")
+
+	_, err = store.RedeliverWebhook(ctx, webhook.ID, dead.ID)
+	require.NoError(t, err)
+	n, err = sender.DeliverDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	deliveries, err = store.ListWebhookDeliveries(ctx, webhook.ID, models.WebhookDeliveryFilter{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, models.DeliverySucceeded, deliveries[0].Status)
+	assert.Len(t, rcv.received(), 4)
+}
+
+func TestSender_TransportError(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	store, relay, webhook := setup(t, url)
+	require.NoError(t, store.UpdateWalletBalance(ctx, walletID, models.DEPOSIT, 100))
+	_, err := relay.Drain(ctx)
+	require.NoError(t, err)
+
+	sender, _ := newTestSender(store, 3)
+	n, err := sender.DeliverDue(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+
+	deliveries, err := store.ListWebhookDeliveries(ctx, webhook.ID, models.WebhookDeliveryFilter{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, models.DeliveryPending, deliveries[0].Status)
+	assert.Zero(t, deliveries[0].LastStatusCode)
+	assert.NotEmpty(t, deliveries[0].LastError)
+}
+
+func TestCreateSubscription_Validation(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryWalletRepository()
+
+	tests := []struct {
+		name    string
+		request models.WebhookRequest
+	}{
+		{name: "missing url", request: models.WebhookRequest{EventTypes: EventTypes}},
+		{name: "relative url", request: models.WebhookRequest{URL: "/hook", EventTypes: EventTypes}},
+		{name: "unsupported scheme", request: models.WebhookRequest{URL: "ftp://example.com", EventTypes: EventTypes}},
+		{name: "no event types", request: models.WebhookRequest{URL: "https://example.com"}},
+		{name: "unknown event type", request: models.WebhookRequest{URL: "https://example.com", EventTypes: []string{"wallet.closed"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CreateSubscription(ctx, store, tt.request)
+			assert.ErrorIs(t, err, repository.ErrInvalidOperation)
+		})
+	}
+
+	created, err := CreateSubscription(ctx, store, models.WebhookRequest{
+		URL:        "https://example.com",
+		EventTypes: []string{EventDebited, EventCredited, EventDebited},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{EventCredited, EventDebited}, created.EventTypes)
+	assert.True(t, created.Active)
+	assert.Regexp(t, `^whsec_[0-9a-f]{48}$`, created.Secret)
+}
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS wallet_transactions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    wallet_id UUID NOT NULL,
+    operation_type VARCHAR(16) NOT NULL,
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    balance_before BIGINT NOT NULL,
+    balance_after BIGINT NOT NULL,
+    transfer_id UUID,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_wallet_transactions_wallet_created ON wallet_transactions(wallet_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_wallet_transactions_transfer_id ON wallet_transactions(transfer_id) WHERE transfer_id IS NOT NULL;
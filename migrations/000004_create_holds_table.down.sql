@@ -0,0 +1,3 @@
+ALTER TABLE wallet_transactions DROP COLUMN IF EXISTS hold_id;
+
+DROP TABLE IF EXISTS holds;
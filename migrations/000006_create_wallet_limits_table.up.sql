@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS wallet_limits (
+    wallet_id UUID PRIMARY KEY REFERENCES wallets(id) ON DELETE CASCADE,
+    per_operation BIGINT CHECK (per_operation >= 0),
+    daily BIGINT CHECK (daily >= 0),
+    monthly BIGINT CHECK (monthly >= 0),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,3 @@
+ALTER TABLE wallets
+    DROP COLUMN IF EXISTS has_limits,
+    DROP COLUMN IF EXISTS has_holds;
@@ -0,0 +1,9 @@
+ALTER TABLE wallets
+    ADD COLUMN IF NOT EXISTS has_holds BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS has_limits BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE wallets w SET has_holds = TRUE
+WHERE EXISTS (SELECT 1 FROM holds h WHERE h.wallet_id = w.id AND h.status = 'ACTIVE');
+
+UPDATE wallets w SET has_limits = TRUE
+WHERE EXISTS (SELECT 1 FROM wallet_limits l WHERE l.wallet_id = w.id);
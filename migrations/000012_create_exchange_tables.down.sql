@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_wallet_transactions_exchange_id;
+ALTER TABLE wallet_transactions DROP COLUMN IF EXISTS exchange_id;
+
+DROP TABLE IF EXISTS exchange_quotes;
+DROP TABLE IF EXISTS exchange_rates;
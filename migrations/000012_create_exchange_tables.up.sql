@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    from_currency VARCHAR(3) NOT NULL,
+    to_currency VARCHAR(3) NOT NULL,
+    rate NUMERIC NOT NULL CHECK (rate > 0),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (from_currency, to_currency)
+);
+
+CREATE TABLE IF NOT EXISTS exchange_quotes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    from_currency VARCHAR(3) NOT NULL,
+    to_currency VARCHAR(3) NOT NULL,
+    rate NUMERIC NOT NULL CHECK (rate > 0),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_exchange_quotes_expires_at ON exchange_quotes(expires_at);
+
+ALTER TABLE wallet_transactions ADD COLUMN IF NOT EXISTS exchange_id UUID;
+
+CREATE INDEX IF NOT EXISTS idx_wallet_transactions_exchange_id ON wallet_transactions(exchange_id) WHERE exchange_id IS NOT NULL;
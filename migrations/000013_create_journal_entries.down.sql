@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS journal_entries;
+DROP FUNCTION IF EXISTS check_journal_balanced();
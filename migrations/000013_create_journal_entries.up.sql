@@ -0,0 +1,55 @@
+CREATE TABLE IF NOT EXISTS journal_entries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    journal_id UUID NOT NULL,
+    transaction_id UUID NOT NULL REFERENCES wallet_transactions(id),
+    account VARCHAR(64) NOT NULL,
+    currency VARCHAR(3),
+    debit BIGINT NOT NULL DEFAULT 0 CHECK (debit >= 0),
+    credit BIGINT NOT NULL DEFAULT 0 CHECK (credit >= 0),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CHECK ((debit = 0) <> (credit = 0))
+);
+
+CREATE INDEX IF NOT EXISTS idx_journal_entries_journal_id ON journal_entries(journal_id);
+CREATE INDEX IF NOT EXISTS idx_journal_entries_account ON journal_entries(account);
+
+-- Post the history recorded before the journal existed. A wallet account
+-- adds up only to what the history explains: balances a wallet had before
+-- its history was recorded get an OPENING entry in 000022.
+INSERT INTO journal_entries (journal_id, transaction_id, account, currency, debit, credit, created_at)
+SELECT COALESCE(t.transfer_id, t.exchange_id, t.id), t.id, posting.account, w.currency,
+    GREATEST(-posting.amount, 0), GREATEST(posting.amount, 0), t.created_at
+FROM wallet_transactions t
+JOIN wallets w ON w.id = t.wallet_id
+CROSS JOIN LATERAL (VALUES
+    ('wallet:' || t.wallet_id, t.balance_after - t.balance_before),
+    (CASE t.operation_type
+        WHEN 'DEPOSIT' THEN 'cash_in'
+        WHEN 'WITHDRAW' THEN 'cash_out'
+        WHEN 'EXCHANGE' THEN 'fx'
+    END, t.balance_before - t.balance_after)
+) AS posting(account, amount)
+WHERE posting.account IS NOT NULL AND posting.amount <> 0;
+
+-- Checked at commit, so that the two legs of a transfer can be posted by
+-- separate statements.
+CREATE OR REPLACE FUNCTION check_journal_balanced() RETURNS trigger AS $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM journal_entries
+        WHERE journal_id = NEW.journal_id
+        GROUP BY currency
+        HAVING SUM(debit) <> SUM(credit)
+    ) THEN
+        RAISE EXCEPTION 'journal % does not balance', NEW.journal_id
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS journal_balanced ON journal_entries;
+CREATE CONSTRAINT TRIGGER journal_balanced
+    AFTER INSERT ON journal_entries
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION check_journal_balanced();
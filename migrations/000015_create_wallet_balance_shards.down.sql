@@ -0,0 +1,14 @@
+-- The table is missing when the up migration failed before creating it.
+DO $$
+BEGIN
+    IF to_regclass('wallet_balance_shards') IS NOT NULL THEN
+        UPDATE wallets w SET balance = w.balance + s.total
+        FROM (SELECT wallet_id, SUM(balance) AS total FROM wallet_balance_shards GROUP BY wallet_id) s
+        WHERE w.id = s.wallet_id;
+    END IF;
+END $$;
+
+DROP TABLE IF EXISTS wallet_balance_shards;
+
+ALTER TABLE wallets
+    DROP COLUMN IF EXISTS shard_count;
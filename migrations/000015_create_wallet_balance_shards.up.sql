@@ -0,0 +1,10 @@
+ALTER TABLE wallets
+    ADD COLUMN IF NOT EXISTS shard_count INTEGER NOT NULL DEFAULT 0 CHECK (shard_count >= 0);
+
+CREATE TABLE IF NOT EXISTS wallet_balance_shards (
+    wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    shard INTEGER NOT NULL CHECK (shard >= 0),
+    balance BIGINT NOT NULL DEFAULT 0 CHECK (balance >= 0),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (wallet_id, shard)
+);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_wallet_transactions_wallet_seq;
+ALTER TABLE wallet_transactions DROP COLUMN IF EXISTS seq;
+DROP SEQUENCE IF EXISTS wallet_transactions_seq_seq;
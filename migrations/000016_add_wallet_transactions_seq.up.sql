@@ -0,0 +1,21 @@
+-- Entries written in one transaction share created_at, so the ledger is
+-- ordered by seq instead. Existing entries are numbered in their old order.
+CREATE SEQUENCE IF NOT EXISTS wallet_transactions_seq_seq;
+
+ALTER TABLE wallet_transactions ADD COLUMN IF NOT EXISTS seq BIGINT;
+
+UPDATE wallet_transactions t SET seq = o.seq
+FROM (
+    SELECT id, row_number() OVER (ORDER BY created_at, id) AS seq
+    FROM wallet_transactions
+) o
+WHERE t.id = o.id;
+
+SELECT setval('wallet_transactions_seq_seq', COALESCE((SELECT MAX(seq) FROM wallet_transactions), 0) + 1, false);
+
+ALTER TABLE wallet_transactions
+    ALTER COLUMN seq SET DEFAULT nextval('wallet_transactions_seq_seq'),
+    ALTER COLUMN seq SET NOT NULL;
+ALTER SEQUENCE wallet_transactions_seq_seq OWNED BY wallet_transactions.seq;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_wallet_transactions_wallet_seq ON wallet_transactions(wallet_id, seq DESC);
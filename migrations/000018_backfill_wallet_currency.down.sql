@@ -0,0 +1,2 @@
+ALTER TABLE journal_entries ALTER COLUMN currency DROP NOT NULL;
+ALTER TABLE wallets ALTER COLUMN currency DROP NOT NULL;
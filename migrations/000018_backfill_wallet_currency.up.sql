@@ -0,0 +1,12 @@
+-- Wallets from before currencies were tracked were treated as wallets in
+-- DEFAULT_CURRENCY, which the migrator passes in app.default_currency.
+UPDATE wallets SET currency = COALESCE(NULLIF(current_setting('app.default_currency', true), ''), 'USD')
+WHERE currency IS NULL;
+
+UPDATE journal_entries j SET currency = w.currency
+FROM wallet_transactions t
+JOIN wallets w ON w.id = t.wallet_id
+WHERE j.transaction_id = t.id AND j.currency IS NULL;
+
+ALTER TABLE wallets ALTER COLUMN currency SET NOT NULL;
+ALTER TABLE journal_entries ALTER COLUMN currency SET NOT NULL;
@@ -0,0 +1,21 @@
+DROP TRIGGER IF EXISTS journal_balanced ON journal_entries;
+
+CREATE OR REPLACE FUNCTION check_journal_balanced() RETURNS trigger AS $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM journal_entries
+        WHERE journal_id = NEW.journal_id
+        GROUP BY currency
+        HAVING SUM(debit) <> SUM(credit)
+    ) THEN
+        RAISE EXCEPTION 'journal % does not balance', NEW.journal_id
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER journal_balanced
+    AFTER INSERT ON journal_entries
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION check_journal_balanced();
@@ -0,0 +1,28 @@
+-- Check only the journals a statement posted to, once at the end of the
+-- statement, instead of the whole journal once per row at commit. Every
+-- journal is posted by a single statement.
+DROP TRIGGER IF EXISTS journal_balanced ON journal_entries;
+
+CREATE OR REPLACE FUNCTION check_journal_balanced() RETURNS trigger AS $$
+DECLARE
+    unbalanced UUID;
+BEGIN
+    SELECT j.journal_id INTO unbalanced
+    FROM journal_entries j
+    WHERE j.journal_id IN (SELECT journal_id FROM posted)
+    GROUP BY j.journal_id, j.currency
+    HAVING SUM(j.debit) <> SUM(j.credit)
+    LIMIT 1;
+
+    IF FOUND THEN
+        RAISE EXCEPTION 'journal % does not balance', unbalanced
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER journal_balanced
+    AFTER INSERT ON journal_entries
+    REFERENCING NEW TABLE AS posted
+    FOR EACH STATEMENT EXECUTE FUNCTION check_journal_balanced();
@@ -0,0 +1,13 @@
+ALTER TABLE wallet_transactions DROP CONSTRAINT IF EXISTS wallet_transactions_balances_check;
+
+-- Entries without balances get a running balance in ledger order.
+UPDATE wallet_transactions t SET balance_after = r.balance_after, balance_before = r.balance_after - t.balance_change
+FROM (
+    SELECT id, SUM(balance_change) OVER (PARTITION BY wallet_id ORDER BY seq) AS balance_after
+    FROM wallet_transactions
+) r
+WHERE t.id = r.id AND t.balance_before IS NULL;
+
+ALTER TABLE wallet_transactions ALTER COLUMN balance_before SET NOT NULL;
+ALTER TABLE wallet_transactions ALTER COLUMN balance_after SET NOT NULL;
+ALTER TABLE wallet_transactions DROP COLUMN IF EXISTS balance_change;
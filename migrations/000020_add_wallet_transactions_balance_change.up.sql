@@ -0,0 +1,19 @@
+-- Entries made through the shards of a sharded wallet do not know the whole
+-- balance, so they record only the change; balance_before and balance_after
+-- are NULL for them.
+ALTER TABLE wallet_transactions ADD COLUMN IF NOT EXISTS balance_change BIGINT;
+UPDATE wallet_transactions SET balance_change = balance_after - balance_before WHERE balance_change IS NULL;
+ALTER TABLE wallet_transactions ALTER COLUMN balance_change SET NOT NULL;
+
+ALTER TABLE wallet_transactions ALTER COLUMN balance_before DROP NOT NULL;
+ALTER TABLE wallet_transactions ALTER COLUMN balance_after DROP NOT NULL;
+ALTER TABLE wallet_transactions ADD CONSTRAINT wallet_transactions_balances_check CHECK (
+    (balance_before IS NULL AND balance_after IS NULL)
+    OR balance_after - balance_before = balance_change
+);
+
+-- Events still waiting in the outbox get the change webhooks read.
+UPDATE outbox_events
+SET payload = payload || jsonb_build_object('balanceChange', (payload->>'balanceAfter')::bigint - (payload->>'balanceBefore')::bigint)
+WHERE event_type = 'wallet.balance_changed' AND published_at IS NULL
+    AND payload ? 'balanceAfter' AND NOT payload ? 'balanceChange';
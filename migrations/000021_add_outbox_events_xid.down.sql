@@ -0,0 +1 @@
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS xid;
@@ -0,0 +1,5 @@
+-- Ids are taken from the sequence when a row is inserted, not when its
+-- transaction commits, so a later id can become visible before an earlier
+-- one. The inserting transaction's id lets the relay hold back events until
+-- every transaction that might still add an earlier one has finished.
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS xid xid8 NOT NULL DEFAULT pg_current_xact_id();
@@ -0,0 +1,3 @@
+DELETE FROM journal_entries
+WHERE transaction_id IN (SELECT id FROM wallet_transactions WHERE operation_type = 'OPENING');
+DELETE FROM wallet_transactions WHERE operation_type = 'OPENING';
@@ -0,0 +1,34 @@
+-- Wallets funded before their history was recorded hold balances that the
+-- history does not explain. Each of them gets one OPENING entry for the
+-- difference, posted against opening_balances in the same statement so the
+-- journal trigger sees both legs, after which the history and the wallet
+-- account add up to the balance. Wallets whose history already adds up get
+-- none, so running this again changes nothing.
+WITH totals AS (
+    SELECT wallet_id, SUM(balance_change)::bigint AS total
+    FROM wallet_transactions
+    GROUP BY wallet_id
+),
+missing AS (
+    SELECT w.id AS wallet_id, w.currency,
+        COALESCE(t.total, 0) AS balance_before,
+        w.balance + COALESCE((SELECT SUM(s.balance) FROM wallet_balance_shards s WHERE s.wallet_id = w.id), 0) AS balance_after
+    FROM wallets w
+    LEFT JOIN totals t ON t.wallet_id = w.id
+),
+entry AS (
+    INSERT INTO wallet_transactions (wallet_id, operation_type, amount, balance_before, balance_after, balance_change)
+    SELECT wallet_id, 'OPENING', ABS(balance_after - balance_before), balance_before, balance_after, balance_after - balance_before
+    FROM missing
+    WHERE balance_after <> balance_before
+    RETURNING id, wallet_id, balance_change, created_at
+)
+INSERT INTO journal_entries (journal_id, transaction_id, account, currency, debit, credit, created_at)
+SELECT entry.id, entry.id, posting.account, missing.currency,
+    GREATEST(-posting.amount, 0), GREATEST(posting.amount, 0), entry.created_at
+FROM entry
+JOIN missing ON missing.wallet_id = entry.wallet_id
+CROSS JOIN LATERAL (VALUES
+    ('wallet:' || entry.wallet_id, entry.balance_change),
+    ('opening_balances', -entry.balance_change)
+) AS posting(account, amount);
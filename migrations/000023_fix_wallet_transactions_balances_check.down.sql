@@ -0,0 +1,5 @@
+ALTER TABLE wallet_transactions DROP CONSTRAINT IF EXISTS wallet_transactions_balances_check;
+ALTER TABLE wallet_transactions ADD CONSTRAINT wallet_transactions_balances_check CHECK (
+    (balance_before IS NULL AND balance_after IS NULL)
+    OR balance_after - balance_before = balance_change
+);
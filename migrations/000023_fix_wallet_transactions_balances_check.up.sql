@@ -0,0 +1,9 @@
+-- The check let a row through with only one of balance_before and
+-- balance_after NULL, because the difference with a NULL is NULL. Either
+-- both are NULL, for entries made through shards, or both are set and add
+-- up to balance_change.
+ALTER TABLE wallet_transactions DROP CONSTRAINT IF EXISTS wallet_transactions_balances_check;
+ALTER TABLE wallet_transactions ADD CONSTRAINT wallet_transactions_balances_check CHECK (
+    (balance_before IS NULL) = (balance_after IS NULL)
+    AND (balance_before IS NULL OR balance_after - balance_before = balance_change)
+);